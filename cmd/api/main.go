@@ -1,42 +1,364 @@
+// Command api runs the notes HTTP API.
+//
+// STORAGE_DRIVER selects Postgres (the default) or an embedded SQLite file;
+// with SQLite, saved searches and Idempotency-Key support are disabled.
+//
+// "api migrate status|up|down|redo" manages the Postgres schema instead, see
+// runMigrate. SQLite databases are migrated when opened.
+//
+// Exit codes of the server:
+//
+//	0 - stopped by SIGINT/SIGTERM and shut down cleanly
+//	1 - startup failed (configuration, database, migrations, prepared statements)
+//	2 - the HTTP server failed while running
+//	3 - graceful shutdown did not finish cleanly within SHUTDOWN_TIMEOUT
 package main
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+
 	"example.com/notes-api-pz14/internal/config"
-	"example.com/notes-api-pz14/internal/db"
+	"example.com/notes-api-pz14/internal/cors"
+	"example.com/notes-api-pz14/internal/health"
+	"example.com/notes-api-pz14/internal/httpx"
+	"example.com/notes-api-pz14/internal/idempotency"
+	"example.com/notes-api-pz14/internal/lifecycle"
+	"example.com/notes-api-pz14/internal/logging"
+	"example.com/notes-api-pz14/internal/metrics"
+	"example.com/notes-api-pz14/internal/migrate"
 	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/overload"
+	"example.com/notes-api-pz14/internal/querystats"
+	"example.com/notes-api-pz14/internal/ratelimit"
+	"example.com/notes-api-pz14/internal/tracing"
+	"example.com/notes-api-pz14/migrations"
+)
+
+const (
+	exitOK = iota
+	exitStartup
+	exitServer
+	exitShutdown
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	os.Exit(run())
+}
+
+func run() int {
 	cfg := config.Load()
-	if cfg.DatabaseURL == "" {
-		log.Fatal("DATABASE_URL is required")
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		slog.Error("startup", "error", err)
+		return exitStartup
 	}
+	slog.SetDefault(logger)
 
-	ctx := context.Background()
+	bodyLimits, err := httpx.ParseSizes(cfg.MaxBodyBytesRoutes)
+	if err != nil {
+		logger.Error("startup: body limits", "error", err)
+		return exitStartup
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	dbConn, err := db.Open(ctx, cfg.DatabaseURL, cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+	st, err := openStorage(ctx, cfg, logger)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("startup: storage", "driver", cfg.StorageDriver, "error", err)
+		return exitStartup
+	}
+	if st.repo == nil {
+		logger.Warn("startup: saved searches and idempotency keys need Postgres, disabled", "driver", cfg.StorageDriver)
+	}
+
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		logger.Error("startup: trace exporter", "error", err)
+		_ = st.Close()
+		return exitStartup
+	}
+	tracer := tracing.New(cfg.TracingService, exporter, cfg.TracingSampleRatio).
+		OnExportError(func(err error) { logger.Error("tracing", "error", err) })
+	st.AddHook(tracing.NewQueryHook(tracer, st.system))
+	st.AddHook(logging.QueryHook{})
+	queryStats := querystats.NewCollector(querystats.DefaultSamples)
+	st.AddHook(queryStats)
+	slowLog := querystats.NewSlowLog(cfg.SlowQueryThreshold)
+	if cfg.SlowQueryExplain && st.repo != nil {
+		slowLog.WithExplain(st.db, cfg.SlowQueryExplainTimeout, cfg.SlowQueryExplainInterval)
 	}
-	defer dbConn.SQL.Close()
+	st.AddHook(slowLog)
 
-	repo, err := notes.NewRepository(ctx, dbConn.SQL)
+	limiter, err := newLimiter(cfg, st)
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("startup: rate limits", "error", err)
+		_ = exporter.Close()
+		_ = st.Close()
+		return exitStartup
+	}
+
+	lc := lifecycle.New()
+
+	checks := health.NewRegistry(cfg.ReadyCheckTimeout)
+	checks.Register("shutdown", health.DrainChecker(lc.Draining))
+	checks.Register("database", health.PingChecker(st.db))
+	if cfg.ReadyCheckSchema && st.repo != nil {
+		checks.Register("schema", health.SchemaChecker(st.db, migrations.Latest))
+	}
+
+	reg := metrics.NewRegistry()
+	httpMetrics := metrics.NewHTTPMetrics(reg)
+	store := metrics.NewStoreMetrics(reg).Instrument(st.store)
+	if st.pool != nil {
+		metrics.RegisterPoolStats(reg, st.pool.Pool)
+	} else {
+		metrics.RegisterDBStats(reg, st.db)
+	}
+	retries := reg.NewCounterVec("store_retries_total",
+		"Repository calls retried after a transient database error.", "operation")
+	st.WithRetry(notes.RetryPolicy{
+		MaxAttempts: cfg.DBRetryAttempts,
+		BaseDelay:   cfg.DBRetryBaseDelay,
+		MaxDelay:    cfg.DBRetryMaxDelay,
+		OnRetry: func(ctx context.Context, name string, attempt int, err error) {
+			retries.Inc(name)
+			logger.WarnContext(ctx, "db: retrying", "operation", name, "attempt", attempt, "error", err)
+		},
+	})
+
+	guard := newGuard(cfg, store)
+	metrics.RegisterGuard(reg, guard)
+	if b := guard.Breaker(); b != nil {
+		checks.Register("store breaker", b)
+	}
+
+	handlers := notes.NewHandlers(guard).
+		WithHighlight(notes.HighlightOptions{
+			MaxFragments: cfg.SearchMaxFragments,
+			MaxWords:     cfg.SearchMaxWords,
+			MinWords:     cfg.SearchMinWords,
+			StartSel:     cfg.SearchStartSel,
+			StopSel:      cfg.SearchStopSel,
+			Delimiter:    cfg.SearchDelimiter,
+		}).
+		WithRelatedCache(cfg.RelatedCacheTTL, cfg.RelatedCacheSize).
+		WithHealthCheck(checks.Ready)
+	if st.repo != nil {
+		handlers.WithSavedSearches(st.repo)
+	}
+	var corsHandler *cors.CORS
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		corsHandler, err = cors.New(cors.Options{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   cfg.CORSAllowedMethods,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			ExposedHeaders:   cfg.CORSExposedHeaders,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		})
+		if err != nil {
+			logger.Error("startup: cors", "error", err)
+			_ = exporter.Close()
+			_ = st.Close()
+			return exitStartup
+		}
+	}
+
+	router := chi.NewRouter()
+	router.Use(logging.RequestID)
+	router.Use(httpMetrics.Middleware)
+	router.Use(tracer.Middleware)
+	router.Use(logging.AccessLog(logger))
+	router.Use(httpx.Recover)
+	if corsHandler != nil {
+		// Ahead of the middlewares that answer on their own (429, 413, 409,
+		// 503), so browsers can read those responses too; preflight requests
+		// are answered here for every path.
+		router.Use(corsHandler.Handler)
+	}
+	if cfg.SecurityHeaders {
+		headers := httpx.DefaultSecurityHeaders
+		headers.HSTSMaxAge = cfg.HSTSMaxAge
+		router.Use(httpx.SecureHeaders(headers))
+	}
+	if limiter != nil {
+		router.Use(limiter.Middleware)
+	}
+	router.Use(httpx.BodyLimit(cfg.MaxBodyBytes, bodyLimits))
+	if st.cluster != nil {
+		router.Use(st.cluster.ReadYourWrites(cfg.ReadYourWritesTTL))
 	}
-	defer repo.Close()
+	var idem *idempotency.Middleware
+	if st.repo != nil {
+		idem = idempotency.New(idempotency.NewPostgresStore(st.db), cfg.IdempotencyTTL, cfg.IdempotencyLockTimeout).
+			WithClient(ratelimit.Keys(ratelimit.APIKey, ratelimit.IP(cfg.RateLimitTrustProxy)))
+		router.Use(idem.Handler)
+	}
+	router.Use(httpx.Timeout(cfg.RequestTimeout))
+	router.Get("/livez", health.LiveHandler())
+	router.Get("/readyz", checks.ReadyHandler())
+	router.Handle("/metrics", reg.Handler())
+	router.Get("/admin/query-stats", queryStats.Handler())
+	router.Mount("/", handlers.Routes())
 
 	srv := &http.Server{
 		Addr:              cfg.HTTPAddr,
-		Handler:           notes.NewHandlers(repo).Routes(),
-		ReadHeaderTimeout: 5 * time.Second,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	// Shutdown order: background workers, HTTP server (drains in-flight
+	// requests), prepared statements, connection pool, trace exporter.
+	webhooks := &http.Client{
+		Timeout:   cfg.WebhookTimeout,
+		Transport: &tracing.Transport{Tracer: tracer, Base: notes.NewWebhookTransport()},
+	}
+	if st.repo != nil {
+		worker := notes.NewSavedSearchWorker(st.repo, notes.WebhookNotifier{Client: webhooks},
+			cfg.SavedSearchInterval, cfg.SavedSearchLag)
+		lc.Go("saved-search worker", worker.Run)
+	}
+	if limiter != nil {
+		lc.Go("rate limit pruning", limiter.Run)
+	}
+	if st.cluster != nil {
+		lc.Go("replica checks", func(ctx context.Context) { st.cluster.Run(ctx, cfg.ReplicaCheckInterval) })
+	}
+	if idem != nil {
+		lc.Go("idempotency key pruning", idem.Run)
+	}
+	lc.OnShutdown("http server", srv.Shutdown)
+	if st.repo != nil {
+		lc.OnClose("repository", func(context.Context) error { return st.repo.Close() })
+	}
+	lc.OnClose("db pool", func(context.Context) error { return st.closePool() })
+	lc.OnClose("trace exporter", func(context.Context) error { return exporter.Close() })
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Notes API listening", "addr", cfg.HTTPAddr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	code := exitOK
+	select {
+	case err := <-serveErr:
+		logger.Error("http server", "error", err)
+		code = exitServer
+	case <-ctx.Done():
+		// A second signal kills the process immediately.
+		stop()
+		logger.Info("shutdown: draining", "delay", cfg.ShutdownDelay)
+		lc.StartDraining()
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := lc.Shutdown(sctx); err != nil {
+		logger.Error("shutdown", "error", err)
+		if code == exitOK {
+			code = exitShutdown
+		}
+	}
+	logger.Info("shutdown: done", "exit_code", code)
+	return code
+}
+
+func newExporter(cfg config.Config) (tracing.Exporter, error) {
+	switch cfg.TracingExporter {
+	case "", "none":
+		return tracing.NopExporter{}, nil
+	case "stdout":
+		return tracing.NewStdoutExporter(), nil
+	case "file":
+		return tracing.NewFileExporter(cfg.TracingFile)
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", cfg.TracingExporter)
+	}
+}
+
+// newLimiter returns nil if rate limiting is disabled.
+func newLimiter(cfg config.Config, st *storage) (*ratelimit.Limiter, error) {
+	rules, err := ratelimit.ParseRules(cfg.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	var backend ratelimit.Backend
+	switch cfg.RateLimitBackend {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		backend = ratelimit.NewMemoryBackend()
+	case "postgres":
+		if st.repo == nil {
+			return nil, fmt.Errorf("RATE_LIMIT_BACKEND=postgres needs STORAGE_DRIVER=postgres")
+		}
+		backend = ratelimit.NewPostgresBackend(st.db)
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND %q", cfg.RateLimitBackend)
 	}
+	return ratelimit.New(backend, rules, ratelimit.IP(cfg.RateLimitTrustProxy), ratelimit.APIKey), nil
+}
 
-	log.Printf("Notes API listening on %s", cfg.HTTPAddr)
-	log.Fatal(srv.ListenAndServe())
+// newGuard wraps store with the circuit breaker and the adaptive concurrency
+// limit, each unless disabled. The limit starts at the pool size.
+func newGuard(cfg config.Config, store notes.Store) *overload.Guard {
+	var breaker *overload.Breaker
+	if cfg.BreakerFailureThreshold > 0 {
+		breaker = overload.NewBreaker(overload.BreakerOptions{
+			FailureThreshold: cfg.BreakerFailureThreshold,
+			OpenTimeout:      cfg.BreakerOpenTimeout,
+			HalfOpenRequests: cfg.BreakerHalfOpenRequests,
+		})
+	}
+	var limiter *overload.Limiter
+	if cfg.LoadShedding {
+		maxLimit := cfg.ConcurrencyLimitMax
+		if maxLimit <= 0 {
+			maxLimit = 2 * cfg.MaxOpenConns
+		}
+		limiter = overload.NewLimiter(overload.LimiterOptions{
+			Initial:       cfg.MaxOpenConns,
+			Min:           1,
+			Max:           maxLimit,
+			LatencyTarget: cfg.ConcurrencyLatencyTarget,
+			Backoff:       0.9,
+		})
+	}
+	return overload.NewGuard(store, breaker, limiter)
+}
+
+// migrateUp applies pending migrations; concurrent instances wait for each
+// other on an advisory lock.
+func migrateUp(ctx context.Context, db *sql.DB, logger *slog.Logger) error {
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return err
+	}
+	m := migrate.New(db, list)
+	m.Logf = func(format string, args ...any) { logger.Info(fmt.Sprintf(format, args...)) }
+	done, err := m.Up(ctx, 0)
+	if err != nil {
+		return err
+	}
+	logger.Info("migrate: schema up to date", "applied", len(done), "version", m.Latest())
+	return nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"example.com/notes-api-pz14/internal/config"
+	"example.com/notes-api-pz14/internal/db"
+	"example.com/notes-api-pz14/internal/migrate"
+	"example.com/notes-api-pz14/migrations"
+)
+
+const migrateUsage = "usage: api migrate <command>\n\n" + migrate.CommandUsage
+
+// runMigrate implements the migrate subcommand. It exits with 0 on success,
+// 1 on errors and 2 on usage errors.
+func runMigrate(args []string) int {
+	cmd, err := migrate.ParseCommand(args)
+	if err != nil {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	cfg := config.Load()
+	if cfg.DatabaseURL == "" {
+		fmt.Fprintln(os.Stderr, "DATABASE_URL is required")
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	m, closeDB, err := newMigrator(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeDB()
+	m.Logf = func(format string, args ...any) { fmt.Fprintf(os.Stderr, format+"\n", args...) }
+
+	if err := cmd.Run(ctx, m, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func newMigrator(ctx context.Context, cfg config.Config) (*migrate.Migrator, func(), error) {
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := db.Open(ctx, cfg.DatabaseURL, 2, 2, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrate.New(conn.SQL, list), func() { _ = conn.SQL.Close() }, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"example.com/notes-api-pz14/internal/config"
+	"example.com/notes-api-pz14/internal/db"
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/sqlitestore"
+)
+
+// storage is the notes store selected by STORAGE_DRIVER and its database.
+type storage struct {
+	db    *sql.DB
+	store interface {
+		notes.Store
+		AddHook(notes.QueryHook)
+	}
+	// repo is nil with SQLite: saved searches, idempotency keys, the Postgres
+	// rate limit backend, EXPLAIN and the schema check need Postgres.
+	repo *notes.Repository
+	// pool is set with DB_POOL=pgx; db draws connections from it.
+	pool *db.Pool
+	// cluster is set with read replicas; db is its primary.
+	cluster *db.Cluster
+	// system is the db.system attribute of query spans.
+	system string
+}
+
+func openStorage(ctx context.Context, cfg config.Config, logger *slog.Logger) (*storage, error) {
+	switch cfg.StorageDriver {
+	case "", "postgres":
+		return openPostgres(ctx, cfg, logger)
+	case "sqlite":
+		sdb, err := sqlitestore.Open(ctx, cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
+		logger.Info("storage: sqlite", "path", cfg.SQLitePath, "version", sqlitestore.Latest)
+		return &storage{db: sdb, store: sqlitestore.New(sdb), system: "sqlite"}, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", cfg.StorageDriver)
+	}
+}
+
+func openPostgres(ctx context.Context, cfg config.Config, logger *slog.Logger) (*storage, error) {
+	if cfg.DatabaseURL == "" {
+		return nil, errors.New("DATABASE_URL is required")
+	}
+	st := &storage{system: "postgresql"}
+	switch cfg.DBPool {
+	case "", "sql":
+		if len(cfg.DatabaseReplicaURLs) > 0 {
+			cluster, err := db.OpenCluster(ctx, cfg.DatabaseURL, cfg.DatabaseReplicaURLs,
+				cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+			if err != nil {
+				return nil, fmt.Errorf("open database: %w", err)
+			}
+			cluster.Logf = func(format string, args ...any) { logger.Info(fmt.Sprintf(format, args...)) }
+			cluster.Check(ctx)
+			st.db, st.cluster = cluster.Primary, cluster
+			break
+		}
+		dbConn, err := db.Open(ctx, cfg.DatabaseURL, cfg.MaxOpenConns, cfg.MaxIdleConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
+		st.db = dbConn.SQL
+	case "pgx":
+		if len(cfg.DatabaseReplicaURLs) > 0 {
+			return nil, errors.New("DATABASE_REPLICA_URLS needs DB_POOL=sql")
+		}
+		pool, err := db.OpenPool(ctx, cfg.DatabaseURL, cfg.MaxOpenConns, cfg.ConnMaxLifetime, cfg.ConnMaxIdleTime)
+		if err != nil {
+			return nil, fmt.Errorf("open database: %w", err)
+		}
+		st.db, st.pool = pool.SQL, pool
+	default:
+		return nil, fmt.Errorf("unknown DB_POOL %q", cfg.DBPool)
+	}
+
+	if cfg.MigrateOnStart {
+		if err := migrateUp(ctx, st.db, logger); err != nil {
+			_ = st.closePool()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	}
+	repo, err := notes.NewRepository(ctx, st.db)
+	if err != nil {
+		_ = st.closePool()
+		return nil, fmt.Errorf("prepare statements: %w", err)
+	}
+	if st.cluster != nil {
+		repo.WithReplicas(st.cluster)
+	}
+	st.repo = repo
+	st.store = repo
+	if st.pool != nil {
+		st.store = notes.NewPgxRepository(st.pool.Pool)
+	}
+	return st, nil
+}
+
+// AddHook registers h on the notes store and, when they differ, on the
+// repository serving saved searches.
+func (s *storage) AddHook(h notes.QueryHook) {
+	s.store.AddHook(h)
+	if s.repo != nil && notes.Store(s.repo) != s.store {
+		s.repo.AddHook(h)
+	}
+}
+
+// WithRetry sets the retry policy of the Postgres repositories; SQLite has
+// no failovers to ride out.
+func (s *storage) WithRetry(p notes.RetryPolicy) {
+	if s.repo != nil {
+		s.repo.WithRetry(p)
+	}
+	if pr, ok := s.store.(*notes.PgxRepository); ok {
+		pr.WithRetry(p)
+	}
+}
+
+// Close releases prepared statements and the connection pool.
+func (s *storage) Close() error {
+	if s.repo != nil {
+		_ = s.repo.Close()
+	}
+	return s.closePool()
+}
+
+func (s *storage) closePool() error {
+	switch {
+	case s.pool != nil:
+		return s.pool.Close()
+	case s.cluster != nil:
+		return s.cluster.Close()
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// positional lists completions for the arguments of some commands.
+var positional = map[string][]string{
+	"migrate":    {"status", "up", "down", "redo"},
+	"completion": {"bash", "zsh", "fish"},
+}
+
+func runCompletion(_ context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "bash":
+		return writeBash(a.out)
+	case "zsh":
+		// zsh runs the bash script through bashcompinit.
+		if _, err := io.WriteString(a.out, "autoload -U +X bashcompinit && bashcompinit\n"); err != nil {
+			return err
+		}
+		return writeBash(a.out)
+	case "fish":
+		return writeFish(a.out)
+	}
+	return errUsage
+}
+
+func writeBash(w io.Writer) error {
+	var b strings.Builder
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	b.WriteString(`# bash completion for notesctl; load with: source <(notesctl completion bash)
+_notesctl() {
+	local cur prev cmd i
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	case $prev in
+	-o) COMPREPLY=($(compgen -W "` + strings.Join(formats, " ") + `" -- "$cur")); return ;;
+	-lang) COMPREPLY=($(compgen -W "simple english russian" -- "$cur")); return ;;
+	-file) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+	-database-url|-title|-content|-limit|-n|-seed|-title-words|-content-words|-sentence-words|-russian|-tags|-end|-span|-batch|-min-rows) return ;;
+	esac
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		-o|-database-url) ((i++)) ;;
+		-*) ;;
+		*) cmd=${COMP_WORDS[i]}; break ;;
+		esac
+	done
+	case $cmd in
+	"") COMPREPLY=($(compgen -W "-o -database-url ` + strings.Join(names, " ") + `" -- "$cur")) ;;
+`)
+	for _, c := range commands {
+		words := append(append([]string{}, c.flags...), positional[c.name]...)
+		if len(words) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", c.name, strings.Join(words, " "))
+	}
+	b.WriteString(`	esac
+}
+complete -F _notesctl notesctl
+`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFish(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(`# fish completion for notesctl; load with: notesctl completion fish | source
+complete -c notesctl -f
+complete -c notesctl -n __fish_use_subcommand -o o -xa '` + strings.Join(formats, " ") + `' -d 'output format'
+complete -c notesctl -n __fish_use_subcommand -o database-url -x -d 'PostgreSQL connection string'
+`)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c notesctl -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+		cond := fishQuote("__fish_seen_subcommand_from " + c.name)
+		for _, f := range c.flags {
+			opt := strings.TrimPrefix(f, "-")
+			switch opt {
+			case "file":
+				fmt.Fprintf(&b, "complete -c notesctl -n %s -o %s -rF\n", cond, opt)
+			case "lang":
+				fmt.Fprintf(&b, "complete -c notesctl -n %s -o %s -xa 'simple english russian'\n", cond, opt)
+			default:
+				fmt.Fprintf(&b, "complete -c notesctl -n %s -o %s\n", cond, opt)
+			}
+		}
+		if words := positional[c.name]; len(words) > 0 {
+			fmt.Fprintf(&b, "complete -c notesctl -n %s -a %s\n", cond, fishQuote(strings.Join(words, " ")))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
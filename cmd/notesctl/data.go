@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"example.com/notes-api-pz14/internal/migrate"
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/migrations"
+)
+
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	cmd, err := migrate.ParseCommand(args)
+	if err != nil {
+		return errUsage
+	}
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return err
+	}
+	conn, err := a.open(ctx)
+	if err != nil {
+		return err
+	}
+	m := migrate.New(conn, list)
+	m.Logf = func(format string, args ...any) { fmt.Fprintf(a.errOut, format+"\n", args...) }
+	return cmd.Run(ctx, m, a.out)
+}
+
+// exportPage is the page size of export; notes are read newest first.
+const exportPage = 200
+
+// exported is one line of an export file. IDs and creation times are
+// informational: import creates new notes.
+type exported struct {
+	ID        int64     `json:"id,omitempty"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Language  string    `json:"language,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("export")
+	file := fs.String("file", "-", `output file, "-" is stdout`)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	out := a.out
+	if *file != "-" {
+		if f, err = os.Create(*file); err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	count := 0
+	err = eachPage(ctx, store, exportPage, func(page []notes.Note) error {
+		for _, n := range page {
+			line := exported{ID: n.ID, Title: n.Title, Content: n.Content, Language: n.Language, CreatedAt: n.CreatedAt}
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+		count += len(page)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if f != nil {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(a.errOut, "exported %d note(s)\n", count)
+	return nil
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("import")
+	file := fs.String("file", "-", `input file, "-" is stdin`)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	in := a.in
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// Validate the whole file before writing anything.
+	drafts, err := readExport(in)
+	if err != nil {
+		return err
+	}
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+	for i, d := range drafts {
+		if _, err := store.Create(ctx, d.Title, d.Content, d.Language); err != nil {
+			return fmt.Errorf("note %d of %d: %w", i+1, len(drafts), err)
+		}
+	}
+	fmt.Fprintf(a.errOut, "imported %d note(s)\n", len(drafts))
+	return nil
+}
+
+// readExport parses JSON lines written by export. Blank lines are skipped.
+func readExport(r io.Reader) ([]draft, error) {
+	var out []draft
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	for i := 1; ; i++ {
+		var e exported
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		d := draft{Title: e.Title, Content: e.Content, Language: e.Language}
+		if err := d.resolve(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		out = append(out, d)
+	}
+}
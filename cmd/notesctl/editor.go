@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// draft is a note as the user edits it.
+type draft struct {
+	Title    string
+	Content  string
+	Language string
+}
+
+const draftHelp = `# Edit the note below. Lines starting with '#' before the first blank
+# line are ignored; everything after the blank line is the content.
+# Leave Language empty to detect it. An empty title aborts.
+`
+
+// resolve checks required fields and fills in a detected language.
+func (d *draft) resolve() error {
+	if d.Title == "" || d.Content == "" {
+		return errors.New("title and content required")
+	}
+	if d.Language == "" {
+		d.Language = notes.DetectLanguage(d.Title + " " + d.Content)
+	}
+	if !notes.SupportedLanguage(d.Language) {
+		return fmt.Errorf("unsupported language %q", d.Language)
+	}
+	return nil
+}
+
+func formatDraft(d draft) string {
+	var b strings.Builder
+	b.WriteString(draftHelp)
+	fmt.Fprintf(&b, "Title: %s\nLanguage: %s\n\n", d.Title, d.Language)
+	b.WriteString(d.Content)
+	if !strings.HasSuffix(d.Content, "\n") {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func parseDraft(s string) (draft, error) {
+	var d draft
+	sc := bufio.NewScanner(strings.NewReader(s))
+	line := 0
+	for sc.Scan() {
+		text := sc.Text()
+		line++
+		if strings.TrimSpace(text) == "" {
+			break
+		}
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return draft{}, fmt.Errorf("line %d: expected \"Key: value\"", line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			d.Title = value
+		case "language":
+			d.Language = value
+		default:
+			return draft{}, fmt.Errorf("line %d: unknown field %q", line, key)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return draft{}, err
+	}
+
+	// The scanner consumed the header; the rest of s is the content.
+	_, body, _ := cutLines(s, line)
+	d.Content = strings.TrimRight(body, "\n")
+	return d, nil
+}
+
+// cutLines splits s after its first n lines.
+func cutLines(s string, n int) (string, string, bool) {
+	i := 0
+	for ; n > 0; n-- {
+		j := strings.IndexByte(s[i:], '\n')
+		if j < 0 {
+			return s, "", false
+		}
+		i += j + 1
+	}
+	return s[:i], s[i:], true
+}
+
+// edit opens d in the editor and returns the result. An empty title aborts.
+func (a *app) edit(d draft) (draft, error) {
+	f, err := os.CreateTemp("", "notesctl-*.txt")
+	if err != nil {
+		return draft{}, err
+	}
+	path := f.Name()
+	defer os.Remove(path)
+	_, err = f.WriteString(formatDraft(d))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return draft{}, err
+	}
+
+	if err := a.editor(path); err != nil {
+		return draft{}, fmt.Errorf("editor: %w", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return draft{}, err
+	}
+	edited, err := parseDraft(string(b))
+	if err != nil {
+		return draft{}, err
+	}
+	if edited.Title == "" {
+		return draft{}, errors.New("aborted: empty title")
+	}
+	return edited, nil
+}
+
+// runEditor runs $VISUAL, $EDITOR or vi on path. The variable may contain
+// arguments, e.g. "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	argv := strings.Fields(editor)
+	if len(argv) == 0 {
+		argv = []string{"vi"}
+	}
+	cmd := exec.Command(argv[0], append(argv[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
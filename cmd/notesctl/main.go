@@ -0,0 +1,233 @@
+// Command notesctl administers the notes database from the command line.
+//
+//	notesctl [-o table|json|yaml] [-database-url URL] <command> [flags] [args]
+//
+// It talks to PostgreSQL directly through notes.Repository, so it works
+// without a running API server. DATABASE_URL is used when -database-url is
+// not given.
+//
+// Exit codes:
+//
+//	0 - success
+//	1 - the command failed (database error, note not found, bad input file,
+//	    query plan regressions)
+//	2 - usage error
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"example.com/notes-api-pz14/internal/config"
+	"example.com/notes-api-pz14/internal/db"
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+const (
+	exitOK = iota
+	exitFailed
+	exitUsage
+)
+
+// errUsage marks errors caused by malformed command lines. errFlags is a
+// usage error the flag package has already reported.
+var (
+	errUsage = errors.New("usage")
+	errFlags = fmt.Errorf("%w: bad flags", errUsage)
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// flags are completed by the shell completion scripts.
+	flags []string
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+// commands is initialised in init because completion refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"create", "[-title T] [-content C|-] [-lang L]", "create a note (opens $EDITOR without -title)", []string{"-title", "-content", "-lang"}, runCreate},
+		{"get", "ID", "show a note", nil, runGet},
+		{"list", "[-limit N] [-all]", "list notes, newest first", []string{"-limit", "-all"}, runList},
+		{"search", "[-lang L] [-fuzzy] [-limit N] QUERY", "full-text or fuzzy search", []string{"-lang", "-fuzzy", "-limit"}, runSearch},
+		{"edit", "ID", "edit a note in $EDITOR", nil, runEdit},
+		{"delete", "ID...", "delete notes", nil, runDelete},
+		{"migrate", "status|up [version]|down [steps]|redo", "manage the database schema", nil, runMigrate},
+		{"seed", "[-n N] [-seed S] [flags]", "insert generated notes with COPY", seedFlags, runSeed},
+		{"plans", "[-min-rows N]", "check query plans for index regressions", []string{"-min-rows"}, runPlans},
+		{"export", "[-file F]", "write all notes as JSON lines", []string{"-file"}, runExport},
+		{"import", "[-file F]", "create notes from JSON lines", []string{"-file"}, runImport},
+		{"completion", "bash|zsh|fish", "print a shell completion script", nil, runCompletion},
+	}
+}
+
+// formats are the values accepted by -o.
+var formats = []string{"table", "json", "yaml"}
+
+// app carries global options and the lazily opened database.
+type app struct {
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+	format string
+
+	databaseURL string
+	cfg         config.Config
+	db          *sql.DB
+	store       notes.Store
+
+	// editor runs the user's editor on path; see editFile.
+	editor func(path string) error
+}
+
+func main() {
+	cfg := config.Load()
+	a := &app{in: os.Stdin, out: os.Stdout, errOut: os.Stderr, cfg: cfg, editor: runEditor}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := a.main(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+func (a *app) main(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("notesctl", flag.ContinueOnError)
+	fs.SetOutput(a.errOut)
+	fs.StringVar(&a.format, "o", "table", "output format: "+strings.Join(formats, ", "))
+	fs.StringVar(&a.databaseURL, "database-url", a.cfg.DatabaseURL, "PostgreSQL connection string")
+	fs.Usage = func() { a.usage() }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if !slices.Contains(formats, a.format) {
+		fmt.Fprintf(a.errOut, "notesctl: unknown output format %q\n", a.format)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		a.usage()
+		return exitUsage
+	}
+
+	cmd, ok := lookup(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(a.errOut, "notesctl: unknown command %q\n", fs.Arg(0))
+		a.usage()
+		return exitUsage
+	}
+	defer a.close()
+
+	err := cmd.run(ctx, a, fs.Args()[1:])
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errFlags):
+		return exitUsage
+	case errors.Is(err, errUsage):
+		if err != errUsage {
+			fmt.Fprintf(a.errOut, "notesctl %s: %v\n", cmd.name, err)
+		}
+		fmt.Fprintf(a.errOut, "usage: notesctl %s %s\n", cmd.name, cmd.args)
+		return exitUsage
+	default:
+		fmt.Fprintf(a.errOut, "notesctl %s: %v\n", cmd.name, err)
+		return exitFailed
+	}
+}
+
+func (a *app) usage() {
+	fmt.Fprint(a.errOut, "usage: notesctl [-o table|json|yaml] [-database-url URL] <command> [flags] [args]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(a.errOut, "  %-11s %s\n", c.name, c.summary)
+	}
+}
+
+func lookup(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// open connects to the database on first use.
+func (a *app) open(ctx context.Context) (*sql.DB, error) {
+	if a.db != nil {
+		return a.db, nil
+	}
+	if a.databaseURL == "" {
+		return nil, errors.New("DATABASE_URL or -database-url is required")
+	}
+	conn, err := db.Open(ctx, a.databaseURL, 4, 4, a.cfg.ConnMaxLifetime, a.cfg.ConnMaxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+	a.db = conn.SQL
+	return a.db, nil
+}
+
+// notes returns the note store, opening the repository on first use.
+func (a *app) notes(ctx context.Context) (notes.Store, error) {
+	if a.store != nil {
+		return a.store, nil
+	}
+	conn, err := a.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := notes.NewRepository(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	a.store = repo
+	return repo, nil
+}
+
+func (a *app) close() {
+	if c, ok := a.store.(io.Closer); ok {
+		_ = c.Close()
+	}
+	if a.db != nil {
+		_ = a.db.Close()
+	}
+}
+
+// flags returns the flag set of the named command.
+func (a *app) flags(name string) *flag.FlagSet {
+	c, _ := lookup(name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(a.errOut, "usage: notesctl %s %s\n", c.name, c.args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses command flags; malformed flags are usage errors.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errFlags
+	}
+	return nil
+}
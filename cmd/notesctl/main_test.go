@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/seed"
+)
+
+// memNotes keeps notes in a map; List pages newest (highest id) first.
+type memNotes struct {
+	notes.Store
+	byID   map[int64]notes.Note
+	nextID int64
+	lists  []notes.ListParams
+}
+
+func newMemNotes() *memNotes { return &memNotes{byID: map[int64]notes.Note{}} }
+
+func (m *memNotes) Create(_ context.Context, title, content, language string) (notes.Note, error) {
+	m.nextID++
+	n := notes.Note{ID: m.nextID, Title: title, Content: content, Language: language,
+		CreatedAt: time.Date(2026, 1, 1, 0, 0, int(m.nextID), 0, time.UTC)}
+	m.byID[n.ID] = n
+	return n, nil
+}
+
+func (m *memNotes) Get(_ context.Context, id int64) (notes.Note, error) {
+	n, ok := m.byID[id]
+	if !ok {
+		return notes.Note{}, sql.ErrNoRows
+	}
+	return n, nil
+}
+
+func (m *memNotes) Update(_ context.Context, id int64, title, content, language string) (notes.Note, error) {
+	n, ok := m.byID[id]
+	if !ok {
+		return notes.Note{}, sql.ErrNoRows
+	}
+	n.Title, n.Content, n.Language = title, content, language
+	m.byID[id] = n
+	return n, nil
+}
+
+func (m *memNotes) Delete(_ context.Context, id int64) error {
+	if _, ok := m.byID[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.byID, id)
+	return nil
+}
+
+func (m *memNotes) List(_ context.Context, p notes.ListParams) ([]notes.Note, error) {
+	m.lists = append(m.lists, p)
+	var out []notes.Note
+	for _, n := range m.byID {
+		if p.CursorID == nil || n.ID < *p.CursorID {
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if len(out) > p.Limit {
+		out = out[:p.Limit]
+	}
+	return out, nil
+}
+
+type result struct {
+	code        int
+	out, errOut string
+}
+
+func run(t *testing.T, store notes.Store, stdin string, args ...string) result {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	a := &app{
+		in:     strings.NewReader(stdin),
+		out:    &out,
+		errOut: &errOut,
+		store:  store,
+		editor: func(string) error { t.Fatal("unexpected editor"); return nil },
+	}
+	code := a.main(context.Background(), args)
+	return result{code, out.String(), errOut.String()}
+}
+
+func TestNotesctl_Usage(t *testing.T) {
+	store := newMemNotes()
+
+	r := run(t, store, "")
+	require.Equal(t, exitUsage, r.code)
+	require.Contains(t, r.errOut, "commands:")
+
+	r = run(t, store, "", "frobnicate")
+	require.Equal(t, exitUsage, r.code)
+	require.Contains(t, r.errOut, `unknown command "frobnicate"`)
+
+	r = run(t, store, "", "-o", "xml", "list")
+	require.Equal(t, exitUsage, r.code)
+
+	r = run(t, store, "", "get", "abc")
+	require.Equal(t, exitUsage, r.code)
+	require.Contains(t, r.errOut, `invalid id "abc"`)
+	require.Contains(t, r.errOut, "usage: notesctl get ID")
+
+	r = run(t, store, "", "list", "-bogus")
+	require.Equal(t, exitUsage, r.code)
+
+	r = run(t, store, "", "migrate", "sideways")
+	require.Equal(t, exitUsage, r.code)
+
+	r = run(t, store, "", "search")
+	require.Equal(t, exitUsage, r.code)
+}
+
+func TestNotesctl_CRUD(t *testing.T) {
+	store := newMemNotes()
+
+	r := run(t, store, "body from stdin\n", "create", "-title", "Hello", "-content", "-")
+	require.Equal(t, exitOK, r.code, r.errOut)
+	require.Equal(t, "body from stdin\n", store.byID[1].Content)
+	require.Equal(t, notes.LangEnglish, store.byID[1].Language)
+	require.Contains(t, r.out, "Title:     Hello")
+
+	r = run(t, store, "", "create", "-title", "Bad", "-content", "x", "-lang", "klingon")
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, `unsupported language "klingon"`)
+
+	r = run(t, store, "", "-o", "json", "get", "1")
+	require.Equal(t, exitOK, r.code)
+	var n notes.Note
+	require.NoError(t, json.Unmarshal([]byte(r.out), &n))
+	require.Equal(t, "Hello", n.Title)
+
+	r = run(t, store, "", "get", "9")
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, "note 9 not found")
+
+	r = run(t, store, "", "delete", "1", "9")
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, "deleted 1")
+	require.Contains(t, r.errOut, "note 9 not found")
+	require.Empty(t, store.byID)
+}
+
+func TestNotesctl_ListFormats(t *testing.T) {
+	store := newMemNotes()
+	for _, title := range []string{"first", "second   line\nwrapped", "third"} {
+		_, _ = store.Create(context.Background(), title, "content", notes.LangEnglish)
+	}
+
+	r := run(t, store, "", "list", "-limit", "2")
+	require.Equal(t, exitOK, r.code)
+	lines := strings.Split(strings.TrimSpace(r.out), "\n")
+	require.Len(t, lines, 3)
+	require.Regexp(t, `^ID\s+LANGUAGE\s+CREATED\s+TITLE$`, lines[0])
+	require.Regexp(t, `^3\s+english\s+2026-01-01T00:00:03Z\s+third$`, lines[1])
+	require.Regexp(t, `second line wrapped$`, lines[2])
+
+	store.lists = nil
+	r = run(t, store, "", "-o", "yaml", "list", "-all", "-limit", "2")
+	require.Equal(t, exitOK, r.code)
+	require.Contains(t, r.out, "- id: 3\n  title: third\n  content: content\n  language: english\n  created_at: \"2026-01-01T00:00:03Z\"\n")
+	require.Equal(t, 3, strings.Count(r.out, "- id:"))
+	// Two pages and the empty one that ends the walk.
+	require.Len(t, store.lists, 3)
+	require.Nil(t, store.lists[0].CursorID)
+	require.Equal(t, int64(2), *store.lists[1].CursorID)
+	require.Equal(t, int64(1), *store.lists[2].CursorID)
+}
+
+func TestNotesctl_Search(t *testing.T) {
+	var got notes.ListParams
+	r := run(t, searchStore{memNotes: newMemNotes(), list: func(p notes.ListParams) []notes.Note {
+		got = p
+		return []notes.Note{
+			{ID: 4, Title: "Index plan", Highlight: &notes.Highlight{Title: "*Index* plan", Content: "the *index* is used"}},
+			{ID: 5, Title: "Indexes", Content: "fuzzy match", Score: 0.75},
+		}
+	}}, "", "search", "-fuzzy", "-limit", "5", "index", "plan")
+	require.Equal(t, exitOK, r.code, r.errOut)
+	require.Equal(t, "index plan", got.Query)
+	require.True(t, got.Fuzzy)
+	require.Equal(t, 5, got.Limit)
+	require.Equal(t, searchHighlight, got.Highlight)
+	require.Regexp(t, `4\s+-\s+\*Index\* plan\s+the \*index\* is used`, r.out)
+	require.Regexp(t, `5\s+0\.750\s+Indexes\s+fuzzy match`, r.out)
+}
+
+type searchStore struct {
+	*memNotes
+	list func(notes.ListParams) []notes.Note
+}
+
+func (s searchStore) List(_ context.Context, p notes.ListParams) ([]notes.Note, error) {
+	return s.list(p), nil
+}
+
+func TestNotesctl_Edit(t *testing.T) {
+	store := newMemNotes()
+	_, _ = store.Create(context.Background(), "Old", "old body\n", notes.LangEnglish)
+
+	edit := func(replace func(string) string) result {
+		var out, errOut bytes.Buffer
+		a := &app{out: &out, errOut: &errOut, store: store, format: "table"}
+		a.editor = func(path string) error {
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+			return os.WriteFile(path, []byte(replace(string(b))), 0o600)
+		}
+		code := a.main(context.Background(), []string{"edit", "1"})
+		return result{code, out.String(), errOut.String()}
+	}
+
+	r := edit(func(s string) string { return s })
+	require.Equal(t, exitOK, r.code, r.errOut)
+	require.Contains(t, r.errOut, "note unchanged")
+
+	r = edit(func(s string) string {
+		require.Contains(t, s, "Title: Old\nLanguage: english\n\nold body\n")
+		return "Title: Новая\nLanguage:\n\nновый текст\n\n"
+	})
+	require.Equal(t, exitOK, r.code, r.errOut)
+	require.Equal(t, notes.Note{ID: 1, Title: "Новая", Content: "новый текст", Language: notes.LangRussian,
+		CreatedAt: store.byID[1].CreatedAt}, store.byID[1])
+
+	r = edit(func(string) string { return "Title:\n\nbody" })
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, "aborted: empty title")
+
+	r = edit(func(string) string { return "Author: me\n\nbody" })
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, `unknown field "Author"`)
+}
+
+func TestParseDraft(t *testing.T) {
+	d, err := parseDraft("# comment\ntitle:  Spaced  \n\nline 1\n\nline 3\n")
+	require.NoError(t, err)
+	require.Equal(t, draft{Title: "Spaced", Content: "line 1\n\nline 3"}, d)
+
+	// A header without content.
+	d, err = parseDraft("Title: only")
+	require.NoError(t, err)
+	require.Equal(t, draft{Title: "only"}, d)
+
+	_, err = parseDraft("no colon\n\nbody")
+	require.Error(t, err)
+}
+
+func TestNotesctl_ExportImport(t *testing.T) {
+	src := newMemNotes()
+	for i, title := range []string{"one", "два", "three"} {
+		_, _ = src.Create(context.Background(), title, "content "+title, []string{"english", "russian", "simple"}[i])
+	}
+	file := filepath.Join(t.TempDir(), "notes.jsonl")
+
+	r := run(t, src, "", "export", "-file", file)
+	require.Equal(t, exitOK, r.code, r.errOut)
+	require.Contains(t, r.errOut, "exported 3 note(s)")
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, 3, strings.Count(string(b), "\n"))
+
+	dst := newMemNotes()
+	r = run(t, dst, "", "import", "-file", file)
+	require.Equal(t, exitOK, r.code, r.errOut)
+	require.Contains(t, r.errOut, "imported 3 note(s)")
+	for id, n := range src.byID {
+		// Newest first on export, so ids are reversed on import.
+		got := dst.byID[4-id]
+		require.Equal(t, []string{n.Title, n.Content, n.Language}, []string{got.Title, got.Content, got.Language})
+	}
+
+	// Nothing is written when a record is invalid.
+	dst = newMemNotes()
+	r = run(t, dst, `{"title":"ok","content":"x"}`+"\n"+`{"title":"","content":"x"}`, "import")
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, "record 2: title and content required")
+	require.Empty(t, dst.byID)
+
+	r = run(t, dst, `{"title":"ok","content":"x","tags":[]}`, "import")
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, `unknown field "tags"`)
+}
+
+func TestNotesctl_SeedArgs(t *testing.T) {
+	a := &app{errOut: io.Discard}
+	cfg, n, opts, err := a.seedArgs([]string{"-n", "5000", "-seed", "7", "-content-words", "normal:40,10",
+		"-russian", "0.5", "-end", "2026-01-02T03:04:05Z", "-span", "48h", "-batch", "500", "-analyze=false"})
+	require.NoError(t, err)
+	require.Equal(t, int64(5000), n)
+	require.Equal(t, uint64(7), cfg.Seed)
+	require.Equal(t, seed.Normal(40, 10), cfg.ContentWords)
+	require.Equal(t, seed.Uniform(2, 8), cfg.TitleWords)
+	require.Equal(t, 0.5, cfg.RussianShare)
+	require.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), cfg.End)
+	require.Equal(t, 48*time.Hour, cfg.Span)
+	require.Equal(t, seed.Options{BatchSize: 500}, opts)
+
+	for _, args := range [][]string{
+		{"-n", "0"}, {"-batch", "0"}, {"-tags", "3-1"}, {"-russian", "2"}, {"-end", "yesterday"}, {"extra"},
+	} {
+		_, _, _, err := a.seedArgs(args)
+		require.ErrorIs(t, err, errUsage, args)
+	}
+
+	r := run(t, nil, "", "seed", "-n", "10")
+	require.Equal(t, exitFailed, r.code)
+	require.Contains(t, r.errOut, "DATABASE_URL or -database-url is required")
+}
+
+func TestNotesctl_Completion(t *testing.T) {
+	r := run(t, nil, "", "completion", "bash")
+	require.Equal(t, exitOK, r.code)
+	require.Contains(t, r.out, "complete -F _notesctl notesctl")
+	for _, c := range commands {
+		require.Contains(t, r.out, " "+c.name)
+	}
+	require.Contains(t, r.out, `migrate) COMPREPLY=($(compgen -W "status up down redo" -- "$cur")) ;;`)
+
+	r = run(t, nil, "", "completion", "zsh")
+	require.Equal(t, exitOK, r.code)
+	require.True(t, strings.HasPrefix(r.out, "autoload -U +X bashcompinit"))
+
+	r = run(t, nil, "", "completion", "fish")
+	require.Equal(t, exitOK, r.code)
+	require.Contains(t, r.out, "complete -c notesctl -n __fish_use_subcommand -a edit -d 'edit a note in $EDITOR'")
+	require.Contains(t, r.out, "complete -c notesctl -n '__fish_seen_subcommand_from export' -o file -rF")
+
+	require.Equal(t, exitUsage, run(t, nil, "", "completion", "tcsh").code)
+}
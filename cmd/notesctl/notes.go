@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// searchHighlight marks matches in search snippets for terminal output.
+var searchHighlight = notes.HighlightOptions{StartSel: "*", StopSel: "*"}
+
+func runCreate(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("create")
+	title := fs.String("title", "", "note title")
+	content := fs.String("content", "", `note content, "-" reads it from stdin`)
+	lang := fs.String("lang", "", "text search language (detected when empty)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	d := draft{Title: *title, Content: *content, Language: *lang}
+	if d.Content == "-" {
+		b, err := io.ReadAll(a.in)
+		if err != nil {
+			return err
+		}
+		d.Content = string(b)
+	}
+	if d.Title == "" {
+		edited, err := a.edit(d)
+		if err != nil {
+			return err
+		}
+		d = edited
+	}
+	if err := d.resolve(); err != nil {
+		return err
+	}
+
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := store.Create(ctx, d.Title, d.Content, d.Language)
+	if err != nil {
+		return err
+	}
+	return a.printNote(n)
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := get(ctx, store, id)
+	if err != nil {
+		return err
+	}
+	return a.printNote(n)
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("list")
+	limit := fs.Int("limit", 20, "number of notes (1-200)")
+	all := fs.Bool("all", false, "list every note, page by page")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !*all {
+		items, err := store.List(ctx, notes.ListParams{Limit: *limit})
+		if err != nil {
+			return err
+		}
+		return a.printNotes(items)
+	}
+	var items []notes.Note
+	err = eachPage(ctx, store, *limit, func(page []notes.Note) error {
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return a.printNotes(items)
+}
+
+func runSearch(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("search")
+	lang := fs.String("lang", "", "text search language (detected when empty)")
+	fuzzy := fs.Bool("fuzzy", false, "typo-tolerant trigram search")
+	limit := fs.Int("limit", 20, "number of results (1-200)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	q := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if q == "" {
+		return errUsage
+	}
+	if *lang != "" && !notes.SupportedLanguage(*lang) {
+		return fmt.Errorf("unsupported language %q", *lang)
+	}
+
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+	items, err := store.List(ctx, notes.ListParams{
+		Limit:     *limit,
+		Query:     q,
+		Language:  *lang,
+		Fuzzy:     *fuzzy,
+		Highlight: searchHighlight,
+	})
+	if err != nil {
+		return err
+	}
+	return a.printSearch(items)
+}
+
+func runEdit(ctx context.Context, a *app, args []string) error {
+	id, err := oneID(args)
+	if err != nil {
+		return err
+	}
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := get(ctx, store, id)
+	if err != nil {
+		return err
+	}
+
+	before := draft{Title: n.Title, Content: n.Content, Language: n.Language}
+	after, err := a.edit(before)
+	if err != nil {
+		return err
+	}
+	// Compare with what the editor saw, so trailing newlines don't count.
+	if shown, _ := parseDraft(formatDraft(before)); after == shown {
+		fmt.Fprintln(a.errOut, "note unchanged")
+		return nil
+	}
+	if err := after.resolve(); err != nil {
+		return err
+	}
+	n, err = store.Update(ctx, id, after.Title, after.Content, after.Language)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("note %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	return a.printNote(n)
+}
+
+func runDelete(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	ids := make([]int64, 0, len(args))
+	for _, s := range args {
+		id, err := parseID(s)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	store, err := a.notes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err := store.Delete(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("note %d not found", id)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.errOut, "deleted %d\n", id)
+	}
+	return nil
+}
+
+// get loads a note, turning sql.ErrNoRows into a readable error.
+func get(ctx context.Context, store notes.Store, id int64) (notes.Note, error) {
+	n, err := store.Get(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notes.Note{}, fmt.Errorf("note %d not found", id)
+	}
+	return n, err
+}
+
+// eachPage walks all notes newest first with keyset pagination.
+func eachPage(ctx context.Context, store notes.Store, size int, fn func([]notes.Note) error) error {
+	p := notes.ListParams{Limit: size}
+	for {
+		page, err := store.List(ctx, p)
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		last := page[len(page)-1]
+		p.CursorCreatedAt, p.CursorID = &last.CreatedAt, &last.ID
+	}
+}
+
+func oneID(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	return parseID(args[0])
+}
+
+func parseID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: invalid id %q", errUsage, s)
+	}
+	return id, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// print writes v in the selected output format; table renders the table
+// format.
+func (a *app) print(v any, table func(w io.Writer)) error {
+	switch a.format {
+	case "json":
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		return writeYAML(a.out, v)
+	}
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// writeYAML encodes v with its JSON field names: it is marshalled to JSON
+// and decoded into a yaml.Node, which keeps the field order.
+func writeYAML(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle drops the flow style and quoting JSON input decodes with.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+func (a *app) printNote(n notes.Note) error {
+	return a.print(n, func(w io.Writer) {
+		fmt.Fprintf(w, "ID:\t%d\n", n.ID)
+		fmt.Fprintf(w, "Title:\t%s\n", n.Title)
+		fmt.Fprintf(w, "Language:\t%s\n", n.Language)
+		fmt.Fprintf(w, "Created:\t%s\n", n.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(w, "\n%s\n", n.Content)
+	})
+}
+
+func (a *app) printNotes(items []notes.Note) error {
+	return a.print(items, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tLANGUAGE\tCREATED\tTITLE")
+		for _, n := range items {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", n.ID, n.Language, n.CreatedAt.Format(time.RFC3339), oneLine(n.Title, 60))
+		}
+	})
+}
+
+func (a *app) printSearch(items []notes.Note) error {
+	return a.print(items, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tSCORE\tTITLE\tSNIPPET")
+		for _, n := range items {
+			title, snippet := n.Title, n.Content
+			if n.Highlight != nil {
+				title, snippet = n.Highlight.Title, n.Highlight.Content
+			}
+			score := "-"
+			if n.Score != 0 {
+				score = fmt.Sprintf("%.3f", n.Score)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", n.ID, score, oneLine(title, 40), oneLine(snippet, 80))
+		}
+	})
+}
+
+// oneLine collapses whitespace so s fits a table cell of at most width runes.
+func oneLine(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s
+}
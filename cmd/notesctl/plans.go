@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"example.com/notes-api-pz14/internal/plancheck"
+)
+
+func runPlans(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("plans")
+	minRows := fs.Int64("min-rows", plancheck.DefaultMinRows, "refuse to check plans of a smaller notes table")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	conn, err := a.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	rep, err := plancheck.CheckRepository(ctx, conn, *minRows)
+	if err != nil {
+		return err
+	}
+	if err := a.print(rep, func(w io.Writer) { _ = rep.WriteText(w) }); err != nil {
+		return err
+	}
+	if !rep.OK() {
+		return fmt.Errorf("%d plan regression(s)", rep.Failed)
+	}
+	return nil
+}
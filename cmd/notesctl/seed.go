@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"example.com/notes-api-pz14/internal/seed"
+)
+
+var seedFlags = []string{"-n", "-seed", "-title-words", "-content-words", "-sentence-words",
+	"-russian", "-tags", "-end", "-span", "-batch", "-analyze"}
+
+// distValue is a flag.Value for seed.Dist.
+type distValue struct{ d *seed.Dist }
+
+func (v distValue) String() string {
+	if v.d == nil {
+		return ""
+	}
+	return v.d.String()
+}
+
+func (v distValue) Set(s string) error {
+	d, err := seed.ParseDist(s)
+	if err != nil {
+		return err
+	}
+	*v.d = d
+	return nil
+}
+
+// seedArgs parses the flags of seed into the generator configuration, the
+// number of notes and the load options.
+func (a *app) seedArgs(args []string) (seed.Config, int64, seed.Options, error) {
+	cfg := seed.DefaultConfig()
+	opts := seed.Options{}
+
+	fs := a.flags("seed")
+	n := fs.Int64("n", 1000, "number of notes")
+	fs.Uint64Var(&cfg.Seed, "seed", cfg.Seed, "random seed; the same seed and -end generate the same notes")
+	fs.Var(distValue{&cfg.TitleWords}, "title-words", "title length in words: N, MIN-MAX, normal:MEAN,STDDEV or lognormal:MEDIAN,SIGMA")
+	fs.Var(distValue{&cfg.ContentWords}, "content-words", "content length in words")
+	fs.Var(distValue{&cfg.SentenceWords}, "sentence-words", "sentence length in words")
+	fs.Var(distValue{&cfg.Tags}, "tags", "#hashtags per note")
+	fs.Float64Var(&cfg.RussianShare, "russian", cfg.RussianShare, "fraction of Russian notes")
+	end := fs.String("end", "", "newest created_at, RFC 3339 (default now)")
+	fs.DurationVar(&cfg.Span, "span", cfg.Span, "created_at is spread over this period before -end")
+	fs.IntVar(&opts.BatchSize, "batch", seed.DefaultBatchSize, "rows per COPY transaction")
+	fs.BoolVar(&opts.Analyze, "analyze", true, "run ANALYZE notes afterwards")
+	if err := parse(fs, args); err != nil {
+		return seed.Config{}, 0, seed.Options{}, err
+	}
+	if fs.NArg() != 0 || *n <= 0 || opts.BatchSize <= 0 {
+		return seed.Config{}, 0, seed.Options{}, errUsage
+	}
+	if *end != "" {
+		t, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			return seed.Config{}, 0, seed.Options{}, fmt.Errorf("%w: invalid -end: %v", errUsage, err)
+		}
+		cfg.End = t
+	}
+	if err := cfg.Validate(); err != nil {
+		return seed.Config{}, 0, seed.Options{}, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return cfg, *n, opts, nil
+}
+
+func runSeed(ctx context.Context, a *app, args []string) error {
+	cfg, n, opts, err := a.seedArgs(args)
+	if err != nil {
+		return err
+	}
+	conn, err := a.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	opts.Progress = func(s seed.Stats) {
+		fmt.Fprintf(a.errOut, "%5.1f%% %d/%d rows, %.0f rows/s, %.1f MB/s, eta %s\n",
+			100*float64(s.Rows)/float64(s.Total), s.Rows, s.Total, s.RowsPerSec(), s.MBPerSec(), s.ETA().Round(time.Second))
+	}
+	st, err := seed.Load(ctx, conn, seed.NewGenerator(cfg), n, opts)
+	if err != nil {
+		return fmt.Errorf("after %d rows: %w", st.Rows, err)
+	}
+	return a.print(seedReport{
+		Rows:       st.Rows,
+		Bytes:      st.Bytes,
+		Seconds:    st.Elapsed.Seconds(),
+		RowsPerSec: st.RowsPerSec(),
+		MBPerSec:   st.MBPerSec(),
+	}, func(w io.Writer) {
+		fmt.Fprintf(w, "seeded %d notes (%.1f MB) in %s: %.0f rows/s, %.1f MB/s\n",
+			st.Rows, float64(st.Bytes)/(1<<20), st.Elapsed.Round(time.Millisecond), st.RowsPerSec(), st.MBPerSec())
+	})
+}
+
+type seedReport struct {
+	Rows       int64   `json:"rows"`
+	Bytes      int64   `json:"bytes"`
+	Seconds    float64 `json:"seconds"`
+	RowsPerSec float64 `json:"rows_per_sec"`
+	MBPerSec   float64 `json:"mb_per_sec"`
+}
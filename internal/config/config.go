@@ -3,28 +3,227 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
+	// StorageDriver selects the notes store: "postgres" (DatabaseURL) or
+	// "sqlite" (the file at SQLitePath, migrated on start).
+	StorageDriver string
+	SQLitePath    string
+
 	DatabaseURL string
 
+	// DBPool is the Postgres client: "sql" (database/sql over pgx) or "pgx"
+	// (a native pgxpool, which has no MaxIdleConns).
+	DBPool          string
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
 
+	// Read replicas serve Get, List and BatchGet (database/sql pool only).
+	// Replicas are checked every ReplicaCheckInterval; after a write, the
+	// client reads what it wrote for ReadYourWritesTTL (see
+	// db.Cluster.ReadYourWrites).
+	DatabaseReplicaURLs  []string
+	ReplicaCheckInterval time.Duration
+	ReadYourWritesTTL    time.Duration
+
+	// Statements and transactions that fail for a transient reason (a
+	// failover, a serialization failure) run up to DBRetryAttempts times, with
+	// exponential backoff from DBRetryBaseDelay up to DBRetryMaxDelay.
+	DBRetryAttempts  int
+	DBRetryBaseDelay time.Duration
+	DBRetryMaxDelay  time.Duration
+
 	HTTPAddr string
+
+	// MigrateOnStart applies pending migrations before the server starts.
+	MigrateOnStart bool
+
+	// HTTP server timeouts and hardening. RequestTimeout is the deadline of
+	// every request context (and so of its repository calls); MaxBodyBytes
+	// can be overridden per route with MaxBodyBytesRoutes, see httpx.ParseSizes.
+	ReadHeaderTimeout  time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	IdleTimeout        time.Duration
+	RequestTimeout     time.Duration
+	MaxBodyBytes       int64
+	MaxBodyBytesRoutes string
+	SecurityHeaders    bool
+	HSTSMaxAge         time.Duration
+
+	// CORS for browser clients; no allowed origins disables it. Origins may be
+	// exact, "https://*.example.com" or "*" (not with CORSAllowCredentials).
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSExposedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// Logging: level is debug, info, warn or error; format is json or text.
+	LogLevel  string
+	LogFormat string
+
+	// Graceful shutdown: readiness fails for ShutdownDelay before the server
+	// stops accepting connections; everything must stop within ShutdownTimeout.
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+
+	// Readiness probe: per-check timeout and whether the schema version is checked.
+	ReadyCheckTimeout time.Duration
+	ReadyCheckSchema  bool
+
+	// Search result highlighting (ts_headline options).
+	SearchMaxFragments int
+	SearchMaxWords     int
+	SearchMinWords     int
+	SearchStartSel     string
+	SearchStopSel      string
+	SearchDelimiter    string
+
+	// Related-notes result cache.
+	RelatedCacheTTL  time.Duration
+	RelatedCacheSize int
+
+	// Saved searches notification worker.
+	SavedSearchInterval time.Duration
+	SavedSearchLag      time.Duration
+	WebhookTimeout      time.Duration
+
+	// Slow query log: statements slower than SlowQueryThreshold are logged;
+	// with SlowQueryExplain their plan is logged too, once per interval.
+	SlowQueryThreshold       time.Duration
+	SlowQueryExplain         bool
+	SlowQueryExplainTimeout  time.Duration
+	SlowQueryExplainInterval time.Duration
+
+	// Rate limiting: backend is "none", "memory" or "postgres"; RateLimits
+	// holds per-route rules, see ratelimit.ParseRules.
+	RateLimitBackend    string
+	RateLimits          string
+	RateLimitTrustProxy bool
+
+	// Overload protection of the notes store, see package overload. The
+	// breaker opens after BreakerFailureThreshold failures in a row (0
+	// disables it). With LoadShedding, store calls beyond an adaptive limit
+	// get 503; the limit starts at DB_MAX_OPEN and grows up to
+	// ConcurrencyLimitMax (0: twice DB_MAX_OPEN) while calls finish within
+	// ConcurrencyLatencyTarget.
+	BreakerFailureThreshold  int
+	BreakerOpenTimeout       time.Duration
+	BreakerHalfOpenRequests  int
+	LoadShedding             bool
+	ConcurrencyLimitMax      int
+	ConcurrencyLatencyTarget time.Duration
+
+	// Idempotency keys are kept for IdempotencyTTL; a key whose request did
+	// not finish within IdempotencyLockTimeout can be reused.
+	IdempotencyTTL         time.Duration
+	IdempotencyLockTimeout time.Duration
+
+	// Tracing: exporter is "none", "stdout" or "file" (JSON lines at TracingFile).
+	TracingExporter    string
+	TracingFile        string
+	TracingService     string
+	TracingSampleRatio float64
 }
 
 func Load() Config {
 	return Config{
+		StorageDriver: getenv("STORAGE_DRIVER", "postgres"),
+		SQLitePath:    getenv("SQLITE_PATH", "notes.db"),
+
 		DatabaseURL:     getenv("DATABASE_URL", ""),
+		DBPool:          getenv("DB_POOL", "sql"),
 		MaxOpenConns:    getenvInt("DB_MAX_OPEN", 20),
 		MaxIdleConns:    getenvInt("DB_MAX_IDLE", 10),
 		ConnMaxLifetime: getenvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
 		ConnMaxIdleTime: getenvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
-		HTTPAddr:        getenv("HTTP_ADDR", ":8080"),
+
+		DatabaseReplicaURLs:  getenvList("DATABASE_REPLICA_URLS", nil),
+		ReplicaCheckInterval: getenvDuration("REPLICA_CHECK_INTERVAL", 2*time.Second),
+		ReadYourWritesTTL:    getenvDuration("READ_YOUR_WRITES_TTL", 30*time.Second),
+
+		DBRetryAttempts:  getenvInt("DB_RETRY_ATTEMPTS", 3),
+		DBRetryBaseDelay: getenvDuration("DB_RETRY_BASE_DELAY", 50*time.Millisecond),
+		DBRetryMaxDelay:  getenvDuration("DB_RETRY_MAX_DELAY", time.Second),
+
+		HTTPAddr: getenv("HTTP_ADDR", ":8080"),
+
+		MigrateOnStart: getenvBool("MIGRATE_ON_START", false),
+
+		ReadHeaderTimeout:  getenvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:        getenvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:       getenvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:        getenvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		RequestTimeout:     getenvDuration("HTTP_REQUEST_TIMEOUT", 20*time.Second),
+		MaxBodyBytes:       int64(getenvInt("HTTP_MAX_BODY_BYTES", 1<<20)),
+		MaxBodyBytesRoutes: getenv("HTTP_MAX_BODY_BYTES_ROUTES", "POST /notes/batch=64KiB"),
+		SecurityHeaders:    getenvBool("HTTP_SECURITY_HEADERS", true),
+		HSTSMaxAge:         getenvDuration("HTTP_HSTS_MAX_AGE", 0),
+
+		CORSAllowedOrigins: getenvList("CORS_ALLOWED_ORIGINS", nil),
+		CORSAllowedMethods: getenvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE"}),
+		CORSAllowedHeaders: getenvList("CORS_ALLOWED_HEADERS",
+			[]string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key", "X-Request-ID", "X-Notes-LSN"}),
+		CORSExposedHeaders: getenvList("CORS_EXPOSED_HEADERS",
+			[]string{"ETag", "Link", "Location", "X-Request-ID", "X-Cache", "Idempotent-Replayed",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After",
+				"X-Notes-LSN"}),
+		CORSAllowCredentials: getenvBool("CORS_ALLOW_CREDENTIALS", false),
+		CORSMaxAge:           getenvDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		LogLevel:  getenv("LOG_LEVEL", "info"),
+		LogFormat: getenv("LOG_FORMAT", "json"),
+
+		ShutdownDelay:   getenvDuration("SHUTDOWN_DELAY", 5*time.Second),
+		ShutdownTimeout: getenvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		ReadyCheckTimeout: getenvDuration("READY_CHECK_TIMEOUT", 2*time.Second),
+		ReadyCheckSchema:  getenvBool("READY_CHECK_SCHEMA", true),
+
+		SearchMaxFragments: getenvInt("SEARCH_HL_MAX_FRAGMENTS", 2),
+		SearchMaxWords:     getenvInt("SEARCH_HL_MAX_WORDS", 20),
+		SearchMinWords:     getenvInt("SEARCH_HL_MIN_WORDS", 5),
+		SearchStartSel:     getenv("SEARCH_HL_START_SEL", "<b>"),
+		SearchStopSel:      getenv("SEARCH_HL_STOP_SEL", "</b>"),
+		SearchDelimiter:    getenv("SEARCH_HL_DELIMITER", " ... "),
+
+		RelatedCacheTTL:  getenvDuration("RELATED_CACHE_TTL", time.Minute),
+		RelatedCacheSize: getenvInt("RELATED_CACHE_SIZE", 1000),
+
+		SavedSearchInterval: getenvDuration("SAVED_SEARCH_INTERVAL", 30*time.Second),
+		SavedSearchLag:      getenvDuration("SAVED_SEARCH_LAG", 5*time.Second),
+		WebhookTimeout:      getenvDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+
+		SlowQueryThreshold:       getenvDuration("SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+		SlowQueryExplain:         getenvBool("SLOW_QUERY_EXPLAIN", false),
+		SlowQueryExplainTimeout:  getenvDuration("SLOW_QUERY_EXPLAIN_TIMEOUT", 5*time.Second),
+		SlowQueryExplainInterval: getenvDuration("SLOW_QUERY_EXPLAIN_INTERVAL", time.Minute),
+
+		RateLimitBackend:    getenv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimits:          getenv("RATE_LIMITS", "POST /notes=60/m:20,POST /notes/batch=30/m:10"),
+		RateLimitTrustProxy: getenvBool("RATE_LIMIT_TRUST_PROXY", false),
+
+		BreakerFailureThreshold:  getenvInt("BREAKER_FAILURE_THRESHOLD", 10),
+		BreakerOpenTimeout:       getenvDuration("BREAKER_OPEN_TIMEOUT", 5*time.Second),
+		BreakerHalfOpenRequests:  getenvInt("BREAKER_HALF_OPEN_REQUESTS", 3),
+		LoadShedding:             getenvBool("LOAD_SHEDDING", true),
+		ConcurrencyLimitMax:      getenvInt("CONCURRENCY_LIMIT_MAX", 0),
+		ConcurrencyLatencyTarget: getenvDuration("CONCURRENCY_LATENCY_TARGET", 500*time.Millisecond),
+
+		IdempotencyTTL:         getenvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyLockTimeout: getenvDuration("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+
+		TracingExporter:    getenv("TRACING_EXPORTER", "none"),
+		TracingFile:        getenv("TRACING_FILE", "traces.jsonl"),
+		TracingService:     getenv("TRACING_SERVICE", "notes-api"),
+		TracingSampleRatio: getenvFloat("TRACING_SAMPLE_RATIO", 1),
 	}
 }
 
@@ -36,6 +235,21 @@ func getenv(key, def string) string {
 	return v
 }
 
+// getenvList splits a comma separated value, dropping empty items.
+func getenvList(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 func getenvInt(key string, def int) int {
 	v := os.Getenv(key)
 	if v == "" {
@@ -48,6 +262,18 @@ func getenvInt(key string, def int) int {
 	return i
 }
 
+func getenvFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
 func getenvDuration(key string, def time.Duration) time.Duration {
 	v := os.Getenv(key)
 	if v == "" {
@@ -59,3 +285,15 @@ func getenvDuration(key string, def time.Duration) time.Duration {
 	}
 	return d
 }
+
+func getenvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
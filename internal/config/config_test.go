@@ -13,12 +13,72 @@ func TestLoad_Defaults(t *testing.T) {
 	os.Clearenv()
 
 	cfg := Load()
+	require.Equal(t, "postgres", cfg.StorageDriver)
+	require.Equal(t, "notes.db", cfg.SQLitePath)
 	require.Equal(t, "", cfg.DatabaseURL)
+	require.Equal(t, "sql", cfg.DBPool)
+	require.Empty(t, cfg.DatabaseReplicaURLs)
+	require.Equal(t, 2*time.Second, cfg.ReplicaCheckInterval)
+	require.Equal(t, 30*time.Second, cfg.ReadYourWritesTTL)
+	require.Equal(t, 3, cfg.DBRetryAttempts)
+	require.Equal(t, 50*time.Millisecond, cfg.DBRetryBaseDelay)
+	require.Equal(t, time.Second, cfg.DBRetryMaxDelay)
 	require.Equal(t, 20, cfg.MaxOpenConns)
 	require.Equal(t, 10, cfg.MaxIdleConns)
 	require.Equal(t, 30*time.Minute, cfg.ConnMaxLifetime)
 	require.Equal(t, 5*time.Minute, cfg.ConnMaxIdleTime)
 	require.Equal(t, ":8080", cfg.HTTPAddr)
+	require.False(t, cfg.MigrateOnStart)
+	require.Equal(t, 5*time.Second, cfg.ReadHeaderTimeout)
+	require.Equal(t, 15*time.Second, cfg.ReadTimeout)
+	require.Equal(t, 30*time.Second, cfg.WriteTimeout)
+	require.Equal(t, 2*time.Minute, cfg.IdleTimeout)
+	require.Equal(t, 20*time.Second, cfg.RequestTimeout)
+	require.Equal(t, int64(1<<20), cfg.MaxBodyBytes)
+	require.Equal(t, "POST /notes/batch=64KiB", cfg.MaxBodyBytesRoutes)
+	require.True(t, cfg.SecurityHeaders)
+	require.Equal(t, time.Duration(0), cfg.HSTSMaxAge)
+	require.Empty(t, cfg.CORSAllowedOrigins)
+	require.Equal(t, []string{"GET", "POST", "PUT", "DELETE"}, cfg.CORSAllowedMethods)
+	require.Contains(t, cfg.CORSAllowedHeaders, "Idempotency-Key")
+	require.Contains(t, cfg.CORSExposedHeaders, "ETag")
+	require.False(t, cfg.CORSAllowCredentials)
+	require.Equal(t, 10*time.Minute, cfg.CORSMaxAge)
+	require.Equal(t, "info", cfg.LogLevel)
+	require.Equal(t, "json", cfg.LogFormat)
+	require.Equal(t, 5*time.Second, cfg.ShutdownDelay)
+	require.Equal(t, 30*time.Second, cfg.ShutdownTimeout)
+	require.Equal(t, 2*time.Second, cfg.ReadyCheckTimeout)
+	require.True(t, cfg.ReadyCheckSchema)
+	require.Equal(t, 2, cfg.SearchMaxFragments)
+	require.Equal(t, 20, cfg.SearchMaxWords)
+	require.Equal(t, 5, cfg.SearchMinWords)
+	require.Equal(t, "<b>", cfg.SearchStartSel)
+	require.Equal(t, "</b>", cfg.SearchStopSel)
+	require.Equal(t, time.Minute, cfg.RelatedCacheTTL)
+	require.Equal(t, 1000, cfg.RelatedCacheSize)
+	require.Equal(t, 30*time.Second, cfg.SavedSearchInterval)
+	require.Equal(t, 5*time.Second, cfg.SavedSearchLag)
+	require.Equal(t, 5*time.Second, cfg.WebhookTimeout)
+	require.Equal(t, 200*time.Millisecond, cfg.SlowQueryThreshold)
+	require.False(t, cfg.SlowQueryExplain)
+	require.Equal(t, 5*time.Second, cfg.SlowQueryExplainTimeout)
+	require.Equal(t, time.Minute, cfg.SlowQueryExplainInterval)
+	require.Equal(t, "memory", cfg.RateLimitBackend)
+	require.Equal(t, "POST /notes=60/m:20,POST /notes/batch=30/m:10", cfg.RateLimits)
+	require.False(t, cfg.RateLimitTrustProxy)
+	require.Equal(t, 10, cfg.BreakerFailureThreshold)
+	require.Equal(t, 5*time.Second, cfg.BreakerOpenTimeout)
+	require.Equal(t, 3, cfg.BreakerHalfOpenRequests)
+	require.True(t, cfg.LoadShedding)
+	require.Equal(t, 0, cfg.ConcurrencyLimitMax)
+	require.Equal(t, 500*time.Millisecond, cfg.ConcurrencyLatencyTarget)
+	require.Equal(t, 24*time.Hour, cfg.IdempotencyTTL)
+	require.Equal(t, time.Minute, cfg.IdempotencyLockTimeout)
+	require.Equal(t, "none", cfg.TracingExporter)
+	require.Equal(t, "traces.jsonl", cfg.TracingFile)
+	require.Equal(t, "notes-api", cfg.TracingService)
+	require.Equal(t, 1.0, cfg.TracingSampleRatio)
 }
 
 func TestLoad_OverridesAndInvalidValues(t *testing.T) {
@@ -26,19 +86,39 @@ func TestLoad_OverridesAndInvalidValues(t *testing.T) {
 
 	t.Run("valid overrides", func(t *testing.T) {
 		os.Setenv("DATABASE_URL", "postgres://u:p@localhost:5432/db?sslmode=disable")
+		os.Setenv("DB_POOL", "pgx")
+		os.Setenv("DATABASE_REPLICA_URLS", "postgres://r1/db,postgres://r2/db")
 		os.Setenv("DB_MAX_OPEN", "5")
 		os.Setenv("DB_MAX_IDLE", "2")
 		os.Setenv("DB_CONN_MAX_LIFETIME", "1m")
 		os.Setenv("DB_CONN_MAX_IDLE_TIME", "10s")
+		os.Setenv("DB_RETRY_ATTEMPTS", "1")
+		os.Setenv("DB_RETRY_MAX_DELAY", "250ms")
+		os.Setenv("BREAKER_FAILURE_THRESHOLD", "0")
+		os.Setenv("LOAD_SHEDDING", "false")
+		os.Setenv("CONCURRENCY_LIMIT_MAX", "64")
 		os.Setenv("HTTP_ADDR", ":9999")
+		os.Setenv("CORS_ALLOWED_ORIGINS", " https://app.example.com, ,https://*.example.org")
+		os.Setenv("STORAGE_DRIVER", "sqlite")
+		os.Setenv("SQLITE_PATH", "/var/lib/notes/notes.db")
 
 		cfg := Load()
 		require.Equal(t, "postgres://u:p@localhost:5432/db?sslmode=disable", cfg.DatabaseURL)
+		require.Equal(t, "pgx", cfg.DBPool)
+		require.Equal(t, []string{"postgres://r1/db", "postgres://r2/db"}, cfg.DatabaseReplicaURLs)
 		require.Equal(t, 5, cfg.MaxOpenConns)
 		require.Equal(t, 2, cfg.MaxIdleConns)
 		require.Equal(t, time.Minute, cfg.ConnMaxLifetime)
 		require.Equal(t, 10*time.Second, cfg.ConnMaxIdleTime)
+		require.Equal(t, 1, cfg.DBRetryAttempts)
+		require.Equal(t, 250*time.Millisecond, cfg.DBRetryMaxDelay)
+		require.Equal(t, 0, cfg.BreakerFailureThreshold)
+		require.False(t, cfg.LoadShedding)
+		require.Equal(t, 64, cfg.ConcurrencyLimitMax)
 		require.Equal(t, ":9999", cfg.HTTPAddr)
+		require.Equal(t, []string{"https://app.example.com", "https://*.example.org"}, cfg.CORSAllowedOrigins)
+		require.Equal(t, "sqlite", cfg.StorageDriver)
+		require.Equal(t, "/var/lib/notes/notes.db", cfg.SQLitePath)
 	})
 
 	t.Run("invalid numbers fall back to defaults", func(t *testing.T) {
@@ -47,11 +127,15 @@ func TestLoad_OverridesAndInvalidValues(t *testing.T) {
 		os.Setenv("DB_MAX_IDLE", "xyz")
 		os.Setenv("DB_CONN_MAX_LIFETIME", "bad")
 		os.Setenv("DB_CONN_MAX_IDLE_TIME", "bad")
+		os.Setenv("READY_CHECK_SCHEMA", "maybe")
+		os.Setenv("TRACING_SAMPLE_RATIO", "half")
 
 		cfg := Load()
 		require.Equal(t, 20, cfg.MaxOpenConns)
 		require.Equal(t, 10, cfg.MaxIdleConns)
 		require.Equal(t, 30*time.Minute, cfg.ConnMaxLifetime)
 		require.Equal(t, 5*time.Minute, cfg.ConnMaxIdleTime)
+		require.True(t, cfg.ReadyCheckSchema)
+		require.Equal(t, 1.0, cfg.TracingSampleRatio)
 	})
 }
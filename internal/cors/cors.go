@@ -0,0 +1,191 @@
+// Package cors implements Cross-Origin Resource Sharing for browser clients.
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures CORS. Origins are exact ("https://app.example.com"),
+// wildcard subdomains ("https://*.example.com", which does not match
+// "https://example.com" itself) or "*" for any origin, which cannot be
+// combined with AllowCredentials. AllowedHeaders may be "*" to allow whatever
+// a preflight asks for.
+type Options struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type CORS struct {
+	anyOrigin  bool
+	exact      map[string]bool
+	wildcards  [][2]string // scheme://, .domain suffix
+	methods    map[string]bool
+	anyHeader  bool
+	headers    map[string]bool
+	allowMeths string
+	exposed    string
+	creds      bool
+	maxAge     string
+}
+
+// New fails if any origin ("*") is allowed with credentials: any site could
+// then make credentialed requests and read the responses.
+func New(o Options) (*CORS, error) {
+	c := &CORS{
+		exact:      map[string]bool{},
+		methods:    map[string]bool{},
+		headers:    map[string]bool{},
+		allowMeths: strings.Join(o.AllowedMethods, ", "),
+		exposed:    strings.Join(o.ExposedHeaders, ", "),
+		creds:      o.AllowCredentials,
+	}
+	for _, origin := range o.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			c.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "*")
+			c.wildcards = append(c.wildcards, [2]string{scheme, host})
+		default:
+			c.exact[origin] = true
+		}
+	}
+	for _, m := range o.AllowedMethods {
+		c.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range o.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	if c.anyOrigin && c.creds {
+		return nil, errors.New(`cors: origin "*" cannot be allowed with credentials`)
+	}
+	if o.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(o.MaxAge.Seconds()))
+	}
+	return c, nil
+}
+
+// OriginAllowed reports whether requests from origin may read responses.
+func (c *CORS) OriginAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.exact[origin] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		host, ok := strings.CutPrefix(origin, w[0])
+		if ok && strings.HasSuffix(host, w[1]) && len(host) > len(w[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler answers preflight requests and adds CORS headers to responses for
+// allowed origins. A listed origin is echoed (credentials need that); "*"
+// answers "*".
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		// Unless "*" is answered, responses depend on Origin, also when it is
+		// missing: a cache must not serve one without CORS headers to a
+		// browser.
+		if !c.anyOrigin {
+			h.Add("Vary", "Origin")
+		}
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
+			return
+		}
+
+		if c.OriginAllowed(origin) {
+			h.Set("Access-Control-Allow-Origin", c.allowOrigin(origin))
+			if c.creds {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if c.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", c.exposed)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight always answers 204; the browser blocks the actual request if
+// the Access-Control-Allow-* headers are missing.
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	reqHeaders := requestedHeaders(r)
+	if c.OriginAllowed(origin) && c.methods[method] && c.headersAllowed(reqHeaders) {
+		h.Set("Access-Control-Allow-Origin", c.allowOrigin(origin))
+		h.Set("Access-Control-Allow-Methods", c.allowMeths)
+		if len(reqHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+		}
+		if c.creds {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if c.maxAge != "" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) allowOrigin(origin string) string {
+	if c.anyOrigin {
+		return "*"
+	}
+	return origin
+}
+
+func requestedHeaders(r *http.Request) []string {
+	var out []string
+	for _, v := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				out = append(out, http.CanonicalHeaderKey(h))
+			}
+		}
+	}
+	return out
+}
+
+func (c *CORS) headersAllowed(hs []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range hs {
+		if !c.headers[h] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newCORS(t *testing.T) *CORS {
+	t.Helper()
+	c, err := New(Options{
+		AllowedOrigins:   []string{"https://app.example.com/", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "Idempotency-Key"},
+		ExposedHeaders:   []string{"ETag", "X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+// mustNew is New for options known to be valid.
+func mustNew(t *testing.T, o Options) *CORS {
+	t.Helper()
+	c, err := New(o)
+	require.NoError(t, err)
+	return c
+}
+
+func TestOriginAllowed(t *testing.T) {
+	c := newCORS(t)
+	for origin, want := range map[string]bool{
+		"https://app.example.com":      true,
+		"HTTPS://APP.EXAMPLE.COM":      true,
+		"http://app.example.com":       false,
+		"https://evil.com":             false,
+		"https://a.example.org":        true,
+		"https://a.b.example.org":      true,
+		"https://example.org":          false,
+		"https://badexample.org":       false,
+		"http://a.example.org":         false,
+		"https://app.example.com.evil": false,
+		"":                             false,
+	} {
+		require.Equal(t, want, c.OriginAllowed(origin), origin)
+	}
+	require.True(t, mustNew(t, Options{AllowedOrigins: []string{"*"}}).OriginAllowed("https://x.dev"))
+}
+
+func serve(c *CORS, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	called := false
+	rr := httptest.NewRecorder()
+	c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(rr, req)
+	return rr, called
+}
+
+func TestHandler_Preflight(t *testing.T) {
+	c := newCORS(t)
+	req := httptest.NewRequest(http.MethodOptions, "/notes/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, idempotency-key")
+
+	rr, called := serve(c, req)
+	require.False(t, called)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	h := rr.Header()
+	require.Equal(t, "https://app.example.com", h.Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, POST, PUT", h.Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "Content-Type, Idempotency-Key", h.Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "true", h.Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "600", h.Get("Access-Control-Max-Age"))
+	require.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, h.Values("Vary"))
+}
+
+func TestHandler_PreflightRejected(t *testing.T) {
+	c := newCORS(t)
+	for name, mod := range map[string]func(*http.Request){
+		"origin":  func(r *http.Request) { r.Header.Set("Origin", "https://evil.com") },
+		"method":  func(r *http.Request) { r.Header.Set("Access-Control-Request-Method", "DELETE") },
+		"headers": func(r *http.Request) { r.Header.Set("Access-Control-Request-Headers", "X-Secret") },
+	} {
+		req := httptest.NewRequest(http.MethodOptions, "/notes", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		mod(req)
+
+		rr, called := serve(c, req)
+		require.False(t, called, name)
+		require.Equal(t, http.StatusNoContent, rr.Code, name)
+		require.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"), name)
+	}
+
+	// "*" allows any requested header.
+	wildcard := mustNew(t, Options{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"POST"}, AllowedHeaders: []string{"*"}})
+	req := httptest.NewRequest(http.MethodOptions, "/notes", nil)
+	req.Header.Set("Origin", "https://x.dev")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "X-Whatever")
+	rr, _ := serve(wildcard, req)
+	require.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "X-Whatever", rr.Header().Get("Access-Control-Allow-Headers"))
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"))
+	require.Empty(t, rr.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestHandler_ActualRequest(t *testing.T) {
+	c := newCORS(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/notes", nil)
+	req.Header.Set("Origin", "https://a.example.org")
+	rr, called := serve(c, req)
+	require.True(t, called)
+	require.Equal(t, "https://a.example.org", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "ETag, X-Request-ID", rr.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, "Origin", rr.Header().Get("Vary"))
+
+	req.Header.Set("Origin", "https://evil.com")
+	rr, called = serve(c, req)
+	require.True(t, called)
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "Origin", rr.Header().Get("Vary"))
+
+	// Same-origin and non-browser requests only get Vary; OPTIONS without
+	// Access-Control-Request-Method is not a preflight.
+	rr, called = serve(c, httptest.NewRequest(http.MethodGet, "/notes", nil))
+	require.True(t, called)
+	require.Equal(t, http.Header{"Vary": {"Origin"}}, rr.Header())
+	req = httptest.NewRequest(http.MethodOptions, "/notes", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	_, called = serve(c, req)
+	require.True(t, called)
+}
+
+func TestNew_AnyOriginWithCredentials(t *testing.T) {
+	_, err := New(Options{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true})
+	require.Error(t, err)
+
+	c := mustNew(t, Options{AllowedOrigins: []string{"*"}})
+	req := httptest.NewRequest(http.MethodGet, "/notes", nil)
+	req.Header.Set("Origin", "https://x.dev")
+	rr, _ := serve(c, req)
+	require.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+// With "*" the response is the same for every origin: nothing to vary on.
+func TestHandler_AnyOriginNoVary(t *testing.T) {
+	c := mustNew(t, Options{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}})
+	req := httptest.NewRequest(http.MethodGet, "/notes", nil)
+	rr, _ := serve(c, req)
+	require.Empty(t, rr.Header().Values("Vary"))
+
+	req.Header.Set("Origin", "https://x.dev")
+	rr, _ = serve(c, req)
+	require.Equal(t, "*", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rr.Header().Values("Vary"))
+}
@@ -18,10 +18,7 @@ func Open(ctx context.Context, databaseURL string, maxOpen, maxIdle int, maxLife
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(maxOpen)
-	db.SetMaxIdleConns(maxIdle)
-	db.SetConnMaxLifetime(maxLifetime)
-	db.SetConnMaxIdleTime(maxIdleTime)
+	setPool(db, maxOpen, maxIdle, maxLifetime, maxIdleTime)
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
@@ -32,3 +29,10 @@ func Open(ctx context.Context, databaseURL string, maxOpen, maxIdle int, maxLife
 
 	return &DB{SQL: db}, nil
 }
+
+func setPool(db *sql.DB, maxOpen, maxIdle int, maxLifetime, maxIdleTime time.Duration) {
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(maxLifetime)
+	db.SetConnMaxIdleTime(maxIdleTime)
+}
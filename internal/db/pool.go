@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Pool is a native pgx connection pool. SQL is a database/sql handle drawing
+// connections from the same pool, for code written against *sql.DB
+// (migrations, saved searches, idempotency keys, health checks).
+type Pool struct {
+	Pool *pgxpool.Pool
+	SQL  *sql.DB
+}
+
+// OpenPool is Open for a pgxpool. pgxpool has no limit on idle connections:
+// they are closed after maxIdleTime instead, so there is no maxIdle.
+func OpenPool(ctx context.Context, databaseURL string, maxOpen int, maxLifetime, maxIdleTime time.Duration) (*Pool, error) {
+	cfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if maxOpen > 0 {
+		cfg.MaxConns = int32(maxOpen)
+	}
+	if maxLifetime > 0 {
+		cfg.MaxConnLifetime = maxLifetime
+	}
+	if maxIdleTime > 0 {
+		cfg.MaxConnIdleTime = maxIdleTime
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return &Pool{Pool: pool, SQL: stdlib.OpenDBFromPool(pool)}, nil
+}
+
+// Close closes the database/sql handle, then the pool.
+func (p *Pool) Close() error {
+	err := p.SQL.Close()
+	p.Pool.Close()
+	return err
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LSN is a Postgres write-ahead log position, written as "16/B374D848".
+type LSN uint64
+
+func ParseLSN(s string) (LSN, error) {
+	hi, lo, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("db: invalid LSN %q", s)
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("db: invalid LSN %q", s)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("db: invalid LSN %q", s)
+	}
+	return LSN(h<<32 | l), nil
+}
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint64(l)>>32, uint64(l)&0xFFFFFFFF)
+}
+
+type minLSNKey struct{}
+
+// WithMinLSN returns a context whose reads must see the primary at lsn or
+// later: replicas that have not replayed it are skipped.
+func WithMinLSN(ctx context.Context, lsn LSN) context.Context {
+	return context.WithValue(ctx, minLSNKey{}, lsn)
+}
+
+// MinLSN returns the LSN set by WithMinLSN, or 0.
+func MinLSN(ctx context.Context) LSN {
+	lsn, _ := ctx.Value(minLSNKey{}).(LSN)
+	return lsn
+}
+
+// Cluster is a primary with read replicas. Reads that tolerate replication
+// lag ask Reader for a database: replicas take turns (round-robin) while
+// they pass health checks and have replayed the context's MinLSN; otherwise
+// the primary serves the read.
+type Cluster struct {
+	Primary *sql.DB
+
+	// Logf reports replica health changes; nil discards them.
+	Logf func(format string, args ...any)
+
+	replicas []*replica
+	next     atomic.Uint64
+
+	primaryLSN func(context.Context) (LSN, error)
+}
+
+type replica struct {
+	name string
+	db   *sql.DB
+	// healthy and lsn are updated by Check: the last replayed LSN is a lower
+	// bound of the current one, so routing on it never reads too old data.
+	healthy atomic.Bool
+	lsn     atomic.Uint64
+}
+
+// Replica checks run with this timeout each.
+const replicaCheckTimeout = 2 * time.Second
+
+const (
+	qReplayLSN  = `SELECT coalesce(pg_last_wal_replay_lsn(), pg_current_wal_lsn())::text`
+	qCurrentLSN = `SELECT pg_current_wal_lsn()::text`
+)
+
+func NewCluster(primary *sql.DB) *Cluster {
+	c := &Cluster{Primary: primary}
+	c.primaryLSN = c.PrimaryLSN
+	return c
+}
+
+// AddReplica adds a replica, out of rotation until its first successful
+// Check. name identifies it in logs and must not contain credentials.
+func (c *Cluster) AddReplica(name string, db *sql.DB) {
+	c.replicas = append(c.replicas, &replica{name: name, db: db})
+}
+
+// OpenCluster opens the primary like Open and every replica with the same
+// pool settings. Replicas are not contacted: they join the rotation with the
+// first successful Check, so an unreachable replica is not an error.
+func OpenCluster(ctx context.Context, primaryURL string, replicaURLs []string, maxOpen, maxIdle int, maxLifetime, maxIdleTime time.Duration) (*Cluster, error) {
+	primary, err := Open(ctx, primaryURL, maxOpen, maxIdle, maxLifetime, maxIdleTime)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCluster(primary.SQL)
+	for _, u := range replicaURLs {
+		db, err := sql.Open("pgx", u)
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		setPool(db, maxOpen, maxIdle, maxLifetime, maxIdleTime)
+		c.AddReplica(replicaName(u), db)
+	}
+	return c, nil
+}
+
+// replicaName is the host of a connection URL; key=value DSNs have none.
+func replicaName(u string) string {
+	if p, err := url.Parse(u); err == nil && p.Host != "" {
+		return p.Host
+	}
+	return "replica"
+}
+
+// Check probes every replica and takes failing ones out of rotation.
+func (c *Cluster) Check(ctx context.Context) {
+	for _, r := range c.replicas {
+		lsn, err := replayLSN(ctx, r.db)
+		if err != nil {
+			if r.healthy.Swap(false) {
+				c.logf("db: replica %s is down: %v", r.name, err)
+			}
+			continue
+		}
+		r.lsn.Store(uint64(lsn))
+		if !r.healthy.Swap(true) {
+			c.logf("db: replica %s is up at %s", r.name, lsn)
+		}
+	}
+}
+
+func replayLSN(ctx context.Context, db *sql.DB) (LSN, error) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+	var s string
+	if err := db.QueryRowContext(ctx, qReplayLSN).Scan(&s); err != nil {
+		return 0, err
+	}
+	return ParseLSN(s)
+}
+
+// Run checks the replicas every interval until ctx is cancelled.
+func (c *Cluster) Run(ctx context.Context, interval time.Duration) {
+	if len(c.replicas) == 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.Check(ctx)
+		}
+	}
+}
+
+// Reader returns the next healthy replica that has replayed MinLSN(ctx), or
+// the primary with replica false.
+func (c *Cluster) Reader(ctx context.Context) (db *sql.DB, replica bool) {
+	n := len(c.replicas)
+	if n == 0 {
+		return c.Primary, false
+	}
+	min := uint64(MinLSN(ctx))
+	start := c.next.Add(1)
+	for i := 0; i < n; i++ {
+		r := c.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() && r.lsn.Load() >= min {
+			return r.db, true
+		}
+	}
+	return c.Primary, false
+}
+
+// Failed takes the replica db out of rotation after a failed read, until the
+// next successful Check.
+func (c *Cluster) Failed(db *sql.DB, err error) {
+	for _, r := range c.replicas {
+		if r.db == db && r.healthy.Swap(false) {
+			c.logf("db: replica %s failed, reading from primary: %v", r.name, err)
+		}
+	}
+}
+
+// PrimaryLSN returns the current WAL position of the primary.
+func (c *Cluster) PrimaryLSN(ctx context.Context) (LSN, error) {
+	var s string
+	if err := c.Primary.QueryRowContext(ctx, qCurrentLSN).Scan(&s); err != nil {
+		return 0, err
+	}
+	return ParseLSN(s)
+}
+
+// Close closes the replicas and the primary.
+func (c *Cluster) Close() error {
+	for _, r := range c.replicas {
+		_ = r.db.Close()
+	}
+	return c.Primary.Close()
+}
+
+func (c *Cluster) logf(format string, args ...any) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
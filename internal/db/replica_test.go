@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLSN(t *testing.T) {
+	lsn, err := ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	require.Equal(t, LSN(0x16B374D848), lsn)
+	require.Equal(t, "16/B374D848", lsn.String())
+	require.Equal(t, "0/0", LSN(0).String())
+
+	for _, s := range []string{"", "16", "x/1", "1/x", "1/100000000"} {
+		_, err := ParseLSN(s)
+		require.Error(t, err, s)
+	}
+}
+
+// handle returns a *sql.DB that is never connected, as a routing target.
+func handle(t *testing.T) *sql.DB {
+	db, err := sql.Open("pgx", "postgres://localhost:1/none")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestCluster_Reader(t *testing.T) {
+	primary, r1, r2 := handle(t), handle(t), handle(t)
+	c := NewCluster(primary)
+	ctx := context.Background()
+
+	got, ok := c.Reader(ctx)
+	require.Same(t, primary, got)
+	require.False(t, ok)
+
+	c.AddReplica("r1", r1)
+	c.AddReplica("r2", r2)
+	// Replicas join the rotation after a successful check.
+	got, _ = c.Reader(ctx)
+	require.Same(t, primary, got)
+
+	for i, r := range c.replicas {
+		r.healthy.Store(true)
+		r.lsn.Store(uint64(100 * (i + 1)))
+	}
+	seen := map[*sql.DB]int{}
+	for i := 0; i < 4; i++ {
+		got, ok := c.Reader(ctx)
+		require.True(t, ok)
+		seen[got]++
+	}
+	require.Equal(t, map[*sql.DB]int{r1: 2, r2: 2}, seen)
+
+	// Read-your-writes: only r2 has replayed LSN 150, nobody has 300.
+	for i := 0; i < 3; i++ {
+		got, _ := c.Reader(WithMinLSN(ctx, 150))
+		require.Same(t, r2, got)
+	}
+	got, ok = c.Reader(WithMinLSN(ctx, 300))
+	require.Same(t, primary, got)
+	require.False(t, ok)
+
+	// A failed read takes the replica out of rotation.
+	var logged []string
+	c.Logf = func(format string, _ ...any) { logged = append(logged, format) }
+	c.Failed(r2, errors.New("connection reset"))
+	c.Failed(r2, errors.New("connection reset"))
+	require.Len(t, logged, 1)
+	for i := 0; i < 3; i++ {
+		got, _ := c.Reader(ctx)
+		require.Same(t, r1, got)
+	}
+}
+
+func TestCluster_ReadYourWrites(t *testing.T) {
+	c := NewCluster(handle(t))
+	c.primaryLSN = func(context.Context) (LSN, error) { return 0x1A0, nil }
+
+	var minLSN LSN
+	h := c.ReadYourWrites(30 * time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		minLSN = MinLSN(r.Context())
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	// A write hands out the primary LSN.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notes", nil))
+	require.Equal(t, "0/1A0", rec.Header().Get(LSNHeader))
+	cookie := rec.Result().Cookies()[0]
+	require.Equal(t, LSNCookie, cookie.Name)
+	require.Equal(t, "0/1A0", cookie.Value)
+	require.Equal(t, 30, cookie.MaxAge)
+
+	// Reads carrying it, as a cookie or a header, require it.
+	req := httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	req.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, LSN(0x1A0), minLSN)
+	require.Empty(t, rec.Header().Get(LSNHeader))
+
+	req = httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	req.Header.Set(LSNHeader, "0/2B")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, LSN(0x2B), minLSN)
+
+	req = httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	req.Header.Set(LSNHeader, "garbage")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Zero(t, minLSN)
+
+	// Failed writes and an unavailable primary LSN hand out nothing.
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/fail", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Empty(t, rec.Header().Get(LSNHeader))
+
+	c.primaryLSN = func(context.Context) (LSN, error) { return 0, errors.New("down") }
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/notes/1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(LSNHeader))
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LSNHeader and LSNCookie carry the primary LSN after a write, so the
+// client's next reads see it (read-your-writes). Clients echo the header or
+// keep the cookie; either is enough.
+const (
+	LSNHeader = "X-Notes-LSN"
+	LSNCookie = "notes_lsn"
+)
+
+// ReadYourWrites makes reads of a client that just wrote wait for replicas
+// to catch up, or go to the primary. A successful mutating request gets the
+// primary LSN in LSNHeader and in LSNCookie valid for ttl; requests that
+// carry it read with WithMinLSN. The ttl should exceed the usual replica lag.
+func (c *Cluster) ReadYourWrites(ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if lsn, ok := requestLSN(r); ok {
+				r = r.WithContext(WithMinLSN(r.Context(), lsn))
+			}
+			if !mutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(&lsnWriter{ResponseWriter: w, r: r, c: c, ttl: ttl}, r)
+		})
+	}
+}
+
+func requestLSN(r *http.Request) (LSN, bool) {
+	s := r.Header.Get(LSNHeader)
+	if s == "" {
+		if ck, err := r.Cookie(LSNCookie); err == nil {
+			s = ck.Value
+		}
+	}
+	if s == "" {
+		return 0, false
+	}
+	lsn, err := ParseLSN(s)
+	return lsn, err == nil
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// lsnWriter adds the primary LSN to successful responses before their
+// headers are sent.
+type lsnWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	c           *Cluster
+	ttl         time.Duration
+	wroteHeader bool
+}
+
+func (w *lsnWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status < http.StatusBadRequest {
+			w.setLSN()
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *lsnWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *lsnWriter) setLSN() {
+	lsn, err := w.c.primaryLSN(w.r.Context())
+	if err != nil {
+		// Without a token the next reads may lag; they still succeed.
+		slog.WarnContext(w.r.Context(), "read-your-writes: primary LSN", "error", err)
+		return
+	}
+	v := lsn.String()
+	w.Header().Set(LSNHeader, v)
+	http.SetCookie(w, &http.Cookie{
+		Name:     LSNCookie,
+		Value:    v,
+		Path:     "/",
+		MaxAge:   max(1, int(w.ttl.Round(time.Second).Seconds())),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
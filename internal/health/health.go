@@ -0,0 +1,215 @@
+// Package health implements liveness and readiness probes.
+//
+// Liveness only tells that the process is able to serve HTTP. Readiness runs
+// every registered Checker (database, schema version, shutdown state, ...)
+// and fails if any of them fails.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/notes-api-pz14/internal/logging"
+)
+
+// Checker reports whether a dependency is usable.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error { return f(ctx) }
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckResult is the outcome of a single check. The report is public, so
+// Error is only a generic reason; the error itself is logged.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+
+	err error
+}
+
+// Report is the readiness response body.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Registry holds readiness checks. Dependencies register their own checks.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []namedChecker
+}
+
+// NewRegistry creates a registry; every check gets at most timeout to finish.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a named check. Registering a name twice replaces the check.
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.checks {
+		if r.checks[i].name == name {
+			r.checks[i].checker = c
+			return
+		}
+	}
+	r.checks = append(r.checks, namedChecker{name: name, checker: c})
+}
+
+// Run executes all checks concurrently.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]namedChecker(nil), r.checks...)
+	r.mu.RUnlock()
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c namedChecker) {
+			defer wg.Done()
+			res := r.runOne(ctx, c.checker)
+			if res.err != nil {
+				logging.FromContext(ctx).Warn("readiness check failed", "check", c.name, "error", res.err)
+			}
+			mu.Lock()
+			rep.Checks[c.name] = res
+			if res.Status != StatusOK {
+				rep.Status = StatusFail
+			}
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return rep
+}
+
+func (r *Registry) runOne(ctx context.Context, c Checker) CheckResult {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := c.Check(ctx)
+	res := CheckResult{Status: StatusOK, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = reason(err)
+		res.err = err
+	}
+	return res
+}
+
+// reason is the public description of a failed check.
+func reason(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, ErrDraining):
+		return ErrDraining.Error()
+	default:
+		return "unavailable"
+	}
+}
+
+// Ready returns an error naming the failed checks, or nil.
+func (r *Registry) Ready(ctx context.Context) error {
+	rep := r.Run(ctx)
+	if rep.Status == StatusOK {
+		return nil
+	}
+	var failed []string
+	for name, c := range rep.Checks {
+		if c.Status != StatusOK {
+			failed = append(failed, name+": "+c.err.Error())
+		}
+	}
+	sort.Strings(failed)
+	return fmt.Errorf("not ready: %v", failed)
+}
+
+// ReadyHandler serves the readiness report: 200 if every check passed, 503 otherwise.
+func (r *Registry) ReadyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rep := r.Run(req.Context())
+		status := http.StatusOK
+		if rep.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, rep)
+	}
+}
+
+// LiveHandler serves liveness: the process is up and handling requests.
+func LiveHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+	}
+}
+
+// PingChecker checks that the database accepts connections.
+func PingChecker(db *sql.DB) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// SchemaChecker checks that migrations up to version want were applied.
+func SchemaChecker(db *sql.DB, want int64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var got int64
+		err := db.QueryRowContext(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&got)
+		if err != nil {
+			return err
+		}
+		if got < want {
+			return fmt.Errorf("schema version %d, want %d", got, want)
+		}
+		return nil
+	})
+}
+
+// ErrDraining is reported while the process is shutting down.
+var ErrDraining = errors.New("shutting down")
+
+// DrainChecker fails once draining reports true.
+func DrainChecker(draining func() bool) Checker {
+	return CheckerFunc(func(context.Context) error {
+		if draining() {
+			return ErrDraining
+		}
+		return nil
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
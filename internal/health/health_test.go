@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ReadyHandler(t *testing.T) {
+	reg := NewRegistry(20 * time.Millisecond)
+	reg.Register("ok", CheckerFunc(func(context.Context) error { return nil }))
+
+	rr := httptest.NewRecorder()
+	reg.ReadyHandler()(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// slow check hits the per-check timeout
+	reg.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	rr = httptest.NewRecorder()
+	reg.ReadyHandler()(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	var rep Report
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rep))
+	require.Equal(t, StatusFail, rep.Status)
+	require.Equal(t, StatusOK, rep.Checks["ok"].Status)
+	require.Equal(t, StatusFail, rep.Checks["slow"].Status)
+	require.Equal(t, "timeout", rep.Checks["slow"].Error)
+	require.GreaterOrEqual(t, rep.Checks["slow"].LatencyMS, float64(20))
+
+	// Other errors are logged, not returned: they may name hosts or users.
+	reg.Register("slow", CheckerFunc(func(context.Context) error {
+		return errors.New(`failed to connect to host=db.internal user=notes`)
+	}))
+	rr = httptest.NewRecorder()
+	reg.ReadyHandler()(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.NotContains(t, rr.Body.String(), "db.internal")
+	rep = Report{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &rep))
+	require.Equal(t, "unavailable", rep.Checks["slow"].Error)
+}
+
+func TestRegistry_Ready_And_Drain(t *testing.T) {
+	draining := false
+	reg := NewRegistry(time.Second)
+	reg.Register("shutdown", DrainChecker(func() bool { return draining }))
+	require.NoError(t, reg.Ready(context.Background()))
+
+	draining = true
+	err := reg.Ready(context.Background())
+	require.ErrorContains(t, err, "shutdown: shutting down")
+
+	// re-registering replaces the check
+	reg.Register("shutdown", CheckerFunc(func(context.Context) error { return errors.New("other") }))
+	require.ErrorContains(t, reg.Ready(context.Background()), "other")
+	require.Len(t, reg.Run(context.Background()).Checks, 1)
+}
+
+func TestLiveHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	LiveHandler()(rr, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,161 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProblem(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteProblem(rr, httptest.NewRequest(http.MethodPost, "/notes", nil), http.StatusTooManyRequests, "slow down")
+
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	require.Equal(t, Problem{Type: "about:blank", Title: "Too Many Requests", Status: 429, Detail: "slow down", Instance: "/notes"}, p)
+}
+
+func TestParseSizes(t *testing.T) {
+	for in, want := range map[string]int64{"100": 100, "64KiB": 64 << 10, " 2 MiB": 2 << 20, "1GiB": 1 << 30} {
+		got, err := ParseSize(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	for _, bad := range []string{"", "0", "-1", "1KB", "xMiB"} {
+		_, err := ParseSize(bad)
+		require.Error(t, err, bad)
+	}
+
+	sizes, err := ParseSizes("POST  /notes/batch=64KiB, PUT /notes/{id}=1000,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"POST /notes/batch": 64 << 10, "PUT /notes/{id}": 1000}, sizes)
+	_, err = ParseSizes("POST /notes")
+	require.Error(t, err)
+}
+
+func bodyRouter(mw func(http.Handler) http.Handler) http.Handler {
+	read := func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	sub := chi.NewRouter()
+	sub.Post("/notes", read)
+	sub.Put("/notes/{id}", read)
+
+	r := chi.NewRouter()
+	r.Use(mw)
+	r.Get("/livez", func(w http.ResponseWriter, r *http.Request) {})
+	r.Mount("/", sub)
+	return r
+}
+
+func TestBodyLimit(t *testing.T) {
+	h := bodyRouter(BodyLimit(10, map[string]int64{"PUT /notes/{id}": 4}))
+
+	send := func(method, path, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if chunked {
+			req.ContentLength = -1
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/notes", "0123456789", false).Code)
+
+	rr := send(http.MethodPost, "/notes", "0123456789x", false)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	// Without Content-Length the body is read before the handler runs.
+	rr = send(http.MethodPost, "/notes", "0123456789x", true)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var p Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	require.Equal(t, "request body must not exceed 10 bytes", p.Detail)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, send(http.MethodPut, "/notes/1", "01234", false).Code)
+	require.Equal(t, http.StatusOK, send(http.MethodPut, "/notes/1", "0123", true).Code)
+}
+
+func TestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	h := Timeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
+
+func TestRecover(t *testing.T) {
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/notes/1", nil))
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	require.NotContains(t, rr.Body.String(), "boom")
+
+	abort := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		abort.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestSecureHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	rr := httptest.NewRecorder()
+	SecureHeaders(DefaultSecurityHeaders)(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, "DENY", rr.Header().Get("X-Frame-Options"))
+	require.Equal(t, "no-referrer", rr.Header().Get("Referrer-Policy"))
+	require.Equal(t, "default-src 'none'; frame-ancestors 'none'", rr.Header().Get("Content-Security-Policy"))
+	require.Empty(t, rr.Header().Get("Strict-Transport-Security"))
+
+	rr = httptest.NewRecorder()
+	SecureHeaders(SecurityHeaders{HSTSMaxAge: 24 * time.Hour})(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "max-age=86400; includeSubDomains", rr.Header().Get("Strict-Transport-Security"))
+	require.Empty(t, rr.Header().Get("Content-Security-Policy"))
+}
+
+func TestMatchRoute(t *testing.T) {
+	var got string
+	sub := chi.NewRouter()
+	sub.Get("/notes/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = MatchRoute(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.Mount("/", sub)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/notes/5", nil))
+	require.Equal(t, "/notes/{id}", got)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/notes/5", nil))
+	require.Equal(t, "", got)
+}
@@ -0,0 +1,158 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/notes-api-pz14/internal/logging"
+)
+
+// ParseSize parses a byte size: a plain number or one with a KiB, MiB or GiB
+// suffix, e.g. "64KiB".
+func ParseSize(s string) (int64, error) {
+	num := strings.TrimSpace(s)
+	mult := int64(1)
+	for suffix, m := range map[string]int64{"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30} {
+		if v, ok := strings.CutSuffix(num, suffix); ok {
+			num, mult = strings.TrimSpace(v), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("httpx: invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// ParseSizes parses comma separated "<METHOD /route>=<size>" pairs, e.g.
+// "POST /notes/batch=64KiB".
+func ParseSizes(s string) (map[string]int64, error) {
+	out := map[string]int64{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		route, size, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("httpx: invalid body limit %q", part)
+		}
+		n, err := ParseSize(size)
+		if err != nil {
+			return nil, err
+		}
+		out[strings.Join(strings.Fields(route), " ")] = n
+	}
+	return out, nil
+}
+
+// BodyLimit caps request bodies at def bytes, or at the limit configured for
+// "METHOD /route" in routes. Requests declaring a larger Content-Length get
+// 413 right away; bodies of unknown length are read up to the limit first,
+// so handlers never see a body cut short. Install it on the root chi router.
+func BodyLimit(def int64, routes map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := def
+			if l, ok := routes[r.Method+" "+MatchRoute(r)]; ok {
+				limit = l
+			}
+			if r.ContentLength > limit {
+				WriteProblem(w, r, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("request body must not exceed %d bytes", limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			if r.ContentLength < 0 {
+				body, err := io.ReadAll(r.Body)
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					WriteProblem(w, r, http.StatusRequestEntityTooLarge,
+						fmt.Sprintf("request body must not exceed %d bytes", limit))
+					return
+				}
+				if err != nil {
+					WriteProblem(w, r, http.StatusBadRequest, "cannot read request body")
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout sets a deadline on the request context; repository calls made with
+// it are cancelled once the deadline passes.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Recover turns a panic into a 500 problem response and logs it with the
+// stack trace. http.ErrAbortHandler is re-panicked to abort the response.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logging.FromContext(r.Context()).Error("panic",
+				"panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			WriteProblem(w, r, http.StatusInternalServerError, "internal error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SecurityHeaders configures SecureHeaders.
+type SecurityHeaders struct {
+	// ContentSecurityPolicy is sent unless empty.
+	ContentSecurityPolicy string
+	// HSTSMaxAge enables Strict-Transport-Security when positive; only set it
+	// when the API is served over HTTPS.
+	HSTSMaxAge time.Duration
+}
+
+// DefaultSecurityHeaders suit a JSON API that is never rendered as a page.
+var DefaultSecurityHeaders = SecurityHeaders{
+	ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+}
+
+// SecureHeaders sets security headers on every response.
+func SecureHeaders(cfg SecurityHeaders) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+			if cfg.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			if cfg.HSTSMaxAge > 0 {
+				h.Set("Strict-Transport-Security",
+					fmt.Sprintf("max-age=%d; includeSubDomains", int64(cfg.HSTSMaxAge.Seconds())))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MatchRoute resolves the chi route pattern of r before it is dispatched, so
+// middlewares on the root router can apply per-route settings. It returns ""
+// if no route matches.
+func MatchRoute(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, r.URL.Path) {
+		return ""
+	}
+	return tctx.RoutePattern()
+}
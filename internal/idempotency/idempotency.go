@@ -0,0 +1,213 @@
+// Package idempotency makes retried mutating requests safe: the first
+// response for an Idempotency-Key is stored and replayed for retries with the
+// same request, and a reuse of the key for a different request is rejected.
+// Keys are scoped per client, so a client cannot replay another client's
+// response or block its requests by guessing its keys.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"example.com/notes-api-pz14/internal/httpx"
+	"example.com/notes-api-pz14/internal/logging"
+)
+
+const (
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on replayed responses.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLen = 255
+)
+
+// replayedHeaders are stored with the response; everything else is
+// regenerated by the middlewares on replay.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Middleware applies idempotency keys to POST, PUT, PATCH and DELETE
+// requests that carry the Idempotency-Key header.
+type Middleware struct {
+	store  Store
+	ttl    time.Duration
+	lock   time.Duration
+	client func(r *http.Request) string
+}
+
+// New returns a middleware keeping keys for ttl. A key whose first request
+// did not finish within lock is considered abandoned and can be reused.
+// Clients are told apart by remote address unless WithClient is used.
+func New(store Store, ttl, lock time.Duration) *Middleware {
+	return &Middleware{store: store, ttl: ttl, lock: lock, client: remoteAddr}
+}
+
+// WithClient sets how the client owning a key is identified, e.g. by API key
+// with the remote address as fallback. An empty identity falls back to the
+// remote address.
+func (m *Middleware) WithClient(fn func(r *http.Request) string) *Middleware {
+	m.client = fn
+	return m
+}
+
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || !mutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLen {
+			httpx.WriteProblem(w, r, http.StatusBadRequest,
+				fmt.Sprintf("%s must be at most %d characters", Header, maxKeyLen))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpx.WriteProblem(w, r, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("request body must not exceed %d bytes", tooLarge.Limit))
+			return
+		}
+		if err != nil {
+			httpx.WriteProblem(w, r, http.StatusBadRequest, "cannot read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		client := m.client(r)
+		if client == "" {
+			client = remoteAddr(r)
+		}
+		fp := fingerprint(r, body)
+		rec, claimed, err := m.store.Begin(ctx, client, key, fp, m.ttl, m.lock)
+		if err != nil {
+			logging.FromContext(ctx).Error("idempotency: begin", "error", err)
+			httpx.WriteProblem(w, r, http.StatusInternalServerError, "idempotency key store unavailable")
+			return
+		}
+
+		if !claimed {
+			switch {
+			case rec.Fingerprint != fp:
+				httpx.WriteProblem(w, r, http.StatusUnprocessableEntity,
+					fmt.Sprintf("%s was already used for a different request", Header))
+			case rec.Response == nil:
+				w.Header().Set("Retry-After", "1")
+				httpx.WriteProblem(w, r, http.StatusConflict,
+					"a request with this "+Header+" is still being processed")
+			default:
+				replay(w, *rec.Response)
+			}
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// A panic or server error releases the key so the client can retry.
+			if !completed {
+				if err := m.store.Release(context.WithoutCancel(ctx), client, key); err != nil {
+					logging.FromContext(ctx).Error("idempotency: release", "error", err)
+				}
+			}
+		}()
+		next.ServeHTTP(rw, r)
+
+		if rw.status >= http.StatusInternalServerError {
+			return
+		}
+		resp := Response{Status: rw.status, Header: http.Header{}, Body: rw.body.Bytes()}
+		for _, h := range replayedHeaders {
+			if v := rw.Header().Values(h); len(v) > 0 {
+				resp.Header[h] = v
+			}
+		}
+		// The request may already be cancelled; the outcome must be kept anyway.
+		if err := m.store.Complete(context.WithoutCancel(ctx), client, key, resp); err != nil {
+			logging.FromContext(ctx).Error("idempotency: complete", "error", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// Run prunes expired keys until ctx is cancelled.
+func (m *Middleware) Run(ctx context.Context) {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := m.store.Prune(ctx); err != nil && ctx.Err() == nil {
+				logging.FromContext(ctx).Warn("idempotency: prune", "error", err)
+			}
+		}
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// fingerprint identifies a request by method, path, query and body.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, resp Response) {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+// recorder passes the response through and keeps a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,227 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is a Store for tests; expiry is not modelled.
+type memStore struct {
+	mu      sync.Mutex
+	records map[[2]string]Record
+	beginFn func() error
+}
+
+func newMemStore() *memStore { return &memStore{records: map[[2]string]Record{}} }
+
+func (s *memStore) Begin(_ context.Context, client, key, fp string, _, _ time.Duration) (Record, bool, error) {
+	if s.beginFn != nil {
+		if err := s.beginFn(); err != nil {
+			return Record{}, false, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := [2]string{client, key}
+	if rec, ok := s.records[k]; ok {
+		return rec, false, nil
+	}
+	s.records[k] = Record{Fingerprint: fp}
+	return Record{Fingerprint: fp}, true, nil
+}
+
+func (s *memStore) Complete(_ context.Context, client, key string, resp Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := [2]string{client, key}
+	rec := s.records[k]
+	rec.Response = &resp
+	s.records[k] = rec
+	return nil
+}
+
+func (s *memStore) Release(_ context.Context, client, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := [2]string{client, key}
+	if s.records[k].Response == nil {
+		delete(s.records, k)
+	}
+	return nil
+}
+
+func (s *memStore) Prune(context.Context) error { return nil }
+
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *counter) inc() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n++
+	return c.n
+}
+
+func do(t *testing.T, h http.Handler, method, path, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMiddleware_ReplaysStoredResponse(t *testing.T) {
+	var calls counter
+	h := New(newMemStore(), time.Hour, time.Minute).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		n := calls.inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/notes/1")
+		w.Header().Set("X-Other", "x")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"n":%d,"body":%s}`, n, b)
+	}))
+
+	first := do(t, h, http.MethodPost, "/notes", "k1", `{"t":1}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	require.Empty(t, first.Header().Get(ReplayedHeader))
+
+	again := do(t, h, http.MethodPost, "/notes", "k1", `{"t":1}`)
+	require.Equal(t, http.StatusCreated, again.Code)
+	require.Equal(t, "true", again.Header().Get(ReplayedHeader))
+	require.Equal(t, first.Body.String(), again.Body.String())
+	require.Equal(t, "application/json", again.Header().Get("Content-Type"))
+	require.Equal(t, "/notes/1", again.Header().Get("Location"))
+	require.Empty(t, again.Header().Get("X-Other"))
+	require.Equal(t, 1, calls.n)
+
+	// Requests without a key or with safe methods are not affected.
+	do(t, h, http.MethodPost, "/notes", "", `{"t":1}`)
+	do(t, h, http.MethodGet, "/notes", "k1", "")
+	require.Equal(t, 3, calls.n)
+}
+
+func TestMiddleware_KeyReusedForDifferentRequest(t *testing.T) {
+	h := New(newMemStore(), time.Hour, time.Minute).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	require.Equal(t, http.StatusCreated, do(t, h, http.MethodPost, "/notes", "k1", `{"t":1}`).Code)
+
+	rr := do(t, h, http.MethodPost, "/notes", "k1", `{"t":2}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+	require.Equal(t, http.StatusUnprocessableEntity, do(t, h, http.MethodPut, "/notes/1", "k1", `{"t":1}`).Code)
+}
+
+func TestMiddleware_KeysAreScopedByClient(t *testing.T) {
+	var calls counter
+	h := New(newMemStore(), time.Hour, time.Minute).
+		WithClient(func(r *http.Request) string { return r.Header.Get("X-Client") }).
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%d", calls.inc())
+		}))
+	post := func(client, remote, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notes", strings.NewReader(body))
+		req.Header.Set(Header, "k1")
+		req.Header.Set("X-Client", client)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, "1", post("a", "10.0.0.1:1", `{}`).Body.String())
+	// Another client's key neither replays the response nor conflicts.
+	require.Equal(t, "2", post("b", "10.0.0.1:1", `{"other":1}`).Body.String())
+	rr := post("a", "10.0.0.2:1", `{}`)
+	require.Equal(t, "true", rr.Header().Get(ReplayedHeader))
+	require.Equal(t, "1", rr.Body.String())
+
+	// Without an identity the remote address tells clients apart.
+	require.Equal(t, "3", post("", "10.0.0.1:1", `{}`).Body.String())
+	require.Equal(t, "4", post("", "10.0.0.2:1", `{}`).Body.String())
+	require.Equal(t, "3", post("", "10.0.0.1:2", `{}`).Body.String())
+}
+
+func TestMiddleware_ServerErrorsAreNotStored(t *testing.T) {
+	var calls counter
+	h := New(newMemStore(), time.Hour, time.Minute).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.inc() == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	require.Equal(t, http.StatusInternalServerError, do(t, h, http.MethodDelete, "/notes/1", "k1", "").Code)
+	require.Equal(t, http.StatusNoContent, do(t, h, http.MethodDelete, "/notes/1", "k1", "").Code)
+	rr := do(t, h, http.MethodDelete, "/notes/1", "k1", "")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "true", rr.Header().Get(ReplayedHeader))
+	require.Equal(t, 2, calls.n)
+}
+
+func TestMiddleware_InProgress(t *testing.T) {
+	store := newMemStore()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := New(store, time.Hour, time.Minute).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan int)
+	go func() { done <- do(t, h, http.MethodPost, "/notes", "k1", `{}`).Code }()
+	<-started
+
+	rr := do(t, h, http.MethodPost, "/notes", "k1", `{}`)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	close(release)
+	require.Equal(t, http.StatusCreated, <-done)
+}
+
+func TestMiddleware_Errors(t *testing.T) {
+	store := newMemStore()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := New(store, time.Hour, time.Minute).Handler(next)
+
+	require.Equal(t, http.StatusBadRequest, do(t, h, http.MethodPost, "/notes", strings.Repeat("k", maxKeyLen+1), "").Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/notes", strings.NewReader(strings.Repeat("x", 100)))
+	req.Header.Set(Header, "k2")
+	rr := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rr, req.Body, 10)
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	store.beginFn = func() error { return errors.New("db down") }
+	require.Equal(t, http.StatusInternalServerError, do(t, h, http.MethodPost, "/notes", "k1", "").Code)
+}
+
+func TestFingerprint(t *testing.T) {
+	a := httptest.NewRequest(http.MethodPost, "/notes?x=1", nil)
+	b := httptest.NewRequest(http.MethodPost, "/notes?x=2", nil)
+	require.Equal(t, fingerprint(a, []byte("body")), fingerprint(a, []byte("body")))
+	require.NotEqual(t, fingerprint(a, []byte("body")), fingerprint(a, []byte("other")))
+	require.NotEqual(t, fingerprint(a, []byte("body")), fingerprint(b, []byte("body")))
+}
@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Response is a stored response replayed for retries.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Record is the state of a key. Response is nil while the first request is
+// still being served.
+type Record struct {
+	Fingerprint string
+	Response    *Response
+}
+
+// Store persists idempotency keys. Keys are scoped by client: the same key
+// of two clients names two records.
+type Store interface {
+	// Begin claims the client's key for a request with fingerprint. If the
+	// key is taken and neither expired nor abandoned (in progress for longer
+	// than lock), it returns the existing record and claimed == false.
+	Begin(ctx context.Context, client, key, fingerprint string, ttl, lock time.Duration) (rec Record, claimed bool, err error)
+	// Complete stores the response of a claimed key.
+	Complete(ctx context.Context, client, key string, resp Response) error
+	// Release forgets a claimed key so the request can be retried.
+	Release(ctx context.Context, client, key string) error
+	// Prune deletes expired keys.
+	Prune(ctx context.Context) error
+}
+
+const (
+	// An expired key or one abandoned in progress is taken over.
+	qBegin = `
+		INSERT INTO idempotency_keys AS k (client, key, fingerprint, expires_at)
+		VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+		ON CONFLICT (client, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			response = NULL,
+			created_at = now(),
+			expires_at = EXCLUDED.expires_at
+		WHERE k.expires_at < now()
+		   OR (k.response IS NULL AND k.created_at < now() - make_interval(secs => $5))
+		RETURNING key`
+
+	qGet = `SELECT fingerprint, response FROM idempotency_keys WHERE client = $1 AND key = $2`
+
+	qComplete = `UPDATE idempotency_keys SET response = $3 WHERE client = $1 AND key = $2`
+
+	qRelease = `DELETE FROM idempotency_keys WHERE client = $1 AND key = $2 AND response IS NULL`
+
+	qPrune = `DELETE FROM idempotency_keys WHERE expires_at < now()`
+)
+
+// PostgresStore keeps keys in the idempotency_keys table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Begin(ctx context.Context, client, key, fingerprint string, ttl, lock time.Duration) (Record, bool, error) {
+	// The existing row may be released or pruned between the two statements;
+	// then the key is free and claiming it is tried again.
+	for attempt := 0; ; attempt++ {
+		var k string
+		err := s.db.QueryRowContext(ctx, qBegin, client, key, fingerprint, ttl.Seconds(), lock.Seconds()).Scan(&k)
+		if err == nil {
+			return Record{Fingerprint: fingerprint}, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return Record{}, false, err
+		}
+
+		var rec Record
+		var raw []byte
+		err = s.db.QueryRowContext(ctx, qGet, client, key).Scan(&rec.Fingerprint, &raw)
+		if errors.Is(err, sql.ErrNoRows) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		if raw != nil {
+			rec.Response = &Response{}
+			if err := json.Unmarshal(raw, rec.Response); err != nil {
+				return Record{}, false, err
+			}
+		}
+		return rec, false, nil
+	}
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, client, key string, resp Response) error {
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, qComplete, client, key, raw)
+	return err
+}
+
+func (s *PostgresStore) Release(ctx context.Context, client, key string) error {
+	_, err := s.db.ExecContext(ctx, qRelease, client, key)
+	return err
+}
+
+func (s *PostgresStore) Prune(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, qPrune)
+	return err
+}
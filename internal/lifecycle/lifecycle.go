@@ -0,0 +1,132 @@
+// Package lifecycle coordinates graceful shutdown of the API process.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCloseTimeout bounds each OnClose hook.
+const DefaultCloseTimeout = 5 * time.Second
+
+// Manager runs shutdown hooks in the order they were registered.
+type Manager struct {
+	draining     atomic.Bool
+	closeTimeout time.Duration
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+type hook struct {
+	name  string
+	fn    func(context.Context) error
+	close bool
+}
+
+func New() *Manager {
+	return &Manager{closeTimeout: DefaultCloseTimeout}
+}
+
+// WithCloseTimeout sets how long each OnClose hook may take.
+func (m *Manager) WithCloseTimeout(d time.Duration) *Manager {
+	m.closeTimeout = d
+	return m
+}
+
+// OnShutdown registers fn to be called by Shutdown after the hooks registered before it.
+func (m *Manager) OnShutdown(name string, fn func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn})
+}
+
+// OnClose registers fn like OnShutdown, for releasing resources (connection
+// pools, exporters): it runs even once the shutdown context is done, with a
+// deadline of its own, and Shutdown stops waiting for it at that deadline.
+func (m *Manager) OnClose(name string, fn func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, fn: fn, close: true})
+}
+
+// Go runs a background worker until shutdown. Its context is not tied to the
+// signal context, so the worker keeps running while the server drains; the
+// shutdown hook cancels it and waits for fn to return.
+func (m *Manager) Go(name string, fn func(context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+
+	m.OnShutdown(name, func(sctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-sctx.Done():
+			return sctx.Err()
+		}
+	})
+}
+
+// StartDraining marks the process as shutting down: readiness starts failing
+// while requests are still served.
+func (m *Manager) StartDraining() {
+	m.draining.Store(true)
+}
+
+// Draining reports whether shutdown has started.
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// Shutdown starts draining and runs every hook in order. A failing hook does not
+// stop the following ones; errors are joined. Once ctx is done the remaining
+// OnShutdown hooks are skipped; OnClose hooks still run.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.StartDraining()
+
+	m.mu.Lock()
+	hooks := append([]hook(nil), m.hooks...)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, h := range hooks {
+		var err error
+		switch {
+		case h.close:
+			err = m.runClose(ctx, h.fn)
+		case ctx.Err() != nil:
+			errs = append(errs, fmt.Errorf("%s: skipped: %w", h.name, ctx.Err()))
+			continue
+		default:
+			err = h.fn(ctx)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runClose runs an OnClose hook with its own deadline. A hook that ignores
+// its context is left running once the deadline passes.
+func (m *Manager) runClose(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.closeTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Shutdown_Order(t *testing.T) {
+	m := New()
+	var order []string
+	m.OnShutdown("first", func(context.Context) error { order = append(order, "first"); return nil })
+	m.OnShutdown("second", func(context.Context) error { order = append(order, "second"); return errors.New("boom") })
+	m.OnShutdown("third", func(context.Context) error { order = append(order, "third"); return nil })
+
+	require.False(t, m.Draining())
+	err := m.Shutdown(context.Background())
+	require.True(t, m.Draining())
+	require.ErrorContains(t, err, "second: boom")
+	require.Equal(t, []string{"first", "second", "third"}, order)
+}
+
+func TestManager_Go_StopsWorker(t *testing.T) {
+	m := New()
+	stopped := make(chan struct{})
+	m.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	require.NoError(t, m.Shutdown(context.Background()))
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("worker was not stopped")
+	}
+}
+
+func TestManager_Shutdown_Timeout(t *testing.T) {
+	m := New()
+	m.Go("stuck", func(ctx context.Context) { time.Sleep(time.Second) })
+	called := false
+	m.OnShutdown("after", func(context.Context) error { called = true; return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := m.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, called)
+}
+
+func TestManager_OnClose_RunsAfterTimeout(t *testing.T) {
+	m := New().WithCloseTimeout(20 * time.Millisecond)
+	m.Go("stuck", func(ctx context.Context) { time.Sleep(time.Second) })
+	var closed []string
+	m.OnClose("pool", func(ctx context.Context) error {
+		closed = append(closed, "pool")
+		return ctx.Err() // not the expired shutdown context
+	})
+	m.OnClose("hung", func(context.Context) error { time.Sleep(time.Second); return nil })
+	m.OnClose("exporter", func(context.Context) error { closed = append(closed, "exporter"); return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := m.Shutdown(ctx)
+	require.ErrorContains(t, err, "stuck: context deadline exceeded")
+	require.ErrorContains(t, err, "hung: context deadline exceeded")
+	require.NotContains(t, err.Error(), "pool:")
+	require.Equal(t, []string{"pool", "exporter"}, closed)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, "warn", "json")
+	require.NoError(t, err)
+	l.Info("hidden")
+	l.Warn("shown", "k", 1)
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	require.Equal(t, "shown", lines[0]["msg"])
+
+	buf.Reset()
+	l, err = New(&buf, "DEBUG", "text")
+	require.NoError(t, err)
+	l.Debug("hello", "k", "v")
+	require.Contains(t, buf.String(), "msg=hello k=v")
+
+	_, err = New(&buf, "loud", "json")
+	require.Error(t, err)
+	_, err = New(&buf, "info", "xml")
+	require.Error(t, err)
+}
+
+func TestFromContext_DefaultsToSlogDefault(t *testing.T) {
+	require.NotNil(t, FromContext(context.Background()))
+
+	l, err := New(&bytes.Buffer{}, "info", "json")
+	require.NoError(t, err)
+	require.Same(t, l, FromContext(WithLogger(context.Background(), l)))
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	h.ServeHTTP(rr, req)
+	require.Equal(t, "abc-123", seen)
+	require.Equal(t, "abc-123", rr.Header().Get(RequestIDHeader))
+
+	for _, bad := range []string{"", "has space", strings.Repeat("x", maxRequestIDLen+1), "bad\x7f"} {
+		rr = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, bad)
+		h.ServeHTTP(rr, req)
+		require.Len(t, seen, 32, bad)
+		require.Equal(t, seen, rr.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, "info", "json")
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(AccessLog(l))
+	r.Get("/notes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("inside")
+		_, _ = w.Write([]byte("hello"))
+	})
+	r.Get("/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/notes/7", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 3)
+	require.Equal(t, "inside", lines[0]["msg"])
+	require.Equal(t, "req-1", lines[0]["request_id"])
+
+	access := lines[1]
+	require.Equal(t, "request", access["msg"])
+	require.Equal(t, "INFO", access["level"])
+	require.Equal(t, "req-1", access["request_id"])
+	require.Equal(t, "GET", access["method"])
+	require.Equal(t, "/notes/{id}", access["route"])
+	require.Equal(t, "/notes/7", access["path"])
+	require.Equal(t, float64(200), access["status"])
+	require.Equal(t, float64(5), access["bytes"])
+	require.Contains(t, access, "duration_ms")
+
+	require.Equal(t, "ERROR", lines[2]["level"])
+	require.Equal(t, float64(500), lines[2]["status"])
+}
+
+func TestQueryHook(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, "debug", "json")
+	require.NoError(t, err)
+	ctx := WithLogger(context.Background(), l.With("request_id", "req-1"))
+
+	h := QueryHook{}
+	h.AfterQuery(h.BeforeQuery(ctx, "notes.get"), notes.QueryEvent{Name: "notes.get", Err: sql.ErrNoRows, Duration: time.Millisecond})
+	h.AfterQuery(ctx, notes.QueryEvent{Name: "notes.list_first", Rows: 3, Err: context.Canceled})
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 2)
+	require.Equal(t, "query", lines[0]["msg"])
+	require.Equal(t, "req-1", lines[0]["request_id"])
+	require.Equal(t, "notes.get", lines[0]["statement"])
+	require.NotContains(t, lines[0], "error")
+	require.Equal(t, float64(3), lines[1]["rows"])
+	require.Equal(t, "context canceled", lines[1]["error"])
+
+	buf.Reset()
+	l, err = New(&buf, "info", "json")
+	require.NoError(t, err)
+	h.AfterQuery(WithLogger(context.Background(), l), notes.QueryEvent{Name: "notes.get"})
+	require.Empty(t, buf.String())
+}
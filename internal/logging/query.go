@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// QueryHook logs every repository statement at debug level with the logger
+// from the statement's context, so query logs carry the request ID.
+type QueryHook struct{}
+
+var _ notes.QueryHook = QueryHook{}
+
+func (QueryHook) BeforeQuery(ctx context.Context, _ string) context.Context { return ctx }
+
+func (QueryHook) AfterQuery(ctx context.Context, ev notes.QueryEvent) {
+	l := FromContext(ctx)
+	if !l.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("statement", ev.Name),
+		slog.Int64("rows", ev.Rows),
+		slog.Float64("duration_ms", float64(ev.Duration.Microseconds())/1000),
+	}
+	if ev.Err != nil && !errors.Is(ev.Err, sql.ErrNoRows) {
+		attrs = append(attrs, slog.String("error", ev.Err.Error()))
+	}
+	l.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+}
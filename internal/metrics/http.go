@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// HTTPMetrics counts requests and their latency per chi route pattern.
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewHTTPMetrics(r *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: r.NewCounterVec("http_requests_total",
+			"HTTP requests by method, chi route pattern and status code.", "method", "route", "status"),
+		duration: r.NewHistogramVec("http_request_duration_seconds",
+			"HTTP request latency by method, chi route pattern and status code.", DefBuckets, "method", "route", "status"),
+	}
+}
+
+// Middleware must be installed on the root chi router: the route pattern is
+// read after the request was routed, so it includes mounted sub-routers.
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := RoutePattern(r)
+		// A mount point without a matching route inside it reports "/*".
+		if (status == http.StatusNotFound || status == http.StatusMethodNotAllowed) && strings.HasSuffix(route, "/*") {
+			route = "unmatched"
+		}
+		labels := []string{r.Method, route, strconv.Itoa(status)}
+		m.requests.Inc(labels...)
+		m.duration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+// RoutePattern returns the matched chi route pattern, or "unmatched" so
+// that unknown paths do not create a label value per URL.
+func RoutePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if p := rctx.RoutePattern(); p != "" {
+			return p
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	b, err := io.ReadAll(rr.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestRegistry_Exposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("jobs_total", "Jobs done.", "kind")
+	c.Inc("a")
+	c.Add(2, `q"x`)
+	h := r.NewHistogramVec("job_seconds", "Job latency.", []float64{1, 0.5}, "kind")
+	h.Observe(0.5, "a")
+	h.Observe(3, "a")
+	r.NewGaugeFunc("workers", "Workers.", func() float64 { return 4 })
+
+	out := scrape(t, r)
+	require.Contains(t, out, "# HELP jobs_total Jobs done.\n# TYPE jobs_total counter\n")
+	require.Contains(t, out, `jobs_total{kind="a"} 1`+"\n")
+	require.Contains(t, out, `jobs_total{kind="q\"x"} 2`+"\n")
+	require.Contains(t, out, "# TYPE job_seconds histogram\n")
+	require.Contains(t, out, `job_seconds_bucket{kind="a",le="0.5"} 1`+"\n")
+	require.Contains(t, out, `job_seconds_bucket{kind="a",le="1"} 1`+"\n")
+	require.Contains(t, out, `job_seconds_bucket{kind="a",le="+Inf"} 2`+"\n")
+	require.Contains(t, out, `job_seconds_sum{kind="a"} 3.5`+"\n")
+	require.Contains(t, out, `job_seconds_count{kind="a"} 2`+"\n")
+	require.Contains(t, out, "# TYPE workers gauge\nworkers 4\n")
+
+	require.Panics(t, func() { r.NewCounterVec("jobs_total", "dup") })
+	require.Panics(t, func() { c.Inc() })
+}
+
+func TestHTTPMetrics_RoutePattern(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	sub := chi.NewRouter()
+	sub.Get("/notes/{id}", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })
+	root := chi.NewRouter()
+	root.Use(m.Middleware)
+	root.Mount("/", sub)
+
+	for _, p := range []string{"/notes/1", "/notes/2", "/nope/x"} {
+		root.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	require.Equal(t, float64(2), m.requests.Value("GET", "/notes/{id}", "404"))
+	require.Equal(t, uint64(2), m.duration.Count("GET", "/notes/{id}", "404"))
+	require.Equal(t, float64(1), m.requests.Value("GET", "unmatched", "404"))
+}
+
+type errStore struct{ notes.Store }
+
+func (errStore) Get(_ context.Context, id int64) (notes.Note, error) {
+	if id == 1 {
+		return notes.Note{}, sql.ErrNoRows
+	}
+	return notes.Note{}, errors.New("boom")
+}
+
+func TestStoreMetrics_Instrument(t *testing.T) {
+	m := NewStoreMetrics(NewRegistry())
+	s := m.Instrument(errStore{})
+
+	_, err := s.Get(context.Background(), 1)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = s.Get(context.Background(), 2)
+	require.Error(t, err)
+
+	require.Equal(t, uint64(2), m.duration.Count("Get"))
+	require.Equal(t, float64(1), m.errors.Value("Get"))
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"context"
+
+	"example.com/notes-api-pz14/internal/overload"
+)
+
+// RegisterGuard exposes the circuit breaker state (0 closed, 1 half-open,
+// 2 open), the concurrency limit and the calls shed by g. It sets g's OnShed.
+func RegisterGuard(r *Registry, g *overload.Guard) {
+	if b := g.Breaker(); b != nil {
+		r.NewGaugeFunc("store_breaker_state", "Circuit breaker state: 0 closed, 1 half-open, 2 open.",
+			func() float64 { return float64(b.State()) })
+	}
+	if l := g.Limiter(); l != nil {
+		r.NewGaugeFunc("store_concurrency_limit", "Adaptive limit of concurrent store calls.",
+			func() float64 { return float64(l.Limit()) })
+		r.NewGaugeFunc("store_in_flight", "Store calls admitted by the concurrency limit and not finished.",
+			func() float64 { return float64(l.InFlight()) })
+	}
+	shed := r.NewCounterVec("store_shed_total", "Store calls rejected with 503, by reason (breaker, limit).", "reason")
+	g.OnShed(func(_ context.Context, err *overload.ShedError) { shed.Inc(err.Reason) })
+}
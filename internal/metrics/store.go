@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// StoreMetrics records latency and errors of notes.Store methods.
+type StoreMetrics struct {
+	duration *HistogramVec
+	errors   *CounterVec
+}
+
+func NewStoreMetrics(r *Registry) *StoreMetrics {
+	return &StoreMetrics{
+		duration: r.NewHistogramVec("store_query_duration_seconds",
+			"Latency of notes.Store methods.", DefBuckets, "method"),
+		errors: r.NewCounterVec("store_query_errors_total",
+			"Failed notes.Store calls; not found is not an error.", "method"),
+	}
+}
+
+func (m *StoreMetrics) observe(method string, start time.Time, err error) {
+	m.duration.Observe(time.Since(start).Seconds(), method)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		m.errors.Inc(method)
+	}
+}
+
+// Instrument wraps s so that every call is measured.
+func (m *StoreMetrics) Instrument(s notes.Store) notes.Store {
+	return &instrumentedStore{next: s, m: m}
+}
+
+type instrumentedStore struct {
+	next notes.Store
+	m    *StoreMetrics
+}
+
+func (s *instrumentedStore) Create(ctx context.Context, title, content, language string) (notes.Note, error) {
+	start := time.Now()
+	n, err := s.next.Create(ctx, title, content, language)
+	s.m.observe("Create", start, err)
+	return n, err
+}
+
+func (s *instrumentedStore) Get(ctx context.Context, id int64) (notes.Note, error) {
+	start := time.Now()
+	n, err := s.next.Get(ctx, id)
+	s.m.observe("Get", start, err)
+	return n, err
+}
+
+func (s *instrumentedStore) Update(ctx context.Context, id int64, title, content, language string) (notes.Note, error) {
+	start := time.Now()
+	n, err := s.next.Update(ctx, id, title, content, language)
+	s.m.observe("Update", start, err)
+	return n, err
+}
+
+func (s *instrumentedStore) Delete(ctx context.Context, id int64) error {
+	start := time.Now()
+	err := s.next.Delete(ctx, id)
+	s.m.observe("Delete", start, err)
+	return err
+}
+
+func (s *instrumentedStore) List(ctx context.Context, p notes.ListParams) ([]notes.Note, error) {
+	start := time.Now()
+	items, err := s.next.List(ctx, p)
+	s.m.observe("List", start, err)
+	return items, err
+}
+
+func (s *instrumentedStore) BatchGet(ctx context.Context, ids []int64) ([]notes.Note, error) {
+	start := time.Now()
+	items, err := s.next.BatchGet(ctx, ids)
+	s.m.observe("BatchGet", start, err)
+	return items, err
+}
+
+func (s *instrumentedStore) Suggest(ctx context.Context, prefix string, limit int) ([]notes.Suggestion, error) {
+	start := time.Now()
+	items, err := s.next.Suggest(ctx, prefix, limit)
+	s.m.observe("Suggest", start, err)
+	return items, err
+}
+
+func (s *instrumentedStore) Related(ctx context.Context, id int64, limit int) ([]notes.RelatedNote, error) {
+	start := time.Now()
+	items, err := s.next.Related(ctx, id, limit)
+	s.m.observe("Related", start, err)
+	return items, err
+}
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrUsage is returned by ParseCommand for malformed arguments.
+var ErrUsage = errors.New("migrate: invalid arguments")
+
+// CommandUsage documents the arguments accepted by ParseCommand.
+const CommandUsage = `commands:
+  status          show applied, pending and modified migrations
+  up [version]    apply pending migrations (up to version)
+  down [steps]    revert the last applied migrations (default 1)
+  redo            revert and re-apply the last applied migration
+`
+
+// Command is a parsed "migrate" subcommand shared by the api and notesctl
+// binaries.
+type Command struct {
+	Name string
+	// N is the target version of up (0 = latest) or the steps of down.
+	N int64
+}
+
+// ParseCommand parses "status", "up [version]", "down [steps]" or "redo".
+func ParseCommand(args []string) (Command, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return Command{}, ErrUsage
+	}
+	c := Command{Name: args[0]}
+	switch c.Name {
+	case "status", "redo":
+		if len(args) == 2 {
+			return Command{}, ErrUsage
+		}
+	case "up", "down":
+		if len(args) == 2 {
+			v, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || v <= 0 {
+				return Command{}, ErrUsage
+			}
+			c.N = v
+		}
+		if c.Name == "down" && c.N == 0 {
+			c.N = 1
+		}
+	default:
+		return Command{}, ErrUsage
+	}
+	return c, nil
+}
+
+// Run executes the command with m and writes its result to w.
+func (c Command) Run(ctx context.Context, m *Migrator, w io.Writer) error {
+	switch c.Name {
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, FormatStatus(status))
+		return err
+	case "up":
+		done, err := m.Up(ctx, c.N)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "applied %d migration(s)\n", len(done))
+		return err
+	case "down":
+		done, err := m.Down(ctx, int(c.N))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "reverted %d migration(s)\n", len(done))
+		return err
+	case "redo":
+		v, err := m.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "redone %d\n", v)
+		return err
+	}
+	return ErrUsage
+}
@@ -0,0 +1,109 @@
+// Package migrate applies the SQL migrations embedded in the migrations
+// package and records them, with checksums, in schema_migrations.
+//
+// Files are named NNN_name.sql (up) and NNN_name.down.sql (down, optional).
+// A file containing the line "-- migrate:no-transaction" runs statement by
+// statement outside a transaction, for statements such as CALL of procedures
+// that COMMIT or CREATE INDEX CONCURRENTLY.
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NoTransaction marks a migration that must not run in a transaction.
+const NoTransaction = "-- migrate:no-transaction"
+
+// Migration is one schema version.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	// Down is empty if the migration cannot be reverted.
+	Down string
+	// Checksum is the SHA-256 of Up; editing an applied file changes it.
+	Checksum string
+}
+
+// NoTx reports whether the up migration must run outside a transaction.
+func (m Migration) NoTx() bool { return hasDirective(m.Up) }
+
+func hasDirective(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.TrimSpace(line) == NoTransaction {
+			return true
+		}
+	}
+	return false
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+?)(\.down)?\.sql$`)
+
+// Load reads migrations from the root of fsys, ordered by version. Files
+// that are not *.sql are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	downs := map[int64]string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("migrate: invalid migration file name %q", e.Name())
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: invalid version in %q", e.Name())
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if m[3] != "" {
+			if _, dup := downs[version]; dup {
+				return nil, fmt.Errorf("migrate: duplicate down migration %d", version)
+			}
+			downs[version] = string(b)
+			continue
+		}
+		if _, dup := byVersion[version]; dup {
+			return nil, fmt.Errorf("migrate: duplicate migration %d", version)
+		}
+		sum := sha256.Sum256(b)
+		byVersion[version] = &Migration{
+			Version:  version,
+			Name:     m[2],
+			Up:       string(b),
+			Checksum: hex.EncodeToString(sum[:]),
+		}
+	}
+
+	for v, down := range downs {
+		m, ok := byVersion[v]
+		if !ok {
+			return nil, fmt.Errorf("migrate: down migration %d without up migration", v)
+		}
+		m.Down = down
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
@@ -0,0 +1,145 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func file(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+func TestLoad(t *testing.T) {
+	list, err := Load(fstest.MapFS{
+		"010_later.sql":     file("SELECT 10;"),
+		"002_second.sql":    file("-- migrate:no-transaction\nCALL p();"),
+		"001_init.sql":      file("CREATE TABLE t (id int);"),
+		"001_init.down.sql": file("DROP TABLE t;"),
+		"migrations.go":     file("package migrations"),
+		"README.md":         file("docs"),
+	})
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	require.Equal(t, int64(1), list[0].Version)
+	require.Equal(t, "init", list[0].Name)
+	require.Equal(t, "DROP TABLE t;", list[0].Down)
+	require.Len(t, list[0].Checksum, 64)
+	require.False(t, list[0].NoTx())
+
+	require.Equal(t, int64(2), list[1].Version)
+	require.Empty(t, list[1].Down)
+	require.True(t, list[1].NoTx())
+	require.Equal(t, int64(10), list[2].Version)
+
+	again, err := Load(fstest.MapFS{"001_init.sql": file("CREATE TABLE t (id int);")})
+	require.NoError(t, err)
+	require.Equal(t, list[0].Checksum, again[0].Checksum)
+	edited, err := Load(fstest.MapFS{"001_init.sql": file("CREATE TABLE t (id bigint);")})
+	require.NoError(t, err)
+	require.NotEqual(t, list[0].Checksum, edited[0].Checksum)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":     {"init.sql": file("")},
+		"upper case":   {"001_Init.sql": file("")},
+		"zero version": {"000_init.sql": file("")},
+		"duplicate":    {"001_a.sql": file(""), "1_b.sql": file("")},
+		"dup down":     {"001_a.sql": file(""), "001_a.down.sql": file(""), "1_a.down.sql": file("")},
+		"orphan down":  {"001_a.sql": file(""), "002_b.down.sql": file("")},
+	} {
+		_, err := Load(fsys)
+		require.Error(t, err, name)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	script := `-- header comment; with semicolon
+CREATE OR REPLACE PROCEDURE p(n INT DEFAULT 5)
+LANGUAGE plpgsql AS $$
+BEGIN
+  UPDATE t SET v = 'a;b' WHERE n = 1;
+  COMMIT;
+END
+$$;
+
+CALL p(5000);
+/* block; comment */
+SELECT $tag$ ; $$ ; $tag$, "odd;ident", 'it''s;', $1;
+-- trailing comment only
+`
+	stmts := Split(script)
+	require.Len(t, stmts, 3)
+	require.Contains(t, stmts[0], "COMMIT;\nEND\n$$")
+	require.Equal(t, "CALL p(5000)", stmts[1])
+	require.Contains(t, stmts[2], `SELECT $tag$ ; $$ ; $tag$, "odd;ident", 'it''s;', $1`)
+
+	require.Empty(t, Split("  ;\n-- nothing\n;"))
+}
+
+func TestPlan(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []Migration{
+		{Version: 1, Name: "init", Checksum: "c1"},
+		{Version: 2, Name: "two", Checksum: "c2"},
+		{Version: 3, Name: "three", Checksum: "c3"},
+		{Version: 4, Name: "four", Checksum: "c4"},
+	}
+	rows := []applied{
+		{version: 1, checksum: sql.NullString{String: "c1", Valid: true}, appliedAt: at},
+		// Applied by hand before checksums were recorded.
+		{version: 2, appliedAt: at},
+		{version: 3, checksum: sql.NullString{String: "old", Valid: true}, appliedAt: at},
+		{version: 7, checksum: sql.NullString{String: "c7", Valid: true}, appliedAt: at},
+	}
+
+	got := plan(files, rows)
+	states := map[int64]string{}
+	for _, s := range got {
+		states[s.Version] = s.State
+	}
+	require.Equal(t, map[int64]string{
+		1: StateApplied, 2: StateApplied, 3: StateModified, 4: StatePending, 7: StateMissing,
+	}, states)
+	require.Equal(t, []int64{3}, versionsIn(got, StateModified))
+	require.Nil(t, got[3].AppliedAt)
+	require.Equal(t, at, *got[0].AppliedAt)
+
+	out := FormatStatus(got)
+	require.Contains(t, out, "VERSION")
+	require.Contains(t, out, "3        modified   2026-01-01T00:00:00Z      three")
+	require.Contains(t, out, "4        pending    -")
+}
+
+func TestMigrator_Latest(t *testing.T) {
+	require.Equal(t, int64(0), New(nil, nil).Latest())
+	require.Equal(t, int64(4), New(nil, []Migration{{Version: 1}, {Version: 4}}).Latest())
+}
+
+func TestParseCommand(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want Command
+	}{
+		{[]string{"status"}, Command{Name: "status"}},
+		{[]string{"up"}, Command{Name: "up"}},
+		{[]string{"up", "5"}, Command{Name: "up", N: 5}},
+		{[]string{"down"}, Command{Name: "down", N: 1}},
+		{[]string{"down", "3"}, Command{Name: "down", N: 3}},
+		{[]string{"redo"}, Command{Name: "redo"}},
+	} {
+		got, err := ParseCommand(tc.args)
+		require.NoError(t, err, tc.args)
+		require.Equal(t, tc.want, got)
+	}
+
+	for _, args := range [][]string{
+		nil, {"sideways"}, {"status", "1"}, {"redo", "1"}, {"up", "x"}, {"down", "0"}, {"up", "1", "2"},
+	} {
+		_, err := ParseCommand(args)
+		require.ErrorIs(t, err, ErrUsage, args)
+	}
+}
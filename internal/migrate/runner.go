@@ -0,0 +1,327 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lockID is the pg_advisory_lock key held while migrating, so instances
+// starting together do not apply migrations concurrently.
+const lockID int64 = 0x6e6f746573 // "notes"
+
+// State of a migration in Status.
+const (
+	StatePending  = "pending"
+	StateApplied  = "applied"
+	StateModified = "modified" // applied, but the file changed since
+	StateMissing  = "missing"  // applied, but no such file
+)
+
+// ErrModified is returned by Up when applied migration files were edited.
+var ErrModified = errors.New("migrate: applied migrations were modified")
+
+// Status describes one migration version.
+type Status struct {
+	Version   int64
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+type applied struct {
+	version   int64
+	checksum  sql.NullString
+	appliedAt time.Time
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// Logf reports progress; nil means silent.
+	Logf func(format string, args ...any)
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest returns the highest known version.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+const (
+	// Versions recorded by hand-applied migrations have no checksum; it is
+	// filled in by the next run.
+	qEnsureTable = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		  version BIGINT PRIMARY KEY,
+		  applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS name TEXT;
+		ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum TEXT;`
+
+	qApplied = `SELECT version, checksum, applied_at FROM schema_migrations ORDER BY version`
+
+	qRecord = `
+		INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+		ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, checksum = EXCLUDED.checksum, applied_at = now()`
+
+	qAdopt = `UPDATE schema_migrations SET name = $2, checksum = $3 WHERE version = $1 AND checksum IS NULL`
+
+	qForget = `DELETE FROM schema_migrations WHERE version = $1`
+)
+
+// Status compares the migration files with schema_migrations.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, qEnsureTable); err != nil {
+		return nil, err
+	}
+	rows, err := loadApplied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return plan(m.migrations, rows), nil
+}
+
+// plan merges files and applied versions, ordered by version.
+func plan(files []Migration, rows []applied) []Status {
+	byVersion := map[int64]applied{}
+	for _, a := range rows {
+		byVersion[a.version] = a
+	}
+	known := map[int64]bool{}
+
+	var out []Status
+	for _, f := range files {
+		known[f.Version] = true
+		st := Status{Version: f.Version, Name: f.Name, State: StatePending}
+		if a, ok := byVersion[f.Version]; ok {
+			at := a.appliedAt
+			st.AppliedAt = &at
+			st.State = StateApplied
+			if a.checksum.Valid && a.checksum.String != f.Checksum {
+				st.State = StateModified
+			}
+		}
+		out = append(out, st)
+	}
+	for _, a := range rows {
+		if !known[a.version] {
+			at := a.appliedAt
+			out = append(out, Status{Version: a.version, State: StateMissing, AppliedAt: &at})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
+
+// Up applies pending migrations up to target (0 means all) and returns the
+// applied versions. It refuses to run if applied files were modified.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]int64, error) {
+	var done []int64
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		rows, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		status := plan(m.migrations, rows)
+		if mod := versionsIn(status, StateModified); len(mod) > 0 {
+			return fmt.Errorf("%w: %v", ErrModified, mod)
+		}
+
+		byVersion := map[int64]applied{}
+		for _, a := range rows {
+			byVersion[a.version] = a
+		}
+		for _, mig := range m.migrations {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if a, ok := byVersion[mig.Version]; ok {
+				if !a.checksum.Valid {
+					if _, err := conn.ExecContext(ctx, qAdopt, mig.Version, mig.Name, mig.Checksum); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			m.logf("migrate: applying %03d_%s", mig.Version, mig.Name)
+			if err := apply(ctx, conn, mig.Up, mig.NoTx(), func(ex execer) error {
+				_, err := ex.ExecContext(ctx, qRecord, mig.Version, mig.Name, mig.Checksum)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migrate: %03d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig.Version)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations and returns the reverted
+// versions. It stops with an error at a migration without a down file.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]int64, error) {
+	var done []int64
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		rows, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		byVersion := map[int64]Migration{}
+		for _, mig := range m.migrations {
+			byVersion[mig.Version] = mig
+		}
+
+		for i := len(rows) - 1; i >= 0 && len(done) < steps; i-- {
+			v := rows[i].version
+			mig, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("migrate: applied version %d has no migration file", v)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migrate: %03d_%s cannot be reverted", v, mig.Name)
+			}
+			m.logf("migrate: reverting %03d_%s", v, mig.Name)
+			if err := apply(ctx, conn, mig.Down, hasDirective(mig.Down), func(ex execer) error {
+				_, err := ex.ExecContext(ctx, qForget, v)
+				return err
+			}); err != nil {
+				return fmt.Errorf("migrate: revert %03d_%s: %w", v, mig.Name, err)
+			}
+			done = append(done, v)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Redo reverts and re-applies the last applied migration.
+func (m *Migrator) Redo(ctx context.Context) (int64, error) {
+	down, err := m.Down(ctx, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(down) == 0 {
+		return 0, errors.New("migrate: nothing to redo")
+	}
+	if _, err := m.Up(ctx, down[0]); err != nil {
+		return 0, err
+	}
+	return down[0], nil
+}
+
+// locked runs fn on a single connection holding the migration advisory lock.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	defer func() {
+		// The lock is released with the session anyway if this fails.
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockID)
+	}()
+
+	if _, err := conn.ExecContext(ctx, qEnsureTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// apply runs script and then record, in one transaction unless noTx. A
+// no-transaction script that fails halfway is not recorded and runs again
+// from the start, so its statements must be idempotent.
+func apply(ctx context.Context, conn *sql.Conn, script string, noTx bool, record func(execer) error) error {
+	if noTx {
+		for _, stmt := range Split(script) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return record(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func loadApplied(ctx context.Context, conn *sql.Conn) ([]applied, error) {
+	rows, err := conn.QueryContext(ctx, qApplied)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []applied
+	for rows.Next() {
+		var a applied
+		if err := rows.Scan(&a.version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func versionsIn(status []Status, state string) []int64 {
+	var out []int64
+	for _, s := range status {
+		if s.State == state {
+			out = append(out, s.Version)
+		}
+	}
+	return out
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
+
+// FormatStatus renders status as a table for the migrate command.
+func FormatStatus(status []Status) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-8s %-10s %-25s %s\n", "VERSION", "STATE", "APPLIED AT", "NAME")
+	for _, s := range status {
+		at := "-"
+		if s.AppliedAt != nil {
+			at = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "%-8d %-10s %-25s %s\n", s.Version, s.State, at, s.Name)
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import "strings"
+
+// Split splits a script into statements at top-level semicolons, respecting
+// quoted strings and identifiers, dollar-quoted bodies and comments. Empty
+// statements are dropped.
+func Split(script string) []string {
+	var out []string
+	start := 0
+	add := func(end int) {
+		if s := strings.TrimSpace(script[start:end]); s != "" && !onlyComments(s) {
+			out = append(out, s)
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			i = skipTo(script, i, "\n")
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			i = skipTo(script, i+2, "*/")
+		case c == '\'' || c == '"':
+			i = skipQuoted(script, i, c)
+		case c == '$':
+			if tag, ok := dollarTag(script[i:]); ok {
+				i = skipTo(script, i+len(tag), tag)
+			}
+		case c == ';':
+			add(i)
+			start = i + 1
+		}
+	}
+	add(len(script))
+	return out
+}
+
+// skipTo returns the index of the last byte of the first end at or after
+// from, or the end of s.
+func skipTo(s string, from int, end string) int {
+	j := strings.Index(s[from:], end)
+	if j < 0 {
+		return len(s) - 1
+	}
+	return from + j + len(end) - 1
+}
+
+// skipQuoted skips a quoted literal; doubled quotes are escapes.
+func skipQuoted(s string, i int, q byte) int {
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == q {
+			if j+1 < len(s) && s[j+1] == q {
+				j++
+				continue
+			}
+			return j
+		}
+	}
+	return len(s) - 1
+}
+
+// dollarTag returns the opening tag ("$$" or "$name$") at the start of s.
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		if c == '$' {
+			return s[:j+1], true
+		}
+		isIdent := c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || (j > 1 && c >= '0' && c <= '9')
+		if !isIdent {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func onlyComments(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
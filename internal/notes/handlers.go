@@ -4,36 +4,83 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type Handlers struct {
-	store Store
+	store     Store
+	saved     SavedSearchStore
+	highlight HighlightOptions
+	related   *relatedCache
+	health    func(context.Context) error
+	// lookupHost resolves webhook hosts to check they are public.
+	lookupHost lookupHost
 }
 
 // Store is an abstraction over the notes storage.
 // It allows unit-testing handlers without a real database.
 type Store interface {
-	Create(ctx context.Context, title, content string) (Note, error)
+	Create(ctx context.Context, title, content, language string) (Note, error)
 	Get(ctx context.Context, id int64) (Note, error)
-	Update(ctx context.Context, id int64, title, content string) (Note, error)
+	Update(ctx context.Context, id int64, title, content, language string) (Note, error)
 	Delete(ctx context.Context, id int64) error
 	List(ctx context.Context, p ListParams) ([]Note, error)
 	BatchGet(ctx context.Context, ids []int64) ([]Note, error)
+	Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error)
+	Related(ctx context.Context, id int64, limit int) ([]RelatedNote, error)
 }
 
 func NewHandlers(store Store) *Handlers {
-	return &Handlers{store: store}
+	return &Handlers{
+		store:     store,
+		highlight: DefaultHighlightOptions,
+		related:   newRelatedCache(time.Minute, 1000),
+
+		lookupHost: defaultLookupHost,
+	}
+}
+
+// WithHealthCheck makes /health report 503 while check returns an error.
+func (h *Handlers) WithHealthCheck(check func(context.Context) error) *Handlers {
+	h.health = check
+	return h
+}
+
+// WithSavedSearches enables the /saved-searches routes.
+func (h *Handlers) WithSavedSearches(s SavedSearchStore) *Handlers {
+	h.saved = s
+	return h
+}
+
+// WithRelatedCache configures caching of related-notes results.
+// A zero ttl disables the cache.
+func (h *Handlers) WithRelatedCache(ttl time.Duration, size int) *Handlers {
+	h.related = newRelatedCache(ttl, size)
+	return h
+}
+
+// WithHighlight sets how search snippets are built.
+func (h *Handlers) WithHighlight(o HighlightOptions) *Handlers {
+	h.highlight = o.normalize()
+	return h
 }
 
 func (h *Handlers) Routes() http.Handler {
 	r := chi.NewRouter()
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		if h.health != nil {
+			if err := h.health(r.Context()); err != nil {
+				writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "error": err.Error()})
+				return
+			}
+		}
 		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 	})
 
@@ -41,33 +88,55 @@ func (h *Handlers) Routes() http.Handler {
 		r.Post("/", h.create)
 		r.Get("/", h.list)
 		r.Post("/batch", h.batch)
+		r.Get("/suggest", h.suggest)
 
 		r.Route("/{id}", func(r chi.Router) {
 			r.Get("/", h.get)
 			r.Put("/", h.update)
 			r.Delete("/", h.delete)
+			r.Get("/related", h.relatedNotes)
 		})
 	})
 
+	if h.saved != nil {
+		r.Route("/saved-searches", func(r chi.Router) {
+			r.Post("/", h.createSavedSearch)
+			r.Get("/", h.listSavedSearches)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Get("/", h.getSavedSearch)
+				r.Delete("/", h.deleteSavedSearch)
+				r.Get("/results", h.savedSearchResults)
+				r.Get("/inbox", h.savedSearchInbox)
+				r.Post("/inbox/read", h.markSavedSearchInboxRead)
+			})
+		})
+	}
+
 	return r
 }
 
 func (h *Handlers) create(w http.ResponseWriter, r *http.Request) {
 	var req CreateNoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 	if req.Title == "" || req.Content == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "title and content required"})
 		return
 	}
+	lang, ok := resolveLanguage(req.Language, req.Title, req.Content)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported language"})
+		return
+	}
 
-	n, err := h.store.Create(r.Context(), req.Title, req.Content)
+	n, err := h.store.Create(r.Context(), req.Title, req.Content, lang)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeStoreError(w, err)
 		return
 	}
+	h.related.invalidate()
 	writeJSON(w, http.StatusCreated, n)
 }
 
@@ -84,7 +153,7 @@ func (h *Handlers) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeStoreError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, n)
@@ -98,24 +167,29 @@ func (h *Handlers) update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req UpdateNoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 	if req.Title == "" || req.Content == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "title and content required"})
 		return
 	}
+	lang, ok := resolveLanguage(req.Language, req.Title, req.Content)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported language"})
+		return
+	}
 
-	n, err := h.store.Update(r.Context(), id, req.Title, req.Content)
+	n, err := h.store.Update(r.Context(), id, req.Title, req.Content, lang)
 	if err == sql.ErrNoRows {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 		return
 	}
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeStoreError(w, err)
 		return
 	}
+	h.related.invalidate()
 	writeJSON(w, http.StatusOK, n)
 }
 
@@ -130,15 +204,43 @@ func (h *Handlers) delete(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 		return
 	} else if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeStoreError(w, err)
 		return
 	}
+	h.related.invalidate()
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *Handlers) list(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("q")
 
+	lang := r.URL.Query().Get("lang")
+	if lang != "" && !SupportedLanguage(lang) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported language"})
+		return
+	}
+	if lang == "" && q != "" {
+		lang = DetectLanguage(q)
+	}
+
+	p := pageParams(r)
+	p.Query = q
+	p.Language = lang
+	p.Fuzzy = isTrue(r.URL.Query().Get("fuzzy"))
+	p.Highlight = h.highlight
+
+	items, err := h.store.List(r.Context(), p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	// Fuzzy results are ranked by similarity and ignore the cursor: there is
+	// a single page of the best matches.
+	h.writeNotesPage(w, items, q, !p.Fuzzy)
+}
+
+// pageParams parses limit and keyset cursor query parameters.
+func pageParams(r *http.Request) ListParams {
 	limit := 20
 	if s := r.URL.Query().Get("limit"); s != "" {
 		if v, err := strconv.Atoi(s); err == nil {
@@ -160,19 +262,23 @@ func (h *Handlers) list(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	items, err := h.store.List(r.Context(), ListParams{
-		Limit:           limit,
-		CursorCreatedAt: cursorAt,
-		CursorID:        cursorID,
-		Query:           q,
-	})
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+	return ListParams{Limit: limit, CursorCreatedAt: cursorAt, CursorID: cursorID}
+}
+
+// writeNotesPage writes a page of notes, with the cursor of the next page if
+// paged. Search results get snippets instead of the full content.
+func (h *Handlers) writeNotesPage(w http.ResponseWriter, items []Note, q string, paged bool) {
+	if q != "" {
+		for i := range items {
+			if items[i].Highlight == nil {
+				items[i].Highlight = fallbackHighlight(items[i], q, h.highlight)
+			}
+			items[i].Content = ""
+		}
 	}
 
 	resp := map[string]any{"items": items}
-	if len(items) > 0 {
+	if paged && len(items) > 0 {
 		last := items[len(items)-1]
 		resp["next_cursor_created_at"] = last.CreatedAt.Format(time.RFC3339Nano)
 		resp["next_cursor_id"] = last.ID
@@ -182,19 +288,119 @@ func (h *Handlers) list(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handlers) batch(w http.ResponseWriter, r *http.Request) {
 	var req BatchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	items, err := h.store.BatchGet(r.Context(), req.IDs)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *Handlers) relatedNotes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+
+	limit := 10
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v > 0 && v <= 50 {
+			limit = v
+		}
+	}
+
+	// limit is clamped like the stores do, so out-of-range values share one
+	// cache entry instead of filling the cache.
+	key := relatedKey{id: id, limit: limit}
+	if items, ok := h.related.get(key); ok {
+		w.Header().Set("X-Cache", "HIT")
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+		return
+	}
+
+	items, err := h.store.Related(r.Context(), id, limit)
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	h.related.put(key, items)
+	w.Header().Set("X-Cache", "MISS")
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *Handlers) suggest(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimSpace(r.URL.Query().Get("prefix"))
+	if prefix == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "prefix required"})
+		return
+	}
+
+	limit := 10
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			limit = v
+		}
+	}
+
+	items, err := h.store.Suggest(r.Context(), prefix, limit)
+	if err != nil {
+		writeStoreError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
+// resolveLanguage validates an explicit language or detects it from the note text.
+func resolveLanguage(lang, title, content string) (string, bool) {
+	if lang == "" {
+		return DetectLanguage(title + " " + content), true
+	}
+	return lang, SupportedLanguage(lang)
+}
+
+func isTrue(s string) bool {
+	v, err := strconv.ParseBool(s)
+	return err == nil && v
+}
+
+// decodeJSON decodes the request body into v. On failure it writes 400 and
+// returns false; bodies over the limit are answered by httpx.BodyLimit.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return false
+	}
+	return true
+}
+
+// retryAfterError is implemented by errors of stores that shed load (see
+// package overload): the request was not served, and may be retried later.
+type retryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// writeStoreError answers a failed store call: 503 with Retry-After for shed
+// calls, 500 otherwise.
+func writeStoreError(w http.ResponseWriter, err error) {
+	var ra retryAfterError
+	if errors.As(err, &ra) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(ra.RetryAfter().Seconds())))
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
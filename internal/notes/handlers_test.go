@@ -6,8 +6,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,32 +17,40 @@ import (
 )
 
 type stubStore struct {
-	createFn   func(context.Context, string, string) (Note, error)
+	createFn   func(context.Context, string, string, string) (Note, error)
 	getFn      func(context.Context, int64) (Note, error)
-	updateFn   func(context.Context, int64, string, string) (Note, error)
+	updateFn   func(context.Context, int64, string, string, string) (Note, error)
 	deleteFn   func(context.Context, int64) error
 	listFn     func(context.Context, ListParams) ([]Note, error)
 	batchGetFn func(context.Context, []int64) ([]Note, error)
+	suggestFn  func(context.Context, string, int) ([]Suggestion, error)
+	relatedFn  func(context.Context, int64, int) ([]RelatedNote, error)
 }
 
-func (s stubStore) Create(ctx context.Context, title, content string) (Note, error) {
-	return s.createFn(ctx, title, content)
+func (s stubStore) Create(ctx context.Context, title, content, language string) (Note, error) {
+	return s.createFn(ctx, title, content, language)
 }
 func (s stubStore) Get(ctx context.Context, id int64) (Note, error) {
 	return s.getFn(ctx, id)
 }
-func (s stubStore) Update(ctx context.Context, id int64, title, content string) (Note, error) {
-	return s.updateFn(ctx, id, title, content)
+func (s stubStore) Update(ctx context.Context, id int64, title, content, language string) (Note, error) {
+	return s.updateFn(ctx, id, title, content, language)
 }
 func (s stubStore) Delete(ctx context.Context, id int64) error             { return s.deleteFn(ctx, id) }
 func (s stubStore) List(ctx context.Context, p ListParams) ([]Note, error) { return s.listFn(ctx, p) }
 func (s stubStore) BatchGet(ctx context.Context, ids []int64) ([]Note, error) {
 	return s.batchGetFn(ctx, ids)
 }
+func (s stubStore) Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error) {
+	return s.suggestFn(ctx, prefix, limit)
+}
+func (s stubStore) Related(ctx context.Context, id int64, limit int) ([]RelatedNote, error) {
+	return s.relatedFn(ctx, id, limit)
+}
 
 func TestHandlers_Create_Validation(t *testing.T) {
 	h := NewHandlers(stubStore{
-		createFn: func(context.Context, string, string) (Note, error) {
+		createFn: func(context.Context, string, string, string) (Note, error) {
 			return Note{}, nil
 		},
 	}).Routes()
@@ -56,7 +66,7 @@ func TestHandlers_Create_Validation(t *testing.T) {
 func TestHandlers_Create_Success(t *testing.T) {
 	created := Note{ID: 1, Title: "t", Content: "c", CreatedAt: time.Unix(1, 0).UTC()}
 	h := NewHandlers(stubStore{
-		createFn: func(context.Context, string, string) (Note, error) {
+		createFn: func(context.Context, string, string, string) (Note, error) {
 			return created, nil
 		},
 	}).Routes()
@@ -121,11 +131,38 @@ func TestHandlers_Get_Success_NotFound_And_Internal(t *testing.T) {
 	}
 }
 
+// shedErr is what a load-shedding store returns.
+type shedErr struct{ after time.Duration }
+
+func (e shedErr) Error() string             { return "overloaded" }
+func (e shedErr) RetryAfter() time.Duration { return e.after }
+
+func TestHandlers_StoreShedsLoad(t *testing.T) {
+	h := NewHandlers(stubStore{
+		getFn: func(context.Context, int64) (Note, error) {
+			return Note{}, fmt.Errorf("get: %w", shedErr{after: 5 * time.Second})
+		},
+		listFn: func(context.Context, ListParams) ([]Note, error) { return nil, shedErr{after: time.Second} },
+	}).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Equal(t, "5", rr.Header().Get("Retry-After"))
+
+	req = httptest.NewRequest(http.MethodGet, "/notes/", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+}
+
 func TestHandlers_Update_Delete_And_List(t *testing.T) {
 	fixed := time.Unix(3, 0).UTC()
 
 	store := stubStore{
-		updateFn: func(context.Context, int64, string, string) (Note, error) {
+		updateFn: func(context.Context, int64, string, string, string) (Note, error) {
 			return Note{ID: 1, Title: "t2", Content: "c2", CreatedAt: fixed}, nil
 		},
 		deleteFn: func(context.Context, int64) error { return nil },
@@ -135,7 +172,7 @@ func TestHandlers_Update_Delete_And_List(t *testing.T) {
 			return []Note{{ID: 2, Title: "a", Content: "b", CreatedAt: fixed}}, nil
 		},
 		batchGetFn: func(context.Context, []int64) ([]Note, error) { return []Note{}, nil },
-		createFn:   func(context.Context, string, string) (Note, error) { return Note{}, nil },
+		createFn:   func(context.Context, string, string, string) (Note, error) { return Note{}, nil },
 		getFn:      func(context.Context, int64) (Note, error) { return Note{}, nil },
 	}
 
@@ -184,9 +221,9 @@ func TestHandlers_Batch_InvalidJSON_And_Success(t *testing.T) {
 		batchGetFn: func(context.Context, []int64) ([]Note, error) {
 			return []Note{{ID: 1, Title: "t", Content: "c", CreatedAt: time.Unix(4, 0).UTC()}}, nil
 		},
-		createFn: func(context.Context, string, string) (Note, error) { return Note{}, nil },
+		createFn: func(context.Context, string, string, string) (Note, error) { return Note{}, nil },
 		getFn:    func(context.Context, int64) (Note, error) { return Note{}, nil },
-		updateFn: func(context.Context, int64, string, string) (Note, error) { return Note{}, nil },
+		updateFn: func(context.Context, int64, string, string, string) (Note, error) { return Note{}, nil },
 		deleteFn: func(context.Context, int64) error { return nil },
 		listFn:   func(context.Context, ListParams) ([]Note, error) { return []Note{}, nil },
 	}
@@ -210,3 +247,251 @@ func TestHandlers_Batch_InvalidJSON_And_Success(t *testing.T) {
 		require.Equal(t, http.StatusOK, rr.Code)
 	}
 }
+
+func TestHandlers_List_SearchHighlights(t *testing.T) {
+	fixed := time.Unix(5, 0).UTC()
+	store := stubStore{
+		listFn: func(_ context.Context, p ListParams) ([]Note, error) {
+			require.Equal(t, "[", p.Highlight.StartSel)
+			return []Note{
+				// store without snippets: handler falls back to stringsx
+				{ID: 2, Title: "Go notes", Content: "learning go every day", CreatedAt: fixed},
+				// store that already highlighted the note
+				{ID: 1, Title: "go", CreatedAt: fixed, Highlight: &Highlight{Title: "<go>", Content: "<go>"}},
+			}, nil
+		},
+	}
+	h := NewHandlers(store).WithHighlight(HighlightOptions{MaxWords: 3, StartSel: "[", StopSel: "]"}).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/notes?q=go", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp struct {
+		Items []Note `json:"items"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Items, 2)
+	require.Empty(t, resp.Items[0].Content)
+	require.Equal(t, "[Go] notes", resp.Items[0].Highlight.Title)
+	require.Equal(t, "learning [go] every", resp.Items[0].Highlight.Content)
+	require.Equal(t, "<go>", resp.Items[1].Highlight.Title)
+}
+
+func TestHandlers_List_Fuzzy(t *testing.T) {
+	h := NewHandlers(stubStore{
+		listFn: func(_ context.Context, p ListParams) ([]Note, error) {
+			require.True(t, p.Fuzzy)
+			require.Equal(t, "titel", p.Query)
+			return []Note{{ID: 1, Title: "title", Content: "c", Score: 0.5}}, nil
+		},
+	}).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/notes?q=titel&fuzzy=1", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	var items []Note
+	require.NoError(t, json.Unmarshal(resp["items"], &items))
+	require.Equal(t, 0.5, items[0].Score)
+	// The fuzzy branch does not page, so no cursor is offered.
+	require.NotContains(t, resp, "next_cursor_created_at")
+	require.NotContains(t, resp, "next_cursor_id")
+}
+
+func TestHandlers_Suggest(t *testing.T) {
+	h := NewHandlers(stubStore{
+		suggestFn: func(_ context.Context, prefix string, limit int) ([]Suggestion, error) {
+			require.Equal(t, "ti", prefix)
+			require.Equal(t, 5, limit)
+			return []Suggestion{{ID: 1, Title: "title"}}, nil
+		},
+	}).Routes()
+
+	// missing prefix
+	{
+		req := httptest.NewRequest(http.MethodGet, "/notes/suggest", nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+
+	// success
+	{
+		req := httptest.NewRequest(http.MethodGet, "/notes/suggest?prefix=ti&limit=5", nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp struct {
+			Items []Suggestion `json:"items"`
+		}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Equal(t, []Suggestion{{ID: 1, Title: "title"}}, resp.Items)
+	}
+}
+
+func TestHandlers_Create_Language(t *testing.T) {
+	var gotLang string
+	h := NewHandlers(stubStore{
+		createFn: func(_ context.Context, _, _, lang string) (Note, error) {
+			gotLang = lang
+			return Note{ID: 1, Language: lang}, nil
+		},
+	}).Routes()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantLang string
+	}{
+		{"detected russian", `{"title":"Заметка","content":"про заметки"}`, http.StatusCreated, LangRussian},
+		{"detected english", `{"title":"Note","content":"about notes"}`, http.StatusCreated, LangEnglish},
+		{"explicit", `{"title":"Note","content":"x","language":"simple"}`, http.StatusCreated, LangSimple},
+		{"unsupported", `{"title":"Note","content":"x","language":"klingon"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotLang = ""
+			req := httptest.NewRequest(http.MethodPost, "/notes/", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			require.Equal(t, tt.wantCode, rr.Code)
+			require.Equal(t, tt.wantLang, gotLang)
+		})
+	}
+}
+
+func TestHandlers_List_Language(t *testing.T) {
+	var got ListParams
+	h := NewHandlers(stubStore{
+		listFn: func(_ context.Context, p ListParams) ([]Note, error) {
+			got = p
+			return []Note{}, nil
+		},
+	}).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/notes?q=заметки", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, LangRussian, got.Language)
+
+	req = httptest.NewRequest(http.MethodGet, "/notes?q=x&lang=klingon", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHandlers_Related_CachedAndInvalidated(t *testing.T) {
+	calls := 0
+	store := stubStore{
+		relatedFn: func(_ context.Context, id int64, limit int) ([]RelatedNote, error) {
+			if id == 404 {
+				return nil, sql.ErrNoRows
+			}
+			calls++
+			require.Equal(t, 3, limit)
+			return []RelatedNote{{ID: 2, Title: "t", Score: 0.5, Scores: RelatedScores{Text: 0.5, Title: 0.5}}}, nil
+		},
+		deleteFn: func(context.Context, int64) error { return nil },
+	}
+	h := NewHandlers(store).Routes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/notes/1/related?limit=3")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "MISS", rr.Header().Get("X-Cache"))
+	var resp struct {
+		Items []RelatedNote `json:"items"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, 0.5, resp.Items[0].Score)
+
+	rr = get("/notes/1/related?limit=3")
+	require.Equal(t, "HIT", rr.Header().Get("X-Cache"))
+	require.Equal(t, 1, calls)
+
+	// a write drops cached results
+	req := httptest.NewRequest(http.MethodDelete, "/notes/5", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	rr = get("/notes/1/related?limit=3")
+	require.Equal(t, "MISS", rr.Header().Get("X-Cache"))
+	require.Equal(t, 2, calls)
+
+	require.Equal(t, http.StatusNotFound, get("/notes/404/related").Code)
+}
+
+func TestHandlers_Related_LimitClamped(t *testing.T) {
+	var limits []int
+	h := NewHandlers(stubStore{
+		relatedFn: func(_ context.Context, _ int64, limit int) ([]RelatedNote, error) {
+			limits = append(limits, limit)
+			return []RelatedNote{}, nil
+		},
+	}).Routes()
+
+	for _, q := range []string{"limit=51", "limit=52", "limit=0", "limit=-1", "limit=x", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/notes/1/related?"+q, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+	// Out-of-range limits are the default one, served from one cache entry.
+	require.Equal(t, []int{10}, limits)
+}
+
+func TestHandlers_Health(t *testing.T) {
+	var unhealthy error
+	h := NewHandlers(stubStore{}).WithHealthCheck(func(context.Context) error { return unhealthy }).Routes()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	unhealthy = errors.New("shutting down")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func TestHandlers_MemoryStore(t *testing.T) {
+	h := NewHandlers(NewMemoryStore()).Routes()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/notes/", `{"title":"Release plan","content":"Ship the search feature"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created Note
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	require.Equal(t, LangEnglish, created.Language)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/notes/", `{"title":"Groceries","content":"milk"}`).Code)
+
+	rr = do(http.MethodGet, "/notes/?q=features", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page struct {
+		Items []Note `json:"items"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	require.Len(t, page.Items, 1)
+	require.Equal(t, created.ID, page.Items[0].ID)
+	require.Equal(t, "Ship the search <b>feature</b>", page.Items[0].Highlight.Content)
+
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, fmt.Sprintf("/notes/%d", created.ID), "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, fmt.Sprintf("/notes/%d", created.ID), "").Code)
+}
@@ -0,0 +1,126 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// QueryEvent describes a finished repository statement.
+type QueryEvent struct {
+	// Name identifies the statement, e.g. "notes.get".
+	Name string
+	SQL  string
+	Args []any
+	// Rows is the number of rows returned or affected.
+	Rows     int64
+	Duration time.Duration
+	Err      error
+}
+
+// QueryHook observes repository statements (tracing, logging, statistics).
+// BeforeQuery may return a derived context, e.g. one carrying a span;
+// AfterQuery receives that context.
+type QueryHook interface {
+	BeforeQuery(ctx context.Context, name string) context.Context
+	AfterQuery(ctx context.Context, ev QueryEvent)
+}
+
+// AddHook registers a hook called around every statement. Hooks must be added
+// before the repository is used.
+func (r *Repository) AddHook(h QueryHook) {
+	r.hooks = append(r.hooks, h)
+}
+
+// queryHooks are the hooks of a repository.
+type queryHooks []QueryHook
+
+// rowIterator is implemented by *sql.Rows and pgx.Rows, so the scan
+// functions serve both Repository and PgxRepository.
+type rowIterator interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// queryer is implemented by *sql.DB, *sql.Tx and stmtQueryer.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// stmtQueryer runs a prepared statement; the query text is ignored.
+type stmtQueryer struct{ *sql.Stmt }
+
+func (s stmtQueryer) QueryContext(ctx context.Context, _ string, args ...any) (*sql.Rows, error) {
+	return s.Stmt.QueryContext(ctx, args...)
+}
+
+func (s stmtQueryer) QueryRowContext(ctx context.Context, _ string, args ...any) *sql.Row {
+	return s.Stmt.QueryRowContext(ctx, args...)
+}
+
+func (s stmtQueryer) ExecContext(ctx context.Context, _ string, args ...any) (sql.Result, error) {
+	return s.Stmt.ExecContext(ctx, args...)
+}
+
+// start notifies hooks that a statement begins and returns a function that
+// reports its outcome.
+func (hs queryHooks) start(ctx context.Context, name, query string, args []any) (context.Context, func(rows int64, err error)) {
+	if len(hs) == 0 {
+		return ctx, func(int64, error) {}
+	}
+	begin := time.Now()
+	for _, h := range hs {
+		ctx = h.BeforeQuery(ctx, name)
+	}
+	return ctx, func(rows int64, err error) {
+		ev := QueryEvent{Name: name, SQL: query, Args: args, Rows: rows, Duration: time.Since(begin), Err: err}
+		for i := len(hs) - 1; i >= 0; i-- {
+			hs[i].AfterQuery(ctx, ev)
+		}
+	}
+}
+
+// queryAll runs a statement and scans all rows with scan.
+func queryAll[T any](ctx context.Context, r *Repository, q queryer, name, query string, scan func(rowIterator) ([]T, error), args ...any) ([]T, error) {
+	ctx, done := r.hooks.start(ctx, name, query, args)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		done(0, err)
+		return nil, err
+	}
+	defer rows.Close()
+	out, err := scan(rows)
+	done(int64(len(out)), err)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// queryRow runs a statement returning a single row into dest.
+func (r *Repository) queryRow(ctx context.Context, q queryer, name, query string, dest []any, args ...any) error {
+	ctx, done := r.hooks.start(ctx, name, query, args)
+	err := q.QueryRowContext(ctx, query, args...).Scan(dest...)
+	var rows int64
+	if err == nil {
+		rows = 1
+	}
+	done(rows, err)
+	return err
+}
+
+// exec runs a statement and returns the number of affected rows.
+func (r *Repository) exec(ctx context.Context, q queryer, name, query string, args ...any) (int64, error) {
+	ctx, done := r.hooks.start(ctx, name, query, args)
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		done(0, err)
+		return 0, err
+	}
+	a, _ := res.RowsAffected()
+	done(a, nil)
+	return a, nil
+}
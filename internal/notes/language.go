@@ -0,0 +1,41 @@
+package notes
+
+import "unicode"
+
+// Text search configurations a note can be indexed with.
+const (
+	LangSimple  = "simple"
+	LangEnglish = "english"
+	LangRussian = "russian"
+)
+
+// SupportedLanguage reports whether lang is a known text search configuration.
+func SupportedLanguage(lang string) bool {
+	switch lang {
+	case LangSimple, LangEnglish, LangRussian:
+		return true
+	}
+	return false
+}
+
+// DetectLanguage picks a text search configuration by counting Cyrillic and
+// Latin letters. Text without letters of either script uses 'simple'.
+func DetectLanguage(text string) string {
+	var cyr, lat int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyr++
+		case unicode.Is(unicode.Latin, r):
+			lat++
+		}
+	}
+	switch {
+	case cyr == 0 && lat == 0:
+		return LangSimple
+	case cyr >= lat:
+		return LangRussian
+	default:
+		return LangEnglish
+	}
+}
@@ -0,0 +1,27 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", LangSimple},
+		{"12345 !?", LangSimple},
+		{"Hello world", LangEnglish},
+		{"Привет, мир", LangRussian},
+		{"Заметка about Go", LangRussian},
+		{"Notes about Go и всё", LangEnglish},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			require.Equal(t, tt.want, DetectLanguage(tt.in))
+		})
+	}
+}
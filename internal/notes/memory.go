@@ -0,0 +1,301 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/notes-api-pz14/internal/textsearch"
+)
+
+// AuditEntry is a row of notes_audit.
+type AuditEntry struct {
+	ID        int64
+	NoteID    int64
+	Action    string
+	CreatedAt time.Time
+}
+
+// MemoryStore is a Store kept in memory, for tests and demos without
+// Postgres. Ordering, keyset pagination and errors match Repository; search,
+// fuzzy matching and related notes approximate Postgres text search and
+// pg_trgm (see package textsearch). It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	notes   map[int64]Note
+	audit   []AuditEntry
+	nextID  int64
+	auditID int64
+	last    time.Time
+
+	now func() time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{notes: make(map[int64]Note), now: time.Now}
+}
+
+// Audit returns the audit log, oldest first. Like notes_audit, entries of
+// deleted notes are removed with them.
+func (m *MemoryStore) Audit() []AuditEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]AuditEntry(nil), m.audit...)
+}
+
+// timestamp returns now() with Postgres precision, later than any previous
+// one, so created_at order follows insertion like it does in practice.
+func (m *MemoryStore) timestamp() time.Time {
+	t := m.now().UTC().Truncate(time.Microsecond)
+	if !t.After(m.last) {
+		t = m.last.Add(time.Microsecond)
+	}
+	m.last = t
+	return t
+}
+
+func (m *MemoryStore) Create(_ context.Context, title, content, language string) (Note, error) {
+	if !SupportedLanguage(language) {
+		return Note{}, fmt.Errorf("notes: unsupported language %q", language)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	n := Note{ID: m.nextID, Title: title, Content: content, Language: language, CreatedAt: m.timestamp()}
+	m.notes[n.ID] = n
+	m.auditID++
+	m.audit = append(m.audit, AuditEntry{ID: m.auditID, NoteID: n.ID, Action: "create", CreatedAt: n.CreatedAt})
+	return n, nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, id int64) (Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.notes[id]
+	if !ok {
+		return Note{}, sql.ErrNoRows
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, id int64, title, content, language string) (Note, error) {
+	if !SupportedLanguage(language) {
+		return Note{}, fmt.Errorf("notes: unsupported language %q", language)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.notes[id]
+	if !ok {
+		return Note{}, sql.ErrNoRows
+	}
+	n.Title, n.Content, n.Language = title, content, language
+	m.notes[id] = n
+	return n, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.notes[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.notes, id)
+	kept := m.audit[:0]
+	for _, e := range m.audit {
+		if e.NoteID != id {
+			kept = append(kept, e)
+		}
+	}
+	m.audit = kept
+	return nil
+}
+
+func (m *MemoryStore) List(_ context.Context, p ListParams) ([]Note, error) {
+	if p.Limit <= 0 || p.Limit > 200 {
+		p.Limit = 20
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if p.Query != "" && p.Fuzzy {
+		return m.fuzzy(p.Query, p.Limit), nil
+	}
+
+	var match func(Note) bool
+	var terms []string
+	if p.Query != "" {
+		if !SupportedLanguage(p.Language) {
+			p.Language = DetectLanguage(p.Query)
+		}
+		terms = textsearch.Lexemes(p.Language, p.Query)
+		// plainto_tsquery of only stop words matches nothing.
+		if len(terms) == 0 {
+			return []Note{}, nil
+		}
+		match = func(n Note) bool {
+			if n.Language != p.Language {
+				return false
+			}
+			have := map[string]bool{}
+			for _, l := range textsearch.Lexemes(n.Language, n.Title+" "+n.Content) {
+				have[l] = true
+			}
+			for _, t := range terms {
+				if !have[t] {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	out := make([]Note, 0, p.Limit)
+	for _, n := range m.sorted() {
+		if p.CursorCreatedAt != nil && p.CursorID != nil && !before(n, *p.CursorCreatedAt, *p.CursorID) {
+			continue
+		}
+		if match != nil && !match(n) {
+			continue
+		}
+		if p.Query != "" {
+			n.Highlight = SearchHighlight(n, terms, p.Highlight)
+			n.Content = ""
+		}
+		out = append(out, n)
+		if len(out) == p.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// fuzzy mirrors qNoteFuzzy: $1 <% title, by word similarity.
+func (m *MemoryStore) fuzzy(q string, limit int) []Note {
+	var out []Note
+	for _, n := range m.sorted() {
+		if s := textsearch.WordSimilarity(q, n.Title); s >= textsearch.WordSimilarityThreshold {
+			n.Score = s
+			out = append(out, n)
+		}
+	}
+	// sorted() already orders ties by created_at, id.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out[:min(limit, len(out))]
+}
+
+func (m *MemoryStore) Suggest(_ context.Context, prefix string, limit int) ([]Suggestion, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	prefix = strings.ToLower(prefix)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type scored struct {
+		s    Suggestion
+		dist float64
+	}
+	var found []scored
+	for _, n := range m.sorted() {
+		lower := strings.ToLower(n.Title)
+		if strings.HasPrefix(lower, prefix) {
+			found = append(found, scored{Suggestion{ID: n.ID, Title: n.Title}, 1 - textsearch.Similarity(lower, prefix)})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].dist < found[j].dist })
+
+	out := make([]Suggestion, 0, min(limit, len(found)))
+	for _, f := range found[:min(limit, len(found))] {
+		out = append(out, f.s)
+	}
+	return out, nil
+}
+
+// Related mirrors qNoteRelated; the text signal is textsearch.Rank.
+func (m *MemoryStore) Related(_ context.Context, id int64, limit int) ([]RelatedNote, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	src, ok := m.notes[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	query := map[string]bool{}
+	for _, l := range textsearch.Lexemes(src.Language, src.Title+" "+src.Content) {
+		query[l] = true
+	}
+
+	out := make([]RelatedNote, 0, limit)
+	for _, n := range m.sorted() {
+		if n.ID == id {
+			continue
+		}
+		scores := RelatedScores{
+			Text:  textsearch.Rank(textsearch.Lexemes(n.Language, n.Title), textsearch.Lexemes(n.Language, n.Title+" "+n.Content), query),
+			Title: textsearch.Similarity(n.Title, src.Title),
+			Tags:  TagSimilarity(n.Content, src.Content),
+			Links: LinkSimilarity(n.ID, n.Content, src.ID, src.Content),
+		}
+		if scores.Text == 0 && scores.Title < textsearch.SimilarityThreshold && scores.Tags == 0 && scores.Links == 0 {
+			continue
+		}
+		r := RelatedNote{ID: n.ID, Title: n.Title, Language: n.Language, CreatedAt: n.CreatedAt, Scores: scores}
+		r.Score = scores.Score()
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out[:min(limit, len(out))], nil
+}
+
+func (m *MemoryStore) BatchGet(_ context.Context, ids []int64) ([]Note, error) {
+	if len(ids) == 0 {
+		return []Note{}, nil
+	}
+	want := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]Note, 0, len(want))
+	for _, n := range m.sorted() {
+		if want[n.ID] {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// sorted returns all notes by created_at DESC, id DESC (idx_notes_created_id).
+// Callers hold the lock.
+func (m *MemoryStore) sorted() []Note {
+	out := make([]Note, 0, len(m.notes))
+	for _, n := range m.notes {
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool { return before(out[j], out[i].CreatedAt, out[i].ID) })
+	return out
+}
+
+// before reports whether n sorts after the keyset cursor (at, id), i.e.
+// (n.created_at, n.id) < (at, id).
+func before(n Note, at time.Time, id int64) bool {
+	if !n.CreatedAt.Equal(at) {
+		return n.CreatedAt.Before(at)
+	}
+	return n.ID < id
+}
@@ -0,0 +1,41 @@
+package notes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/notes/storetest"
+)
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(*testing.T) notes.Store { return notes.NewMemoryStore() })
+}
+
+func TestMemoryStore_Audit(t *testing.T) {
+	ctx := context.Background()
+	s := notes.NewMemoryStore()
+	a, err := s.Create(ctx, "a", "a", notes.LangSimple)
+	require.NoError(t, err)
+	b, err := s.Create(ctx, "b", "b", notes.LangSimple)
+	require.NoError(t, err)
+	_, err = s.Update(ctx, a.ID, "a2", "a2", notes.LangSimple)
+	require.NoError(t, err)
+
+	audit := s.Audit()
+	require.Len(t, audit, 2)
+	require.Equal(t, []int64{a.ID, b.ID}, []int64{audit[0].NoteID, audit[1].NoteID})
+	require.Equal(t, "create", audit[0].Action)
+	require.True(t, audit[0].CreatedAt.Equal(a.CreatedAt))
+
+	// Like notes_audit rows, entries go away with their note.
+	require.NoError(t, s.Delete(ctx, a.ID))
+	audit = s.Audit()
+	require.Len(t, audit, 1)
+	require.Equal(t, b.ID, audit[0].NoteID)
+
+	_, err = s.Create(ctx, "bad", "bad", "klingon")
+	require.Error(t, err)
+}
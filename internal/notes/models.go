@@ -5,18 +5,34 @@ import "time"
 type Note struct {
 	ID        int64     `json:"id"`
 	Title     string    `json:"title"`
-	Content   string    `json:"content"`
+	Content   string    `json:"content,omitempty"`
+	Language  string    `json:"language,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// Score is the relevance of a ranked search result.
+	Score float64 `json:"score,omitempty"`
+
+	// Highlight is filled for search results instead of the full content.
+	Highlight *Highlight `json:"highlight,omitempty"`
+}
+
+// Suggestion is a title autocomplete entry.
+type Suggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
 }
 
+// Language is optional in requests: when empty it is detected from the text.
 type CreateNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Language string `json:"language,omitempty"`
 }
 
 type UpdateNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Language string `json:"language,omitempty"`
 }
 
 type BatchRequest struct {
@@ -0,0 +1,171 @@
+package notes
+
+// SQL of every repository statement. Statements are reported to query hooks
+// as "<table>.<operation>", e.g. "notes.get" for qNoteGet.
+const (
+	qNoteGet = `
+		SELECT id, title, content, language, created_at
+		FROM notes
+		WHERE id = $1`
+
+	qNoteUpdate = `
+		UPDATE notes
+		SET title = $1, content = $2, language = $3
+		WHERE id = $4
+		RETURNING id, title, content, language, created_at`
+
+	qNoteDelete = `DELETE FROM notes WHERE id = $1`
+
+	qNoteInsert = `
+		INSERT INTO notes (title, content, language) VALUES ($1, $2, $3)
+		RETURNING id, title, content, language, created_at`
+
+	qAuditInsert = `INSERT INTO notes_audit (note_id, action) VALUES ($1, $2)`
+
+	// Audit row of the note inserted just before on the same connection
+	// (the PgxRepository.Create batch).
+	qAuditInsertLast = `
+		INSERT INTO notes_audit (note_id, action)
+		VALUES (currval(pg_get_serial_sequence('notes', 'id')), $1)`
+
+	// Fuzzy search (GIN trigram on title), ranked by word similarity.
+	qNoteFuzzy = `
+		SELECT id, title, content, language, created_at, word_similarity($1, title) AS score
+		FROM notes
+		WHERE $1 <% title
+		ORDER BY score DESC, created_at DESC, id DESC
+		LIMIT $2`
+
+	// Search (GIN on search_vector) with the query language config, optionally
+	// continued from a keyset cursor; content is replaced by ts_headline snippets
+	// of the HTML-escaped text.
+	qNoteSearch = `
+		SELECT id, title, language, created_at,
+		       ts_headline($5::regconfig, ` + htmlTitle + `, q, $3),
+		       ts_headline($5::regconfig, ` + htmlContent + `, q, $4)
+		FROM notes, plainto_tsquery($5::regconfig, $1) AS q
+		WHERE language = $5 AND search_vector @@ q
+		  AND ($6::timestamptz IS NULL OR (created_at, id) < ($6, $7))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	// Keyset pagination (idx_notes_created_id).
+	qNoteListAfter = `
+		SELECT id, title, content, language, created_at
+		FROM notes
+		WHERE (created_at, id) < ($1, $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+
+	qNoteListFirst = `
+		SELECT id, title, content, language, created_at
+		FROM notes
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1`
+
+	// Title autocomplete (GiST trigram on lower(title)), closest matches first.
+	qNoteSuggest = `
+		SELECT id, title
+		FROM notes
+		WHERE lower(title) LIKE $1 || '%'
+		ORDER BY lower(title) <-> $2
+		LIMIT $3`
+
+	// Related notes: lexemes of the source search_vector are OR-ed into a
+	// tsquery (GIN on search_vector), titles are compared with trigrams, and
+	// notes sharing a hashtag or URL or referencing each other are found
+	// through the GIN indexes on notes_tags, notes_links and notes_refs.
+	qNoteRelated = `
+		WITH src AS (
+			SELECT id, title,
+			       NULLIF(array_to_string(ARRAY(
+			         SELECT quote_literal(l) FROM unnest(tsvector_to_array(search_vector)) AS l
+			       ), ' | '), '')::tsquery AS q,
+			       notes_tags(content) AS tags,
+			       notes_links(content) AS links,
+			       notes_refs(content) AS refs
+			FROM notes
+			WHERE id = $1
+		), scored AS (
+			SELECT n.id, n.title, n.language, n.created_at,
+			       coalesce(ts_rank(n.search_vector, src.q, 32), 0) AS text_score,
+			       similarity(n.title, src.title) AS title_score,
+			       notes_jaccard(notes_tags(n.content), src.tags) AS tag_score,
+			       CASE WHEN n.id = ANY(src.refs) OR notes_refs(n.content) @> ARRAY[src.id] THEN 1
+			            ELSE notes_jaccard(notes_links(n.content), src.links) END AS link_score
+			FROM notes n, src
+			WHERE n.id <> src.id
+			  AND (n.search_vector @@ src.q OR n.title % src.title
+			       OR notes_tags(n.content) && src.tags
+			       OR notes_links(n.content) && src.links
+			       OR notes_refs(n.content) @> ARRAY[src.id]
+			       OR n.id = ANY(src.refs))
+		)
+		SELECT id, title, language, created_at, text_score, title_score, tag_score, link_score,
+		       $3 * text_score + $4 * title_score + $5 * tag_score + $6 * link_score AS score
+		FROM scored
+		ORDER BY score DESC, id DESC
+		LIMIT $2`
+
+	qNoteExists = `SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1)`
+
+	// BatchGet: один запрос вместо N запросов (ANY($1)).
+	qNoteBatchGet = `
+		SELECT id, title, content, language, created_at
+		FROM notes
+		WHERE id = ANY($1)
+		ORDER BY created_at DESC, id DESC`
+
+	qSavedSearchInsert = `
+		INSERT INTO saved_searches (name, query, language, notify, webhook_url, fuzzy)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING ` + savedSearchColumns
+
+	qSavedSearchGet = `SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE id = $1`
+
+	qSavedSearchList = `SELECT ` + savedSearchColumns + ` FROM saved_searches ORDER BY id`
+
+	qSavedSearchListNotified = `SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE notify ORDER BY id`
+
+	qSavedSearchDelete = `DELETE FROM saved_searches WHERE id = $1`
+
+	qSavedSearchInbox = `
+		SELECT m.id, m.saved_search_id, m.note_id, n.title, m.matched_at, m.read_at
+		FROM saved_search_matches m
+		JOIN notes n ON n.id = m.note_id
+		WHERE m.saved_search_id = $1
+		  AND ($2 <= 0 OR m.id < $2)
+		  AND (NOT $4 OR m.read_at IS NULL)
+		ORDER BY m.id DESC
+		LIMIT $3`
+
+	qSavedSearchMarkRead = `
+		UPDATE saved_search_matches SET read_at = now()
+		WHERE saved_search_id = $1 AND read_at IS NULL`
+
+	// New matches of a saved search (idx_notes_updated_id), by full-text search
+	// in the saved language or, for fuzzy searches, by title word similarity.
+	qSavedSearchCollect = `
+		WITH m AS (
+			INSERT INTO saved_search_matches (saved_search_id, note_id)
+			SELECT $1, n.id
+			FROM notes n, plainto_tsquery($2::regconfig, $3) AS q
+			WHERE n.updated_at > $4 AND n.updated_at <= $5
+			  AND CASE WHEN $6 THEN $3 <% n.title
+			           ELSE n.language = $2 AND n.search_vector @@ q END
+			ON CONFLICT (saved_search_id, note_id) DO NOTHING
+			RETURNING id, saved_search_id, note_id, matched_at, read_at
+		)
+		SELECT m.id, m.saved_search_id, m.note_id, n.title, m.matched_at, m.read_at
+		FROM m JOIN notes n ON n.id = m.note_id
+		ORDER BY m.id`
+
+	qSavedSearchCheckpoint = `UPDATE saved_searches SET checked_at = $2 WHERE id = $1`
+
+	savedSearchColumns = `id, name, query, language, fuzzy, notify, coalesce(webhook_url, ''), checked_at, created_at`
+
+	// Title and content escaped like html.EscapeString, so highlights carry
+	// no markup but that of the highlight options.
+	htmlTitle   = `replace(replace(replace(replace(replace(title, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+	htmlContent = `replace(replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+)
@@ -0,0 +1,189 @@
+package notes
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RelatedNote is a note similar to another one, with the signals that ranked it:
+// overlap of tsvector lexemes (Text), trigram similarity of titles (Title),
+// shared #hashtags (Tags) and links (Links, see LinkSimilarity).
+type RelatedNote struct {
+	ID        int64         `json:"id"`
+	Title     string        `json:"title"`
+	Language  string        `json:"language,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Score     float64       `json:"score"`
+	Scores    RelatedScores `json:"scores"`
+}
+
+type RelatedScores struct {
+	Text  float64 `json:"text"`
+	Title float64 `json:"title"`
+	Tags  float64 `json:"tags"`
+	Links float64 `json:"links"`
+}
+
+// Weights of the similarity signals in RelatedNote.Score.
+const (
+	RelatedTextWeight  = 0.4
+	RelatedTitleWeight = 0.2
+	RelatedTagWeight   = 0.2
+	RelatedLinkWeight  = 0.2
+)
+
+// Score is the weighted sum of the signals.
+func (s RelatedScores) Score() float64 {
+	return RelatedTextWeight*s.Text + RelatedTitleWeight*s.Title +
+		RelatedTagWeight*s.Tags + RelatedLinkWeight*s.Links
+}
+
+// Tags, links and note references are found in the content with these
+// patterns; migrations/012_notes_references_charset.sql mirrors them in
+// Postgres (notes_tags, notes_links, notes_refs). The character sets are
+// spelled out, not \p{L} or \s, because Postgres classes depend on the
+// database locale: tags are Latin or Cyrillic letters, digits and '_'. A note
+// reference is a /notes/{id} path, bare or inside a URL.
+var (
+	tagPattern  = regexp.MustCompile(`(?:^|[ \t\n\f\r])#([0-9A-Za-zА-Яа-яЁё_]+)`)
+	linkPattern = regexp.MustCompile(`https?://[^ \t\n\f\r<>"']*[^ \t\n\f\r<>"'.,;:!?)]`)
+	refPattern  = regexp.MustCompile(`/notes/([1-9][0-9]{0,17})`)
+)
+
+// Tags returns the distinct #hashtags of content, lowercased, without '#'.
+func Tags(content string) []string {
+	var out []string
+	for _, m := range tagPattern.FindAllStringSubmatch(content, -1) {
+		out = append(out, strings.ToLower(m[1]))
+	}
+	return distinct(out)
+}
+
+// Links returns the distinct http(s) URLs of content.
+func Links(content string) []string {
+	return distinct(linkPattern.FindAllString(content, -1))
+}
+
+// References returns the distinct IDs of the notes content links to.
+func References(content string) []int64 {
+	var out []int64
+	for _, m := range refPattern.FindAllStringSubmatch(content, -1) {
+		id, _ := strconv.ParseInt(m[1], 10, 64) // at most 18 digits
+		out = append(out, id)
+	}
+	return distinct(out)
+}
+
+// TagSimilarity is the Jaccard index of the hashtags of two contents.
+func TagSimilarity(a, b string) float64 {
+	return jaccard(Tags(a), Tags(b))
+}
+
+// LinkSimilarity is 1 if either note references the other, otherwise the
+// Jaccard index of their URLs.
+func LinkSimilarity(aID int64, a string, bID int64, b string) float64 {
+	if slices.Contains(References(a), bID) || slices.Contains(References(b), aID) {
+		return 1
+	}
+	return jaccard(Links(a), Links(b))
+}
+
+func distinct[T cmp.Ordered](s []T) []T {
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+// jaccard is |a ∩ b| / |a ∪ b| of two sorted sets; 0 if both are empty.
+func jaccard[T cmp.Ordered](a, b []T) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, v := range a {
+		if _, ok := slices.BinarySearch(b, v); ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+type relatedKey struct {
+	id    int64
+	limit int
+}
+
+type relatedEntry struct {
+	items   []RelatedNote
+	expires time.Time
+}
+
+// relatedCache keeps related-notes results for a short time. Any mutation
+// may change similarity between notes, so writes drop the whole cache.
+type relatedCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[relatedKey]relatedEntry
+	now     func() time.Time
+}
+
+func newRelatedCache(ttl time.Duration, size int) *relatedCache {
+	return &relatedCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[relatedKey]relatedEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *relatedCache) get(k relatedKey) ([]RelatedNote, bool) {
+	if c == nil || c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(e.expires) {
+		delete(c.entries, k)
+		return nil, false
+	}
+	return e.items, true
+}
+
+func (c *relatedCache) put(k relatedKey, items []RelatedNote) {
+	if c == nil || c.ttl <= 0 || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= c.size {
+		// Drop expired entries first; if still full, drop everything.
+		for key, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= c.size {
+			clear(c.entries)
+		}
+	}
+	c.entries[k] = relatedEntry{items: items, expires: now.Add(c.ttl)}
+}
+
+func (c *relatedCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
+}
@@ -0,0 +1,24 @@
+package notes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferences(t *testing.T) {
+	content := "#Go notes on #go, #базы_данных and #Базы_Данных, not issue#12.\n" +
+		"See https://example.com/a?b=1, (https://example.com/notes/7) and /notes/42.\n" +
+		"/notes/0 /notes/12345678901234567890"
+
+	require.Equal(t, []string{"go", "базы_данных"}, Tags(content))
+	require.Equal(t, []string{"https://example.com/a?b=1", "https://example.com/notes/7"}, Links(content))
+	require.Equal(t, []int64{7, 42, 123456789012345678}, References(content))
+
+	require.InDelta(t, 2.0/3, TagSimilarity("#a #b", "#B #c #a"), 1e-9)
+	require.InDelta(t, 1, TagSimilarity("#ЁЖИК", "#ёжик"), 1e-9)
+	require.Zero(t, TagSimilarity("#a", "no tags"))
+	require.Equal(t, 1.0, LinkSimilarity(1, "see /notes/2", 2, ""))
+	require.Equal(t, 1.0, LinkSimilarity(1, "", 2, "see /notes/1"))
+	require.InDelta(t, 0.5, LinkSimilarity(1, "http://x.io/a http://x.io/b", 2, "http://x.io/b."), 1e-9)
+}
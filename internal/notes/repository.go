@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -13,29 +14,35 @@ type Repository struct {
 	stmtGet    *sql.Stmt
 	stmtUpdate *sql.Stmt
 	stmtDelete *sql.Stmt
+
+	hooks queryHooks
+
+	replicas Replicas
+	retry    RetryPolicy
+}
+
+// Replicas routes reads that tolerate replication lag (Get, List, BatchGet)
+// away from the primary; db.Cluster implements it.
+type Replicas interface {
+	// Reader returns the database to read from; replica is false for the
+	// primary.
+	Reader(ctx context.Context) (db *sql.DB, replica bool)
+	// Failed reports a failed read on a replica returned by Reader.
+	Failed(db *sql.DB, err error)
 }
 
 func NewRepository(ctx context.Context, db *sql.DB) (*Repository, error) {
-	get, err := db.PrepareContext(ctx, `
-		SELECT id, title, content, created_at
-		FROM notes
-		WHERE id = $1
-	`)
+	get, err := db.PrepareContext(ctx, qNoteGet)
 	if err != nil {
 		return nil, err
 	}
 
-	upd, err := db.PrepareContext(ctx, `
-		UPDATE notes
-		SET title = $1, content = $2
-		WHERE id = $3
-		RETURNING id, title, content, created_at
-	`)
+	upd, err := db.PrepareContext(ctx, qNoteUpdate)
 	if err != nil {
 		return nil, err
 	}
 
-	del, err := db.PrepareContext(ctx, `DELETE FROM notes WHERE id = $1`)
+	del, err := db.PrepareContext(ctx, qNoteDelete)
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +55,36 @@ func NewRepository(ctx context.Context, db *sql.DB) (*Repository, error) {
 	}, nil
 }
 
+// WithReplicas sends Get, List and BatchGet to replicas. The other methods,
+// and reads of a failing replica, use the primary.
+func (r *Repository) WithReplicas(rep Replicas) *Repository {
+	r.replicas = rep
+	return r
+}
+
+// WithRetry retries calls that fail for a transient reason (see Transient):
+// reads, Update, and the transactions of Create and CollectMatches as a
+// whole. Delete is retried only when the failed attempt had no effect.
+func (r *Repository) WithRetry(p RetryPolicy) *Repository {
+	r.retry = p
+	return r
+}
+
+// read runs fn on a replica when one is available and on primary otherwise,
+// or when the replica fails. Missing rows and cancellation are not failures.
+func (r *Repository) read(ctx context.Context, primary queryer, fn func(q queryer) error) error {
+	if r.replicas != nil {
+		if db, ok := r.replicas.Reader(ctx); ok {
+			err := fn(db)
+			if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+				return err
+			}
+			r.replicas.Failed(db, err)
+		}
+	}
+	return fn(primary)
+}
+
 func (r *Repository) Close() error {
 	for _, s := range []*sql.Stmt{r.stmtGet, r.stmtUpdate, r.stmtDelete} {
 		if s != nil {
@@ -57,58 +94,82 @@ func (r *Repository) Close() error {
 	return nil
 }
 
-// Create uses explicit transaction: INSERT notes + INSERT audit.
-func (r *Repository) Create(ctx context.Context, title, content string) (Note, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
-	if err != nil {
-		return Note{}, err
-	}
-	defer tx.Rollback()
+// inTx runs fn in a read committed transaction. A transient failure before
+// COMMIT rolls everything back, so the retry policy reruns the transaction as
+// a whole.
+func (r *Repository) inTx(ctx context.Context, name string, fn func(tx *sql.Tx) error) error {
+	return r.retry.do(ctx, name, true, func() error {
+		tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
 
-	var n Note
-	err = tx.QueryRowContext(ctx, `
-		INSERT INTO notes (title, content) VALUES ($1, $2)
-		RETURNING id, title, content, created_at
-	`, title, content).Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt)
-	if err != nil {
-		return Note{}, err
-	}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return commitError{err}
+		}
+		return nil
+	})
+}
 
-	_, err = tx.ExecContext(ctx, `INSERT INTO notes_audit (note_id, action) VALUES ($1, $2)`, n.ID, "create")
+// Create uses explicit transaction: INSERT notes + INSERT audit.
+func (r *Repository) Create(ctx context.Context, title, content, language string) (Note, error) {
+	var n Note
+	err := r.inTx(ctx, "notes.create", func(tx *sql.Tx) error {
+		err := r.queryRow(ctx, tx, "notes.insert", qNoteInsert,
+			[]any{&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt}, title, content, language)
+		if err != nil {
+			return err
+		}
+		_, err = r.exec(ctx, tx, "notes_audit.insert", qAuditInsert, n.ID, "create")
+		return err
+	})
 	if err != nil {
 		return Note{}, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		return Note{}, err
-	}
 	return n, nil
 }
 
 func (r *Repository) Get(ctx context.Context, id int64) (Note, error) {
 	var n Note
-	err := r.stmtGet.QueryRowContext(ctx, id).Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt)
+	err := r.retry.do(ctx, "notes.get", true, func() error {
+		return r.read(ctx, stmtQueryer{r.stmtGet}, func(q queryer) error {
+			return r.queryRow(ctx, q, "notes.get", qNoteGet,
+				[]any{&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt}, id)
+		})
+	})
 	if errors.Is(err, sql.ErrNoRows) {
 		return Note{}, sql.ErrNoRows
 	}
 	return n, err
 }
 
-func (r *Repository) Update(ctx context.Context, id int64, title, content string) (Note, error) {
+func (r *Repository) Update(ctx context.Context, id int64, title, content, language string) (Note, error) {
 	var n Note
-	err := r.stmtUpdate.QueryRowContext(ctx, title, content, id).Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt)
+	err := r.retry.do(ctx, "notes.update", true, func() error {
+		return r.queryRow(ctx, stmtQueryer{r.stmtUpdate}, "notes.update", qNoteUpdate,
+			[]any{&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt}, title, content, language, id)
+	})
 	if errors.Is(err, sql.ErrNoRows) {
 		return Note{}, sql.ErrNoRows
 	}
 	return n, err
 }
 
+// Delete is retried only when the failed attempt had no effect: a retry of a
+// delete that went through would report a missing note.
 func (r *Repository) Delete(ctx context.Context, id int64) error {
-	res, err := r.stmtDelete.ExecContext(ctx, id)
+	var a int64
+	err := r.retry.do(ctx, "notes.delete", false, func() (err error) {
+		a, err = r.exec(ctx, stmtQueryer{r.stmtDelete}, "notes.delete", qNoteDelete, id)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	a, _ := res.RowsAffected()
 	if a == 0 {
 		return sql.ErrNoRows
 	}
@@ -120,56 +181,105 @@ type ListParams struct {
 	CursorCreatedAt *time.Time
 	CursorID        *int64
 	Query           string
+	// Language is the text search configuration the query is parsed with;
+	// only notes stored in the same language match, as their search_vector
+	// was built with it.
+	Language string
+	// Fuzzy switches search to trigram word similarity (typo-tolerant). The
+	// results are the Limit best matches; the cursor is ignored.
+	Fuzzy bool
+
+	// Highlight configures snippets returned for search queries.
+	Highlight HighlightOptions
 }
 
 func (r *Repository) List(ctx context.Context, p ListParams) ([]Note, error) {
+	var out []Note
+	err := r.retry.do(ctx, "notes.list", true, func() error {
+		return r.read(ctx, r.db, func(q queryer) (err error) {
+			out, err = r.list(ctx, q, p)
+			return err
+		})
+	})
+	return out, err
+}
+
+func (r *Repository) list(ctx context.Context, q queryer, p ListParams) ([]Note, error) {
 	if p.Limit <= 0 || p.Limit > 200 {
 		p.Limit = 20
 	}
 
-	// Search (GIN on to_tsvector(title))
+	if p.Query != "" && p.Fuzzy {
+		return queryAll(ctx, r, q, "notes.fuzzy", qNoteFuzzy, scanScoredNotes, p.Query, p.Limit)
+	}
+
 	if p.Query != "" {
-		rows, err := r.db.QueryContext(ctx, `
-			SELECT id, title, content, created_at
-			FROM notes
-			WHERE to_tsvector('simple', title) @@ plainto_tsquery('simple', $1)
-			ORDER BY created_at DESC, id DESC
-			LIMIT $2
-		`, p.Query, p.Limit)
-		if err != nil {
-			return nil, err
+		if !SupportedLanguage(p.Language) {
+			p.Language = DetectLanguage(p.Query)
+		}
+		var cursorAt *time.Time
+		var cursorID *int64
+		if p.CursorCreatedAt != nil && p.CursorID != nil {
+			cursorAt, cursorID = p.CursorCreatedAt, p.CursorID
 		}
-		defer rows.Close()
-		return scanNotes(rows)
+		return queryAll(ctx, r, q, "notes.search", qNoteSearch, scanSearchNotes,
+			p.Query, p.Limit, p.Highlight.titleHeadline(), p.Highlight.headline(), p.Language, cursorAt, cursorID)
 	}
 
 	// Keyset pagination
 	if p.CursorCreatedAt != nil && p.CursorID != nil {
-		rows, err := r.db.QueryContext(ctx, `
-			SELECT id, title, content, created_at
-			FROM notes
-			WHERE (created_at, id) < ($1, $2)
-			ORDER BY created_at DESC, id DESC
-			LIMIT $3
-		`, *p.CursorCreatedAt, *p.CursorID, p.Limit)
-		if err != nil {
-			return nil, err
-		}
-		defer rows.Close()
-		return scanNotes(rows)
+		return queryAll(ctx, r, q, "notes.list_after", qNoteListAfter, scanNotes,
+			*p.CursorCreatedAt, *p.CursorID, p.Limit)
 	}
 
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, title, content, created_at
-		FROM notes
-		ORDER BY created_at DESC, id DESC
-		LIMIT $1
-	`, p.Limit)
+	return queryAll(ctx, r, q, "notes.list_first", qNoteListFirst, scanNotes, p.Limit)
+}
+
+// Suggest returns titles starting with prefix (GiST trigram on lower(title)),
+// closest matches first.
+func (r *Repository) Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	prefix = strings.ToLower(prefix)
+	var out []Suggestion
+	err := r.retry.do(ctx, "notes.suggest", true, func() (err error) {
+		out, err = queryAll(ctx, r, r.db, "notes.suggest", qNoteSuggest, scanSuggestions, escapeLike(prefix), prefix, limit)
+		return err
+	})
+	return out, err
+}
+
+// Related returns notes similar to the note id: lexemes of its search_vector
+// are OR-ed into a tsquery (GIN on search_vector), titles are compared with
+// trigrams (GIN trigram on title), and hashtags, URLs and note references are
+// matched through GIN indexes on notes_tags, notes_links and notes_refs.
+// Returns sql.ErrNoRows if the note is missing.
+func (r *Repository) Related(ctx context.Context, id int64, limit int) ([]RelatedNote, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	var out []RelatedNote
+	err := r.retry.do(ctx, "notes.related", true, func() error {
+		var err error
+		out, err = queryAll(ctx, r, r.db, "notes.related", qNoteRelated, scanRelated,
+			id, limit, RelatedTextWeight, RelatedTitleWeight, RelatedTagWeight, RelatedLinkWeight)
+		if err != nil || len(out) > 0 {
+			return err
+		}
+		var exists bool
+		if err := r.queryRow(ctx, r.db, "notes.exists", qNoteExists, []any{&exists}, id); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	return scanNotes(rows)
+	return out, nil
 }
 
 // BatchGet: один запрос вместо N запросов (ANY($1)).
@@ -177,27 +287,80 @@ func (r *Repository) BatchGet(ctx context.Context, ids []int64) ([]Note, error)
 	if len(ids) == 0 {
 		return []Note{}, nil
 	}
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, title, content, created_at
-		FROM notes
-		WHERE id = ANY($1)
-		ORDER BY created_at DESC, id DESC
-	`, ids)
-	if err != nil {
-		return nil, err
+	var out []Note
+	err := r.retry.do(ctx, "notes.batch_get", true, func() error {
+		return r.read(ctx, r.db, func(q queryer) (err error) {
+			out, err = queryAll(ctx, r, q, "notes.batch_get", qNoteBatchGet, scanNotes, ids)
+			return err
+		})
+	})
+	return out, err
+}
+
+func scanNotes(rows rowIterator) ([]Note, error) {
+	out := make([]Note, 0, 32)
+	for rows.Next() {
+		var n Note
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+func scanScoredNotes(rows rowIterator) ([]Note, error) {
+	out := make([]Note, 0, 32)
+	for rows.Next() {
+		var n Note
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt, &n.Score); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
 	}
-	defer rows.Close()
-	return scanNotes(rows)
+	return out, rows.Err()
 }
 
-func scanNotes(rows *sql.Rows) ([]Note, error) {
+func scanSearchNotes(rows rowIterator) ([]Note, error) {
 	out := make([]Note, 0, 32)
 	for rows.Next() {
 		var n Note
-		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.CreatedAt); err != nil {
+		var hl Highlight
+		if err := rows.Scan(&n.ID, &n.Title, &n.Language, &n.CreatedAt, &hl.Title, &hl.Content); err != nil {
 			return nil, err
 		}
+		n.Highlight = &hl
 		out = append(out, n)
 	}
 	return out, rows.Err()
 }
+
+func scanSuggestions(rows rowIterator) ([]Suggestion, error) {
+	out := make([]Suggestion, 0, 16)
+	for rows.Next() {
+		var s Suggestion
+		if err := rows.Scan(&s.ID, &s.Title); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func scanRelated(rows rowIterator) ([]RelatedNote, error) {
+	out := make([]RelatedNote, 0, 16)
+	for rows.Next() {
+		var n RelatedNote
+		if err := rows.Scan(&n.ID, &n.Title, &n.Language, &n.CreatedAt, &n.Scores.Text, &n.Scores.Title,
+			&n.Scores.Tags, &n.Scores.Links, &n.Score); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
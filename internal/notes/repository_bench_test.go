@@ -0,0 +1,87 @@
+package notes_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// BenchmarkRepository compares Repository (database/sql over pgx) with
+// PgxRepository (native pgxpool) on TEST_DATABASE_URL:
+//
+//	TEST_DATABASE_URL=... go test ./internal/notes -run '^$' -bench Repository -benchmem
+func BenchmarkRepository(b *testing.B) {
+	ctx := context.Background()
+	db, url := testDB(b)
+	truncate(b, db)
+
+	repo, err := notes.NewRepository(ctx, db)
+	require.NoError(b, err)
+	b.Cleanup(func() { _ = repo.Close() })
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(b, err)
+	b.Cleanup(pool.Close)
+
+	// A fixed data set shared by both paths; Create adds to it.
+	var ids []int64
+	for i := 0; i < 1000; i++ {
+		n, err := repo.Create(ctx, fmt.Sprintf("Benchmark note %d", i), "index vacuum statistics", notes.LangEnglish)
+		require.NoError(b, err)
+		ids = append(ids, n.ID)
+	}
+
+	for _, store := range []struct {
+		name string
+		s    notes.Store
+	}{
+		{"sql", repo},
+		{"pgx", notes.NewPgxRepository(pool)},
+	} {
+		s := store.s
+		b.Run(store.name+"/Create", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Create(ctx, "Benchmark create", "content", notes.LangEnglish); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(store.name+"/Get", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.Get(ctx, ids[i%len(ids)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(store.name+"/List", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.List(ctx, notes.ListParams{Limit: 50}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(store.name+"/BatchGet", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.BatchGet(ctx, ids[:100]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(store.name+"/GetParallel", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if _, err := s.Get(ctx, ids[i%len(ids)]); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxRepository is the Repository port on a native pgx pool. It runs the same
+// statements, reported to hooks under the same names, without database/sql:
+// pgx prepares and caches statements per connection, ids are sent as a typed
+// bigint[] and Create sends its two statements as one pgx.Batch. Saved
+// searches stay on Repository.
+type PgxRepository struct {
+	pool  *pgxpool.Pool
+	hooks queryHooks
+	retry RetryPolicy
+}
+
+var _ Store = (*PgxRepository)(nil)
+
+func NewPgxRepository(pool *pgxpool.Pool) *PgxRepository {
+	return &PgxRepository{pool: pool}
+}
+
+// AddHook registers a hook called around every statement. Hooks must be added
+// before the repository is used.
+func (r *PgxRepository) AddHook(h QueryHook) {
+	r.hooks = append(r.hooks, h)
+}
+
+// WithRetry retries calls that fail for a transient reason, like
+// Repository.WithRetry.
+func (r *PgxRepository) WithRetry(p RetryPolicy) *PgxRepository {
+	r.retry = p
+	return r
+}
+
+// Create sends INSERT notes + INSERT audit in one round trip. A batch outside
+// a transaction runs as one implicit transaction: both rows are committed or
+// neither is. The batch commits as it ends, so a connection lost while
+// reading its results is not retried.
+func (r *PgxRepository) Create(ctx context.Context, title, content, language string) (Note, error) {
+	var n Note
+	err := r.retry.do(ctx, "notes.create", false, func() (err error) {
+		n, err = r.create(ctx, title, content, language)
+		return err
+	})
+	return n, err
+}
+
+func (r *PgxRepository) create(ctx context.Context, title, content, language string) (Note, error) {
+	insertArgs := []any{title, content, language}
+	auditArgs := []any{"create"}
+	var b pgx.Batch
+	b.Queue(qNoteInsert, insertArgs...)
+	b.Queue(qAuditInsertLast, auditArgs...)
+
+	_, insertDone := r.hooks.start(ctx, "notes.insert", qNoteInsert, insertArgs)
+	_, auditDone := r.hooks.start(ctx, "notes_audit.insert", qAuditInsertLast, auditArgs)
+	res := r.pool.SendBatch(ctx, &b)
+	defer res.Close()
+
+	var n Note
+	err := res.QueryRow().Scan(&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt)
+	insertDone(rowsOf(err), err)
+	if err != nil {
+		auditDone(0, err)
+		return Note{}, err
+	}
+	tag, err := res.Exec()
+	auditDone(tag.RowsAffected(), err)
+	if err != nil {
+		return Note{}, err
+	}
+	if err := res.Close(); err != nil {
+		return Note{}, err
+	}
+	return n, nil
+}
+
+func (r *PgxRepository) Get(ctx context.Context, id int64) (Note, error) {
+	var n Note
+	err := r.retry.do(ctx, "notes.get", true, func() error {
+		return r.queryRow(ctx, "notes.get", qNoteGet,
+			[]any{&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt}, id)
+	})
+	return n, err
+}
+
+func (r *PgxRepository) Update(ctx context.Context, id int64, title, content, language string) (Note, error) {
+	var n Note
+	err := r.retry.do(ctx, "notes.update", true, func() error {
+		return r.queryRow(ctx, "notes.update", qNoteUpdate,
+			[]any{&n.ID, &n.Title, &n.Content, &n.Language, &n.CreatedAt}, title, content, language, id)
+	})
+	return n, err
+}
+
+// Delete is retried only when the failed attempt had no effect, see
+// Repository.Delete.
+func (r *PgxRepository) Delete(ctx context.Context, id int64) error {
+	var a int64
+	err := r.retry.do(ctx, "notes.delete", false, func() (err error) {
+		a, err = r.exec(ctx, "notes.delete", qNoteDelete, id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if a == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *PgxRepository) List(ctx context.Context, p ListParams) ([]Note, error) {
+	var out []Note
+	err := r.retry.do(ctx, "notes.list", true, func() (err error) {
+		out, err = r.list(ctx, p)
+		return err
+	})
+	return out, err
+}
+
+func (r *PgxRepository) list(ctx context.Context, p ListParams) ([]Note, error) {
+	if p.Limit <= 0 || p.Limit > 200 {
+		p.Limit = 20
+	}
+
+	if p.Query != "" && p.Fuzzy {
+		return pgxQueryAll(ctx, r, "notes.fuzzy", qNoteFuzzy, scanScoredNotes, p.Query, p.Limit)
+	}
+
+	if p.Query != "" {
+		if !SupportedLanguage(p.Language) {
+			p.Language = DetectLanguage(p.Query)
+		}
+		var cursorAt *time.Time
+		var cursorID *int64
+		if p.CursorCreatedAt != nil && p.CursorID != nil {
+			cursorAt, cursorID = p.CursorCreatedAt, p.CursorID
+		}
+		return pgxQueryAll(ctx, r, "notes.search", qNoteSearch, scanSearchNotes,
+			p.Query, p.Limit, p.Highlight.titleHeadline(), p.Highlight.headline(), p.Language, cursorAt, cursorID)
+	}
+
+	if p.CursorCreatedAt != nil && p.CursorID != nil {
+		return pgxQueryAll(ctx, r, "notes.list_after", qNoteListAfter, scanNotes,
+			*p.CursorCreatedAt, *p.CursorID, p.Limit)
+	}
+	return pgxQueryAll(ctx, r, "notes.list_first", qNoteListFirst, scanNotes, p.Limit)
+}
+
+func (r *PgxRepository) Suggest(ctx context.Context, prefix string, limit int) ([]Suggestion, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	prefix = strings.ToLower(prefix)
+	var out []Suggestion
+	err := r.retry.do(ctx, "notes.suggest", true, func() (err error) {
+		out, err = pgxQueryAll(ctx, r, "notes.suggest", qNoteSuggest, scanSuggestions, escapeLike(prefix), prefix, limit)
+		return err
+	})
+	return out, err
+}
+
+// Related returns sql.ErrNoRows if the note is missing, see Repository.Related.
+func (r *PgxRepository) Related(ctx context.Context, id int64, limit int) ([]RelatedNote, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	var out []RelatedNote
+	err := r.retry.do(ctx, "notes.related", true, func() error {
+		var err error
+		out, err = pgxQueryAll(ctx, r, "notes.related", qNoteRelated, scanRelated,
+			id, limit, RelatedTextWeight, RelatedTitleWeight, RelatedTagWeight, RelatedLinkWeight)
+		if err != nil || len(out) > 0 {
+			return err
+		}
+		var exists bool
+		if err := r.queryRow(ctx, "notes.exists", qNoteExists, []any{&exists}, id); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *PgxRepository) BatchGet(ctx context.Context, ids []int64) ([]Note, error) {
+	if len(ids) == 0 {
+		return []Note{}, nil
+	}
+	var out []Note
+	err := r.retry.do(ctx, "notes.batch_get", true, func() (err error) {
+		out, err = pgxQueryAll(ctx, r, "notes.batch_get", qNoteBatchGet, scanNotes, ids)
+		return err
+	})
+	return out, err
+}
+
+// pgxQueryAll runs a statement and scans all rows with scan.
+func pgxQueryAll[T any](ctx context.Context, r *PgxRepository, name, query string, scan func(rowIterator) ([]T, error), args ...any) ([]T, error) {
+	ctx, done := r.hooks.start(ctx, name, query, args)
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		done(0, err)
+		return nil, err
+	}
+	defer rows.Close()
+	out, err := scan(rows)
+	done(int64(len(out)), err)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// queryRow runs a statement returning a single row into dest; a missing row
+// is sql.ErrNoRows, like with database/sql.
+func (r *PgxRepository) queryRow(ctx context.Context, name, query string, dest []any, args ...any) error {
+	ctx, done := r.hooks.start(ctx, name, query, args)
+	err := r.pool.QueryRow(ctx, query, args...).Scan(dest...)
+	done(rowsOf(err), err)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// exec runs a statement and returns the number of affected rows.
+func (r *PgxRepository) exec(ctx context.Context, name, query string, args ...any) (int64, error) {
+	ctx, done := r.hooks.start(ctx, name, query, args)
+	tag, err := r.pool.Exec(ctx, query, args...)
+	if err != nil {
+		done(0, err)
+		return 0, err
+	}
+	done(tag.RowsAffected(), nil)
+	return tag.RowsAffected(), nil
+}
+
+// rowsOf is the row count of a single-row statement.
+func rowsOf(err error) int64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
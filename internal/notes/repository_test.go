@@ -0,0 +1,157 @@
+package notes_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/migrate"
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/notes/storetest"
+	"example.com/notes-api-pz14/migrations"
+)
+
+// testDB opens TEST_DATABASE_URL and migrates it, or skips the test. Tests
+// truncate notes, so never point it at real data.
+func testDB(tb testing.TB) (*sql.DB, string) {
+	tb.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("pgx", url)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = db.Close() })
+
+	list, err := migrate.Load(migrations.FS)
+	require.NoError(tb, err)
+	_, err = migrate.New(db, list).Up(context.Background(), 0)
+	require.NoError(tb, err)
+	return db, url
+}
+
+func truncate(tb testing.TB, db *sql.DB) {
+	tb.Helper()
+	_, err := db.ExecContext(context.Background(), `TRUNCATE notes RESTART IDENTITY CASCADE`)
+	require.NoError(tb, err)
+}
+
+// TestRepository_Conformance runs the store suite against Postgres.
+func TestRepository_Conformance(t *testing.T) {
+	ctx := context.Background()
+	db, _ := testDB(t)
+
+	storetest.Run(t, func(t *testing.T) notes.Store {
+		truncate(t, db)
+		repo, err := notes.NewRepository(ctx, db)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = repo.Close() })
+		return repo
+	})
+}
+
+func TestPgxRepository_Conformance(t *testing.T) {
+	ctx := context.Background()
+	db, url := testDB(t)
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	storetest.Run(t, func(t *testing.T) notes.Store {
+		truncate(t, db)
+		return notes.NewPgxRepository(pool)
+	})
+}
+
+func TestPgxRepository_CreateAudit(t *testing.T) {
+	ctx := context.Background()
+	db, url := testDB(t)
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	truncate(t, db)
+
+	repo := notes.NewPgxRepository(pool)
+	n, err := repo.Create(ctx, "Title", "content", notes.LangEnglish)
+	require.NoError(t, err)
+	var noteID int64
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT note_id FROM notes_audit WHERE action = 'create'`).Scan(&noteID))
+	require.Equal(t, n.ID, noteID)
+
+	// A failing insert rolls back the whole batch.
+	_, err = repo.Create(ctx, "Title", "content", "klingon")
+	require.Error(t, err)
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT count(*) FROM notes_audit`).Scan(&count))
+	require.Equal(t, 1, count)
+}
+
+// The SQL functions behind the related notes indexes agree with Tags, Links
+// and References whatever the database locale.
+func TestRepository_ReferenceFunctions(t *testing.T) {
+	ctx := context.Background()
+	_, url := testDB(t)
+	pool, err := pgxpool.New(ctx, url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	for _, content := range []string{
+		"#Go and #go, #Базы_Данных #базы_данных #ЁЖИК\t#ёжик, not issue#12",
+		"see https://example.com/a?b=1, (https://пример.рф/notes/7) and /notes/42.",
+		"#x\u00a0#y https://example.com/\u00a0tail",
+	} {
+		var tags, links []string
+		var refs []int64
+		require.NoError(t, pool.QueryRow(ctx,
+			`SELECT notes_tags($1), notes_links($1), notes_refs($1)`, content).Scan(&tags, &links, &refs))
+		require.ElementsMatch(t, notes.Tags(content), tags, content)
+		require.ElementsMatch(t, notes.Links(content), links, content)
+		require.ElementsMatch(t, notes.References(content), refs, content)
+	}
+}
+
+// brokenReplica hands out a closed database, so every replica read fails.
+type brokenReplica struct {
+	db     *sql.DB
+	failed int
+}
+
+func (b *brokenReplica) Reader(context.Context) (*sql.DB, bool) { return b.db, true }
+
+func (b *brokenReplica) Failed(db *sql.DB, err error) {
+	if db == b.db && err != nil {
+		b.failed++
+	}
+}
+
+func TestRepository_ReplicaFallback(t *testing.T) {
+	ctx := context.Background()
+	db, url := testDB(t)
+	truncate(t, db)
+	closed, err := sql.Open("pgx", url)
+	require.NoError(t, err)
+	require.NoError(t, closed.Close())
+
+	replicas := &brokenReplica{db: closed}
+	repo, err := notes.NewRepository(ctx, db)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	repo.WithReplicas(replicas)
+
+	n, err := repo.Create(ctx, "Title", "content", notes.LangEnglish)
+	require.NoError(t, err)
+	_, err = repo.Get(ctx, n.ID)
+	require.NoError(t, err)
+	items, err := repo.List(ctx, notes.ListParams{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	items, err = repo.BatchGet(ctx, []int64{n.ID})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, 3, replicas.failed)
+}
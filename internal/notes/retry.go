@@ -0,0 +1,135 @@
+package notes
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy retries repository calls that failed for a transient reason,
+// such as a Postgres failover, with exponential backoff and full jitter. The
+// zero value does not retry.
+type RetryPolicy struct {
+	// MaxAttempts counts the first call; values below 2 disable retries.
+	MaxAttempts int
+	// The delay before retry n (from 1) is random in [0, BaseDelay*2^(n-1)],
+	// capped at MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// OnRetry, if set, is called before each retry with the statement or
+	// transaction name (e.g. "notes.get") and the error that caused it.
+	OnRetry func(ctx context.Context, name string, attempt int, err error)
+}
+
+// Transient reports whether err may go away on retry: the server rolled the
+// statement back (serialization failure, deadlock, admin or crash shutdown,
+// a server that does not accept connections yet) or the connection was lost.
+// Cancellation and deadlines are not transient.
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08: connection exception.
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		pgconn.SafeToRetry(err) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// notApplied reports whether a transient err proves the statement had no
+// effect: the server reported the error, or nothing reached it. A connection
+// lost after the statement was sent leaves its outcome unknown.
+func notApplied(err error) bool {
+	var pgErr *pgconn.PgError
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &pgErr) || errors.As(err, &connectErr) || pgconn.SafeToRetry(err)
+}
+
+// commitError marks a failed COMMIT: a lost connection there leaves the
+// transaction's outcome unknown, so it is not retried.
+type commitError struct{ err error }
+
+func (e commitError) Error() string { return e.err.Error() }
+func (e commitError) Unwrap() error { return e.err }
+
+func unwrapCommit(err error) error {
+	if ce, ok := err.(commitError); ok {
+		return ce.err
+	}
+	return err
+}
+
+// do runs fn until it succeeds, fails for a non-transient reason, runs out of
+// attempts or ctx ends; the last error is returned. Calls that are not
+// idempotent are retried only when the failed attempt had no effect.
+func (p RetryPolicy) do(ctx context.Context, name string, idempotent bool, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.retryable(err, idempotent) {
+			return unwrapCommit(err)
+		}
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return unwrapCommit(err)
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(ctx, name, attempt, err)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return unwrapCommit(err)
+		case <-t.C:
+		}
+	}
+}
+
+func (p RetryPolicy) retryable(err error, idempotent bool) bool {
+	if !Transient(err) {
+		return false
+	}
+	var ce commitError
+	if errors.As(err, &ce) {
+		idempotent = false
+	}
+	return idempotent || notApplied(err)
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MaxDelay
+	if attempt < 32 {
+		if exp := p.BaseDelay << (attempt - 1); exp > 0 && (d <= 0 || exp < d) {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
@@ -0,0 +1,159 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+// safeErr is an error pgx reports before anything was sent.
+type safeErr struct{}
+
+func (safeErr) Error() string     { return "conn busy" }
+func (safeErr) SafeToRetry() bool { return true }
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"no rows", sql.ErrNoRows, false},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"cannot connect now", &pgconn.PgError{Code: "57P03"}, true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+		{"bad conn", driver.ErrBadConn, true},
+		{"unexpected EOF", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"safe to retry", safeErr{}, true},
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Transient(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	serialization := &pgconn.PgError{Code: "40001"}
+	lost := fmt.Errorf("read: %w", io.ErrUnexpectedEOF)
+
+	// failing returns errs in order, then nil, and counts calls.
+	failing := func(calls *int, errs ...error) func() error {
+		return func() error {
+			*calls++
+			if *calls <= len(errs) {
+				return errs[*calls-1]
+			}
+			return nil
+		}
+	}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		var calls int
+		var retried []int
+		p := p
+		p.OnRetry = func(_ context.Context, name string, attempt int, err error) {
+			require.Equal(t, "notes.get", name)
+			require.ErrorIs(t, err, serialization)
+			retried = append(retried, attempt)
+		}
+		err := p.do(context.Background(), "notes.get", true, failing(&calls, serialization, serialization))
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+		require.Equal(t, []int{1, 2}, retried)
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		var calls int
+		err := p.do(context.Background(), "notes.get", true, failing(&calls, lost, lost, lost, lost))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		var calls int
+		err := p.do(context.Background(), "notes.get", true, failing(&calls, sql.ErrNoRows))
+		require.ErrorIs(t, err, sql.ErrNoRows)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("zero value does not retry", func(t *testing.T) {
+		var calls int
+		err := RetryPolicy{}.do(context.Background(), "notes.get", true, failing(&calls, serialization))
+		require.ErrorIs(t, err, serialization)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("not idempotent: unknown outcome is not retried", func(t *testing.T) {
+		var calls int
+		err := p.do(context.Background(), "notes.delete", false, failing(&calls, lost))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		require.Equal(t, 1, calls)
+
+		calls = 0
+		err = p.do(context.Background(), "notes.delete", false, failing(&calls, serialization, safeErr{}))
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("lost commit is not retried", func(t *testing.T) {
+		var calls int
+		err := p.do(context.Background(), "notes.create", true, failing(&calls, lost, commitError{lost}))
+		require.Equal(t, lost, err, "commitError is unwrapped")
+		require.Equal(t, 2, calls)
+
+		calls = 0
+		err = p.do(context.Background(), "notes.create", true, failing(&calls, commitError{serialization}))
+		require.NoError(t, err)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("bounded by the context", func(t *testing.T) {
+		slow := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		var calls int
+		start := time.Now()
+		err := slow.do(ctx, "notes.get", true, failing(&calls, serialization, serialization))
+		require.ErrorIs(t, err, serialization)
+		require.Less(t, time.Since(start), time.Second)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		calls = 0
+		err = slow.do(ctx, "notes.get", true, failing(&calls, serialization))
+		require.ErrorIs(t, err, serialization)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{
+		1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond,
+		4: 50 * time.Millisecond, 40: 50 * time.Millisecond,
+	} {
+		for i := 0; i < 100; i++ {
+			d := p.backoff(attempt)
+			require.GreaterOrEqual(t, d, time.Duration(0))
+			require.LessOrEqual(t, d, max, "attempt %d", attempt)
+		}
+	}
+	require.Zero(t, RetryPolicy{}.backoff(1))
+}
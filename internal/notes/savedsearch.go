@@ -0,0 +1,171 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrSavedSearchExists is returned when a saved search name is already taken.
+var ErrSavedSearchExists = errors.New("saved search already exists")
+
+// SavedSearch is a named search query with its filters: Language, or Fuzzy
+// for trigram matching on titles as with GET /notes?fuzzy=1. When Notify is set, the background worker records newly matching notes in
+// the inbox and, if WebhookURL is not empty, posts them to the webhook.
+type SavedSearch struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Query      string    `json:"query"`
+	Language   string    `json:"language"`
+	Fuzzy      bool      `json:"fuzzy"`
+	Notify     bool      `json:"notify"`
+	WebhookURL string    `json:"webhook_url,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SavedSearchMatch is an inbox entry: a note that started matching a saved search.
+type SavedSearchMatch struct {
+	ID            int64      `json:"id"`
+	SavedSearchID int64      `json:"saved_search_id"`
+	NoteID        int64      `json:"note_id"`
+	Title         string     `json:"title"`
+	MatchedAt     time.Time  `json:"matched_at"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+}
+
+type CreateSavedSearchRequest struct {
+	Name       string `json:"name"`
+	Query      string `json:"query"`
+	Language   string `json:"language,omitempty"`
+	Fuzzy      bool   `json:"fuzzy"`
+	Notify     bool   `json:"notify"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// SavedSearchStore is an abstraction over saved searches and their inbox.
+type SavedSearchStore interface {
+	CreateSavedSearch(ctx context.Context, s SavedSearch) (SavedSearch, error)
+	GetSavedSearch(ctx context.Context, id int64) (SavedSearch, error)
+	ListSavedSearches(ctx context.Context) ([]SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, id int64) error
+
+	// Inbox returns matches of a saved search, newest first, with id below beforeID (if > 0).
+	Inbox(ctx context.Context, searchID int64, beforeID int64, limit int, unreadOnly bool) ([]SavedSearchMatch, error)
+	MarkInboxRead(ctx context.Context, searchID int64) error
+
+	// ListNotifiedSearches returns saved searches with notifications enabled.
+	ListNotifiedSearches(ctx context.Context) ([]SavedSearch, error)
+	// CollectMatches records notes changed in (s.CheckedAt, until] that match s
+	// and were not matched before, moves the high-water mark to until and
+	// returns the new matches.
+	CollectMatches(ctx context.Context, s SavedSearch, until time.Time) ([]SavedSearchMatch, error)
+}
+
+func scanSavedSearch(row interface{ Scan(...any) error }) (SavedSearch, error) {
+	var s SavedSearch
+	err := row.Scan(savedSearchDest(&s)...)
+	return s, err
+}
+
+func savedSearchDest(s *SavedSearch) []any {
+	return []any{&s.ID, &s.Name, &s.Query, &s.Language, &s.Fuzzy, &s.Notify, &s.WebhookURL, &s.CheckedAt, &s.CreatedAt}
+}
+
+func (r *Repository) CreateSavedSearch(ctx context.Context, s SavedSearch) (SavedSearch, error) {
+	var out SavedSearch
+	err := r.queryRow(ctx, r.db, "saved_searches.insert", qSavedSearchInsert, savedSearchDest(&out),
+		s.Name, s.Query, s.Language, s.Notify, s.WebhookURL, s.Fuzzy)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return SavedSearch{}, ErrSavedSearchExists
+	}
+	return out, err
+}
+
+func (r *Repository) GetSavedSearch(ctx context.Context, id int64) (SavedSearch, error) {
+	var s SavedSearch
+	err := r.queryRow(ctx, r.db, "saved_searches.get", qSavedSearchGet, savedSearchDest(&s), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SavedSearch{}, sql.ErrNoRows
+	}
+	return s, err
+}
+
+func (r *Repository) ListSavedSearches(ctx context.Context) ([]SavedSearch, error) {
+	return queryAll(ctx, r, r.db, "saved_searches.list", qSavedSearchList, scanSavedSearches)
+}
+
+func (r *Repository) ListNotifiedSearches(ctx context.Context) ([]SavedSearch, error) {
+	return queryAll(ctx, r, r.db, "saved_searches.list_notified", qSavedSearchListNotified, scanSavedSearches)
+}
+
+func scanSavedSearches(rows rowIterator) ([]SavedSearch, error) {
+	out := make([]SavedSearch, 0, 8)
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repository) DeleteSavedSearch(ctx context.Context, id int64) error {
+	a, err := r.exec(ctx, r.db, "saved_searches.delete", qSavedSearchDelete, id)
+	if err != nil {
+		return err
+	}
+	if a == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repository) Inbox(ctx context.Context, searchID int64, beforeID int64, limit int, unreadOnly bool) ([]SavedSearchMatch, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 20
+	}
+	return queryAll(ctx, r, r.db, "saved_searches.inbox", qSavedSearchInbox, scanMatches,
+		searchID, beforeID, limit, unreadOnly)
+}
+
+func (r *Repository) MarkInboxRead(ctx context.Context, searchID int64) error {
+	_, err := r.exec(ctx, r.db, "saved_searches.mark_read", qSavedSearchMarkRead, searchID)
+	return err
+}
+
+// CollectMatches runs in one transaction so the inbox and the high-water mark
+// move together.
+func (r *Repository) CollectMatches(ctx context.Context, s SavedSearch, until time.Time) ([]SavedSearchMatch, error) {
+	var matches []SavedSearchMatch
+	err := r.inTx(ctx, "saved_searches.collect", func(tx *sql.Tx) (err error) {
+		matches, err = queryAll(ctx, r, tx, "saved_searches.collect", qSavedSearchCollect, scanMatches,
+			s.ID, s.Language, s.Query, s.CheckedAt, until, s.Fuzzy)
+		if err != nil {
+			return err
+		}
+		_, err = r.exec(ctx, tx, "saved_searches.checkpoint", qSavedSearchCheckpoint, s.ID, until)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func scanMatches(rows rowIterator) ([]SavedSearchMatch, error) {
+	out := make([]SavedSearchMatch, 0, 16)
+	for rows.Next() {
+		var m SavedSearchMatch
+		if err := rows.Scan(&m.ID, &m.SavedSearchID, &m.NoteID, &m.Title, &m.MatchedAt, &m.ReadAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,170 @@
+package notes
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handlers) createSavedSearch(w http.ResponseWriter, r *http.Request) {
+	var req CreateSavedSearchRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.TrimSpace(req.Query) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name and query required"})
+		return
+	}
+	if req.Language == "" {
+		req.Language = DetectLanguage(req.Query)
+	}
+	if !SupportedLanguage(req.Language) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported language"})
+		return
+	}
+	if req.WebhookURL != "" {
+		if err := checkWebhookURL(r.Context(), h.lookupHost, req.WebhookURL); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid webhook_url: " + err.Error()})
+			return
+		}
+	}
+
+	s, err := h.saved.CreateSavedSearch(r.Context(), SavedSearch{
+		Name:       req.Name,
+		Query:      req.Query,
+		Language:   req.Language,
+		Fuzzy:      req.Fuzzy,
+		Notify:     req.Notify,
+		WebhookURL: req.WebhookURL,
+	})
+	if err == ErrSavedSearchExists {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func (h *Handlers) listSavedSearches(w http.ResponseWriter, r *http.Request) {
+	items, err := h.saved.ListSavedSearches(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+func (h *Handlers) getSavedSearch(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadSavedSearch(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func (h *Handlers) deleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+
+	if err := h.saved.DeleteSavedSearch(r.Context(), id); err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	} else if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// savedSearchResults re-runs the saved query with its filters and keyset
+// pagination (cursor_created_at + cursor_id), same as GET /notes?q=.
+func (h *Handlers) savedSearchResults(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	p := pageParams(r)
+	p.Query = s.Query
+	p.Language = s.Language
+	p.Fuzzy = s.Fuzzy
+	p.Highlight = h.highlight
+
+	items, err := h.store.List(r.Context(), p)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	h.writeNotesPage(w, items, s.Query, !s.Fuzzy)
+}
+
+// savedSearchInbox lists notes that started matching the saved search,
+// paginated by before_id (the next_before_id of the previous page).
+func (h *Handlers) savedSearchInbox(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		limit = v
+	}
+	var beforeID int64
+	if v, err := strconv.ParseInt(r.URL.Query().Get("before_id"), 10, 64); err == nil {
+		beforeID = v
+	}
+
+	items, err := h.saved.Inbox(r.Context(), s.ID, beforeID, limit, isTrue(r.URL.Query().Get("unread")))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	resp := map[string]any{"items": items}
+	if len(items) > 0 {
+		resp["next_before_id"] = items[len(items)-1].ID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handlers) markSavedSearchInboxRead(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadSavedSearch(w, r)
+	if !ok {
+		return
+	}
+	if err := h.saved.MarkInboxRead(r.Context(), s.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) loadSavedSearch(w http.ResponseWriter, r *http.Request) (SavedSearch, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return SavedSearch{}, false
+	}
+
+	s, err := h.saved.GetSavedSearch(r.Context(), id)
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return SavedSearch{}, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return SavedSearch{}, false
+	}
+	return s, true
+}
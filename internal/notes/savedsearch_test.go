@@ -0,0 +1,275 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubSavedStore struct {
+	createFn   func(context.Context, SavedSearch) (SavedSearch, error)
+	getFn      func(context.Context, int64) (SavedSearch, error)
+	listFn     func(context.Context) ([]SavedSearch, error)
+	deleteFn   func(context.Context, int64) error
+	inboxFn    func(context.Context, int64, int64, int, bool) ([]SavedSearchMatch, error)
+	markReadFn func(context.Context, int64) error
+	notifiedFn func(context.Context) ([]SavedSearch, error)
+	collectFn  func(context.Context, SavedSearch, time.Time) ([]SavedSearchMatch, error)
+}
+
+func (s stubSavedStore) CreateSavedSearch(ctx context.Context, ss SavedSearch) (SavedSearch, error) {
+	return s.createFn(ctx, ss)
+}
+func (s stubSavedStore) GetSavedSearch(ctx context.Context, id int64) (SavedSearch, error) {
+	return s.getFn(ctx, id)
+}
+func (s stubSavedStore) ListSavedSearches(ctx context.Context) ([]SavedSearch, error) {
+	return s.listFn(ctx)
+}
+func (s stubSavedStore) DeleteSavedSearch(ctx context.Context, id int64) error {
+	return s.deleteFn(ctx, id)
+}
+func (s stubSavedStore) Inbox(ctx context.Context, searchID, beforeID int64, limit int, unreadOnly bool) ([]SavedSearchMatch, error) {
+	return s.inboxFn(ctx, searchID, beforeID, limit, unreadOnly)
+}
+func (s stubSavedStore) MarkInboxRead(ctx context.Context, searchID int64) error {
+	return s.markReadFn(ctx, searchID)
+}
+func (s stubSavedStore) ListNotifiedSearches(ctx context.Context) ([]SavedSearch, error) {
+	return s.notifiedFn(ctx)
+}
+func (s stubSavedStore) CollectMatches(ctx context.Context, ss SavedSearch, until time.Time) ([]SavedSearchMatch, error) {
+	return s.collectFn(ctx, ss, until)
+}
+
+func TestHandlers_SavedSearches_Create(t *testing.T) {
+	saved := stubSavedStore{
+		createFn: func(_ context.Context, s SavedSearch) (SavedSearch, error) {
+			if s.Name == "dup" {
+				return SavedSearch{}, ErrSavedSearchExists
+			}
+			require.Equal(t, LangRussian, s.Language)
+			require.Equal(t, s.Name == "fuzzy", s.Fuzzy)
+			s.ID = 1
+			return s, nil
+		},
+	}
+	handlers := NewHandlers(stubStore{}).WithSavedSearches(saved)
+	handlers.lookupHost = func(_ context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.215.14")}, nil
+		case "localhost":
+			return []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1")}, nil
+		case "rebind.example":
+			return []netip.Addr{netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("10.1.2.3")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	h := handlers.Routes()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing query", `{"name":"n"}`, http.StatusBadRequest},
+		{"bad webhook", `{"name":"n","query":"заметки","webhook_url":"ftp://x"}`, http.StatusBadRequest},
+		{"metadata webhook", `{"name":"n","query":"заметки","webhook_url":"http://169.254.169.254/latest/meta-data"}`, http.StatusBadRequest},
+		{"loopback webhook", `{"name":"n","query":"заметки","webhook_url":"http://localhost:5432"}`, http.StatusBadRequest},
+		{"private webhook", `{"name":"n","query":"заметки","webhook_url":"http://10.0.0.5/hook"}`, http.StatusBadRequest},
+		{"ipv6 loopback webhook", `{"name":"n","query":"заметки","webhook_url":"http://[::1]:8080/"}`, http.StatusBadRequest},
+		{"unspecified webhook", `{"name":"n","query":"заметки","webhook_url":"http://0.0.0.0/"}`, http.StatusBadRequest},
+		{"partly private webhook", `{"name":"n","query":"заметки","webhook_url":"https://rebind.example/"}`, http.StatusBadRequest},
+		{"unresolvable webhook", `{"name":"n","query":"заметки","webhook_url":"https://nowhere.invalid/"}`, http.StatusBadRequest},
+		{"duplicate", `{"name":"dup","query":"заметки"}`, http.StatusConflict},
+		{"created", `{"name":"n","query":"заметки","notify":true,"webhook_url":"https://example.com/hook"}`, http.StatusCreated},
+		{"created fuzzy", `{"name":"fuzzy","query":"заметки","fuzzy":true}`, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/saved-searches/", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			require.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+}
+
+func TestHandlers_SavedSearches_ResultsAndInbox(t *testing.T) {
+	fixed := time.Unix(6, 0).UTC()
+	saved := stubSavedStore{
+		getFn: func(_ context.Context, id int64) (SavedSearch, error) {
+			switch id {
+			case 7:
+				return SavedSearch{ID: 7, Name: "go", Query: "go", Language: LangEnglish}, nil
+			case 9:
+				return SavedSearch{ID: 9, Name: "typo", Query: "databse", Language: LangEnglish, Fuzzy: true}, nil
+			}
+			return SavedSearch{}, sql.ErrNoRows
+		},
+		inboxFn: func(_ context.Context, searchID, beforeID int64, limit int, unreadOnly bool) ([]SavedSearchMatch, error) {
+			require.Equal(t, int64(7), searchID)
+			require.Equal(t, int64(100), beforeID)
+			require.True(t, unreadOnly)
+			return []SavedSearchMatch{{ID: 99, SavedSearchID: 7, NoteID: 1}}, nil
+		},
+	}
+	store := stubStore{
+		listFn: func(_ context.Context, p ListParams) ([]Note, error) {
+			if p.Fuzzy {
+				require.Equal(t, "databse", p.Query)
+				return []Note{{ID: 4, Title: "database", CreatedAt: fixed}}, nil
+			}
+			require.Equal(t, "go", p.Query)
+			require.Equal(t, LangEnglish, p.Language)
+			require.Equal(t, 5, p.Limit)
+			require.NotNil(t, p.CursorID)
+			require.Equal(t, int64(10), *p.CursorID)
+			return []Note{{ID: 3, Title: "go", Content: "go go", CreatedAt: fixed}}, nil
+		},
+	}
+	h := NewHandlers(store).WithSavedSearches(saved).Routes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusNotFound, get("/saved-searches/8/results").Code)
+
+	rr := get("/saved-searches/7/results?limit=5&cursor_created_at=" + fixed.Format(time.RFC3339Nano) + "&cursor_id=10")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page map[string]any
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	require.Equal(t, float64(3), page["next_cursor_id"])
+
+	// Fuzzy searches are re-run fuzzy, without a cursor.
+	rr = get("/saved-searches/9/results")
+	require.Equal(t, http.StatusOK, rr.Code)
+	page = nil
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	require.Len(t, page["items"], 1)
+	require.NotContains(t, page, "next_cursor_id")
+
+	rr = get("/saved-searches/7/inbox?before_id=100&unread=1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&page))
+	require.Equal(t, float64(99), page["next_before_id"])
+}
+
+func TestSavedSearchWorker_RunOnce(t *testing.T) {
+	hooks := make(chan webhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p webhookPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		hooks <- p
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1000, 0).UTC()
+	var collectedUntil time.Time
+	saved := stubSavedStore{
+		notifiedFn: func(context.Context) ([]SavedSearch, error) {
+			return []SavedSearch{
+				{ID: 1, Name: "fresh", CheckedAt: now}, // checked after the lag window: skipped
+				{ID: 2, Name: "go", Query: "go", CheckedAt: now.Add(-time.Minute), WebhookURL: srv.URL},
+			}, nil
+		},
+		collectFn: func(_ context.Context, s SavedSearch, until time.Time) ([]SavedSearchMatch, error) {
+			require.Equal(t, int64(2), s.ID)
+			collectedUntil = until
+			return []SavedSearchMatch{{ID: 1, SavedSearchID: 2, NoteID: 5, Title: "go"}}, nil
+		},
+	}
+
+	w := NewSavedSearchWorker(saved, WebhookNotifier{Client: srv.Client()}, time.Second, 5*time.Second)
+	w.now = func() time.Time { return now }
+
+	require.NoError(t, w.RunOnce(context.Background()))
+	require.Equal(t, now.Add(-5*time.Second), collectedUntil)
+
+	p := <-hooks
+	require.Equal(t, int64(2), p.SavedSearchID)
+	require.Len(t, p.Matches, 1)
+	require.Equal(t, int64(5), p.Matches[0].NoteID)
+}
+
+func TestSavedSearchWorker_RunOnce_FailingSearch(t *testing.T) {
+	now := time.Unix(1000, 0).UTC()
+	boom := errors.New("lock timeout")
+	var collected []int64
+	saved := stubSavedStore{
+		notifiedFn: func(context.Context) ([]SavedSearch, error) {
+			return []SavedSearch{
+				{ID: 1, CheckedAt: now.Add(-time.Minute)},
+				{ID: 2, CheckedAt: now.Add(-time.Minute)},
+				{ID: 3, CheckedAt: now.Add(-time.Minute)},
+			}, nil
+		},
+		collectFn: func(_ context.Context, s SavedSearch, _ time.Time) ([]SavedSearchMatch, error) {
+			collected = append(collected, s.ID)
+			if s.ID == 1 {
+				return nil, boom
+			}
+			return nil, nil
+		},
+	}
+
+	w := NewSavedSearchWorker(saved, nil, time.Second, 0)
+	w.now = func() time.Time { return now }
+
+	err := w.RunOnce(context.Background())
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, []int64{1, 2, 3}, collected, "the other searches still run")
+}
+
+func TestWebhookTransport_RefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("webhook reached a loopback server")
+	}))
+	defer srv.Close()
+
+	n := WebhookNotifier{Client: &http.Client{Transport: NewWebhookTransport()}}
+	err := n.Notify(context.Background(), SavedSearch{ID: 1, WebhookURL: srv.URL}, nil)
+	require.ErrorIs(t, err, errWebhookAddr)
+
+	// The default client is guarded too.
+	err = WebhookNotifier{}.Notify(context.Background(), SavedSearch{ID: 1, WebhookURL: srv.URL}, nil)
+	require.ErrorIs(t, err, errWebhookAddr)
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.215.14":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.0.0.1":         false,
+		"172.16.5.4":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::":               false,
+		"224.0.0.1":        false,
+		"::ffff:127.0.0.1": false,
+	} {
+		require.Equal(t, want, publicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,135 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Notifier delivers new saved search matches outside of the in-app inbox.
+type Notifier interface {
+	Notify(ctx context.Context, s SavedSearch, matches []SavedSearchMatch) error
+}
+
+// WebhookNotifier posts matches as JSON to SavedSearch.WebhookURL.
+type WebhookNotifier struct {
+	// Client sends the webhooks; nil uses a client with NewWebhookTransport.
+	// Other clients should use that transport too.
+	Client *http.Client
+}
+
+var defaultWebhookClient = &http.Client{Transport: NewWebhookTransport(), Timeout: 10 * time.Second}
+
+type webhookPayload struct {
+	SavedSearchID int64              `json:"saved_search_id"`
+	Name          string             `json:"name"`
+	Query         string             `json:"query"`
+	Matches       []SavedSearchMatch `json:"matches"`
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, s SavedSearch, matches []SavedSearchMatch) error {
+	if s.WebhookURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(webhookPayload{SavedSearchID: s.ID, Name: s.Name, Query: s.Query, Matches: matches})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: status %d", s.WebhookURL, resp.StatusCode)
+	}
+	return nil
+}
+
+// SavedSearchWorker periodically evaluates saved searches with notifications
+// enabled against notes created or updated since the previous run.
+type SavedSearchWorker struct {
+	store    SavedSearchStore
+	notifier Notifier
+	interval time.Duration
+	// lag keeps the high-water mark behind now(), so rows from transactions
+	// still in flight (updated_at is their start time) are not skipped.
+	lag time.Duration
+	now func() time.Time
+}
+
+func NewSavedSearchWorker(store SavedSearchStore, notifier Notifier, interval, lag time.Duration) *SavedSearchWorker {
+	return &SavedSearchWorker{
+		store:    store,
+		notifier: notifier,
+		interval: interval,
+		lag:      lag,
+		now:      time.Now,
+	}
+}
+
+// Run evaluates saved searches every interval until ctx is cancelled.
+func (w *SavedSearchWorker) Run(ctx context.Context) {
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+	for {
+		if err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "saved searches", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce evaluates every notified saved search once. A search that fails is
+// logged and skipped until the next run, so it does not hold up the others;
+// the errors are returned joined.
+func (w *SavedSearchWorker) RunOnce(ctx context.Context) error {
+	searches, err := w.store.ListNotifiedSearches(ctx)
+	if err != nil {
+		return err
+	}
+	until := w.now().Add(-w.lag)
+	var errs []error
+	for _, s := range searches {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if !until.After(s.CheckedAt) {
+			continue
+		}
+		matches, err := w.store.CollectMatches(ctx, s, until)
+		if err != nil {
+			slog.ErrorContext(ctx, "saved search collect", "saved_search_id", s.ID, "error", err)
+			errs = append(errs, fmt.Errorf("collect %d: %w", s.ID, err))
+			continue
+		}
+		if len(matches) == 0 || w.notifier == nil {
+			continue
+		}
+		// Matches are already in the inbox; a failed webhook is not retried.
+		if err := w.notifier.Notify(ctx, s, matches); err != nil {
+			slog.ErrorContext(ctx, "saved search notify", "saved_search_id", s.ID, "error", err)
+		}
+	}
+	return errors.Join(errs...)
+}
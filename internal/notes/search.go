@@ -0,0 +1,133 @@
+package notes
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"example.com/notes-api-pz14/internal/stringsx"
+	"example.com/notes-api-pz14/internal/textsearch"
+)
+
+// Highlight holds search snippets with matched terms wrapped into markup.
+// They are HTML: the note text is escaped, only the StartSel, StopSel and
+// Delimiter options are inserted as they are.
+type Highlight struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// HighlightOptions configures ts_headline (and the Go fallback) for search results.
+type HighlightOptions struct {
+	MaxFragments int
+	MaxWords     int
+	MinWords     int
+	StartSel     string
+	StopSel      string
+	Delimiter    string
+}
+
+// DefaultHighlightOptions are used when the handlers are not configured explicitly.
+var DefaultHighlightOptions = HighlightOptions{
+	MaxFragments: 2,
+	MaxWords:     20,
+	MinWords:     5,
+	StartSel:     "<b>",
+	StopSel:      "</b>",
+	Delimiter:    " ... ",
+}
+
+func (o HighlightOptions) normalize() HighlightOptions {
+	d := DefaultHighlightOptions
+	if o.MaxFragments <= 0 {
+		o.MaxFragments = d.MaxFragments
+	}
+	if o.MaxWords <= 0 {
+		o.MaxWords = d.MaxWords
+	}
+	// ts_headline rejects MinWords >= MaxWords.
+	if o.MinWords <= 0 || o.MinWords >= o.MaxWords {
+		o.MinWords = max(1, min(d.MinWords, o.MaxWords-1))
+	}
+	if o.StartSel == "" && o.StopSel == "" {
+		o.StartSel, o.StopSel = d.StartSel, d.StopSel
+	}
+	if o.Delimiter == "" {
+		o.Delimiter = d.Delimiter
+	}
+	return o
+}
+
+// headline renders options for ts_headline on content.
+func (o HighlightOptions) headline() string {
+	o = o.normalize()
+	return fmt.Sprintf(`MaxFragments=%d, MaxWords=%d, MinWords=%d, StartSel="%s", StopSel="%s", FragmentDelimiter="%s"`,
+		o.MaxFragments, o.MaxWords, o.MinWords, quoteOpt(o.StartSel), quoteOpt(o.StopSel), quoteOpt(o.Delimiter))
+}
+
+// titleHeadline renders options for ts_headline on title: the whole title is kept.
+func (o HighlightOptions) titleHeadline() string {
+	o = o.normalize()
+	return fmt.Sprintf(`HighlightAll=true, StartSel="%s", StopSel="%s"`, quoteOpt(o.StartSel), quoteOpt(o.StopSel))
+}
+
+func (o HighlightOptions) snippet() stringsx.SnippetOptions {
+	o = o.normalize()
+	return stringsx.SnippetOptions{
+		MaxFragments: o.MaxFragments,
+		MaxWords:     o.MaxWords,
+		StartSel:     o.StartSel,
+		StopSel:      o.StopSel,
+		Delimiter:    o.Delimiter,
+	}
+}
+
+// quoteOpt drops double quotes: ts_headline has no way to escape them inside a value.
+func quoteOpt(s string) string {
+	return strings.ReplaceAll(s, `"`, "")
+}
+
+// fallbackHighlight builds snippets in Go for stores that cannot do it themselves.
+func fallbackHighlight(n Note, query string, o HighlightOptions) *Highlight {
+	return highlightTerms(n, stringsx.Terms(query), o)
+}
+
+// SearchHighlight builds snippets for stores that match notes by lexemes
+// (textsearch.Lexemes) rather than with ts_headline: words of n whose stems
+// are among lexemes are marked, so inflected forms are highlighted too.
+func SearchHighlight(n Note, lexemes []string, o HighlightOptions) *Highlight {
+	want := map[string]bool{}
+	for _, l := range lexemes {
+		want[l] = true
+	}
+	var words []string
+	for _, w := range textsearch.Words(n.Title + " " + n.Content) {
+		if want[textsearch.Stem(n.Language, w)] && !textsearch.IsStopWord(n.Language, w) {
+			words = append(words, w)
+		}
+	}
+	return highlightTerms(n, words, o)
+}
+
+// Control characters stand for the markup while snippets are cut, so the
+// note text can be escaped without escaping the markup.
+const (
+	startMark = "\x01"
+	stopMark  = "\x02"
+	delimMark = "\x03"
+)
+
+var stripMarks = strings.NewReplacer(startMark, "", stopMark, "", delimMark, "")
+
+// highlightTerms builds snippets marking the given words of the note.
+func highlightTerms(n Note, terms []string, o HighlightOptions) *Highlight {
+	so := o.snippet()
+	markup := strings.NewReplacer(startMark, so.StartSel, stopMark, so.StopSel, delimMark, so.Delimiter)
+	so.StartSel, so.StopSel, so.Delimiter = startMark, stopMark, delimMark
+	title := stringsx.Highlight(stripMarks.Replace(n.Title), terms, startMark, stopMark)
+	content := stringsx.Snippet(stripMarks.Replace(n.Content), terms, so)
+	return &Highlight{
+		Title:   markup.Replace(html.EscapeString(title)),
+		Content: markup.Replace(html.EscapeString(content)),
+	}
+}
@@ -0,0 +1,81 @@
+package notes
+
+import (
+	"strings"
+	"time"
+)
+
+// QueryShape is a repository statement bound to representative arguments.
+// Shapes let tools EXPLAIN exactly the SQL the repository runs.
+type QueryShape struct {
+	// Name is the statement name reported to query hooks; variants of one
+	// statement get a suffix, e.g. "notes.search#cursor".
+	Name string
+	SQL  string
+	Args []any
+}
+
+// ShapeSample holds values from the target database the shapes are bound to,
+// so selective predicates match real rows.
+type ShapeSample struct {
+	ID        int64
+	CreatedAt time.Time
+	Title     string
+	Language  string
+	// Now bounds the saved search window; the worker looks at recent updates.
+	Now time.Time
+}
+
+// QueryShapes returns every repository read and write statement whose plan
+// depends on data. Inserts of single rows are left out. EXPLAIN without
+// ANALYZE does not run the statements, so writes are safe to include.
+func QueryShapes(s ShapeSample) []QueryShape {
+	word := keyword(s.Title)
+	lang := s.Language
+	if !SupportedLanguage(lang) {
+		lang = DetectLanguage(s.Title)
+	}
+	hl := DefaultHighlightOptions
+	prefix := strings.ToLower(string([]rune(word)[:min(3, len([]rune(word)))]))
+
+	return []QueryShape{
+		{"notes.get", qNoteGet, []any{s.ID}},
+		{"notes.exists", qNoteExists, []any{s.ID}},
+		{"notes.batch_get", qNoteBatchGet, []any{[]int64{s.ID, s.ID + 1, s.ID + 2}}},
+		{"notes.update", qNoteUpdate, []any{s.Title, "content", lang, s.ID}},
+		{"notes.delete", qNoteDelete, []any{s.ID}},
+		{"notes.list_first", qNoteListFirst, []any{20}},
+		{"notes.list_after", qNoteListAfter, []any{s.CreatedAt, s.ID, 20}},
+		{"notes.search", qNoteSearch, []any{word, 20, hl.titleHeadline(), hl.headline(), lang, nil, nil}},
+		{"notes.search#cursor", qNoteSearch, []any{word, 20, hl.titleHeadline(), hl.headline(), lang, s.CreatedAt, s.ID}},
+		{"notes.fuzzy", qNoteFuzzy, []any{typo(word), 20}},
+		{"notes.suggest", qNoteSuggest, []any{escapeLike(prefix), prefix, 10}},
+		{"notes.related", qNoteRelated, []any{s.ID, 10, RelatedTextWeight, RelatedTitleWeight, RelatedTagWeight, RelatedLinkWeight}},
+		{"saved_searches.inbox", qSavedSearchInbox, []any{1, 0, 20, false}},
+		{"saved_searches.collect", qSavedSearchCollect, []any{1, lang, word, s.Now.Add(-time.Minute), s.Now, false}},
+	}
+}
+
+// keyword picks the longest word of s, which is unlikely to be a stop word.
+func keyword(s string) string {
+	best := ""
+	for _, f := range strings.Fields(s) {
+		f = strings.ToLower(strings.Trim(f, ".,;:!?\"'()"))
+		if len([]rune(f)) > len([]rune(best)) {
+			best = f
+		}
+	}
+	if best == "" {
+		return "note"
+	}
+	return best
+}
+
+// typo swaps the last two letters, as fuzzy search is for misspellings.
+func typo(word string) string {
+	r := []rune(word)
+	if n := len(r); n > 2 {
+		r[n-1], r[n-2] = r[n-2], r[n-1]
+	}
+	return string(r)
+}
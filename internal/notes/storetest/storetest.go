@@ -0,0 +1,399 @@
+// Package storetest is a conformance suite for notes.Store implementations.
+// Every implementation runs it from its own tests:
+//
+//	storetest.Run(t, func(t *testing.T) notes.Store { return notes.NewMemoryStore() })
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// Run runs the suite. newStore must return an empty store for every call.
+func Run(t *testing.T, newStore func(t *testing.T) notes.Store) {
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, notes.Store)
+	}{
+		{"CRUD", testCRUD},
+		{"ListKeyset", testListKeyset},
+		{"ListLimit", testListLimit},
+		{"Search", testSearch},
+		{"SearchRussian", testSearchRussian},
+		{"SearchKeyset", testSearchKeyset},
+		{"SearchHighlightEscaped", testSearchHighlightEscaped},
+		{"Fuzzy", testFuzzy},
+		{"Suggest", testSuggest},
+		{"Related", testRelated},
+		{"RelatedReferences", testRelatedReferences},
+		{"BatchGet", testBatchGet},
+		{"Concurrent", testConcurrent},
+	} {
+		t.Run(tc.name, func(t *testing.T) { tc.fn(t, newStore(t)) })
+	}
+}
+
+var ctx = context.Background()
+
+func create(t *testing.T, s notes.Store, title, content, lang string) notes.Note {
+	t.Helper()
+	n, err := s.Create(ctx, title, content, lang)
+	require.NoError(t, err)
+	return n
+}
+
+func ids(items []notes.Note) []int64 {
+	out := make([]int64, len(items))
+	for i, n := range items {
+		out[i] = n.ID
+	}
+	return out
+}
+
+func testCRUD(t *testing.T, s notes.Store) {
+	n := create(t, s, "First", "hello world", notes.LangEnglish)
+	require.Positive(t, n.ID)
+	require.Equal(t, "First", n.Title)
+	require.Equal(t, "hello world", n.Content)
+	require.Equal(t, notes.LangEnglish, n.Language)
+	require.False(t, n.CreatedAt.IsZero())
+
+	got, err := s.Get(ctx, n.ID)
+	require.NoError(t, err)
+	require.Equal(t, n.ID, got.ID)
+	require.Equal(t, n.Title, got.Title)
+	require.True(t, n.CreatedAt.Equal(got.CreatedAt))
+
+	upd, err := s.Update(ctx, n.ID, "Второй", "привет", notes.LangRussian)
+	require.NoError(t, err)
+	require.Equal(t, n.ID, upd.ID)
+	require.Equal(t, []string{"Второй", "привет", notes.LangRussian}, []string{upd.Title, upd.Content, upd.Language})
+	require.True(t, n.CreatedAt.Equal(upd.CreatedAt))
+
+	_, err = s.Update(ctx, n.ID+1000, "x", "y", notes.LangSimple)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, s.Delete(ctx, n.ID))
+	_, err = s.Get(ctx, n.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.ErrorIs(t, s.Delete(ctx, n.ID), sql.ErrNoRows)
+
+	// IDs are never reused.
+	next := create(t, s, "Third", "again", notes.LangEnglish)
+	require.Greater(t, next.ID, n.ID)
+}
+
+func testListKeyset(t *testing.T, s notes.Store) {
+	var want []int64
+	for i := 0; i < 7; i++ {
+		n := create(t, s, fmt.Sprintf("note %d", i), "body", notes.LangEnglish)
+		want = append([]int64{n.ID}, want...)
+	}
+
+	var got []int64
+	p := notes.ListParams{Limit: 3}
+	for {
+		page, err := s.List(ctx, p)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		require.LessOrEqual(t, len(page), 3)
+		for _, n := range page {
+			require.Equal(t, "body", n.Content)
+			require.Nil(t, n.Highlight)
+		}
+		got = append(got, ids(page)...)
+		last := page[len(page)-1]
+		p.CursorCreatedAt, p.CursorID = &last.CreatedAt, &last.ID
+	}
+	require.Equal(t, want, got)
+
+	// A cursor needs both fields; half a cursor lists from the start.
+	first, err := s.List(ctx, notes.ListParams{Limit: 2, CursorID: &want[0]})
+	require.NoError(t, err)
+	require.Equal(t, want[:2], ids(first))
+}
+
+func testListLimit(t *testing.T, s notes.Store) {
+	for i := 0; i < 22; i++ {
+		create(t, s, "n", "c", notes.LangSimple)
+	}
+	for _, limit := range []int{0, -1, 201} {
+		items, err := s.List(ctx, notes.ListParams{Limit: limit})
+		require.NoError(t, err)
+		require.Len(t, items, 20, "limit %d", limit)
+	}
+	items, err := s.List(ctx, notes.ListParams{Limit: 200})
+	require.NoError(t, err)
+	require.Len(t, items, 22)
+}
+
+func testSearch(t *testing.T, s notes.Store) {
+	budget := create(t, s, "Quarterly budget review", "The finance team reviewed budgets for the next release.", notes.LangEnglish)
+	release := create(t, s, "Release checklist", "Deploy the new version and update the documentation.", notes.LangEnglish)
+	create(t, s, "Groceries", "milk, eggs, bread", notes.LangEnglish)
+
+	search := func(q string) []int64 {
+		t.Helper()
+		items, err := s.List(ctx, notes.ListParams{Query: q, Language: notes.LangEnglish, Limit: 10})
+		require.NoError(t, err)
+		for _, n := range items {
+			require.Empty(t, n.Content)
+			require.NotNil(t, n.Highlight)
+		}
+		return ids(items)
+	}
+
+	require.Equal(t, []int64{budget.ID}, search("budget"))
+	// Stemming: plural and verb forms match.
+	require.Equal(t, []int64{budget.ID}, search("budgets reviews"))
+	require.Equal(t, []int64{release.ID, budget.ID}, search("releases"))
+	// All words must match.
+	require.Empty(t, search("budget deploy"))
+	require.Empty(t, search("spaceship"))
+	// Stop words are ignored.
+	require.Equal(t, []int64{release.ID}, search("the checklist"))
+
+	// Without a language the query language is detected.
+	items, err := s.List(ctx, notes.ListParams{Query: "documentation", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []int64{release.ID}, ids(items))
+}
+
+func testSearchRussian(t *testing.T, s notes.Store) {
+	n := create(t, s, "План миграции базы", "Перенести таблицы заметок на новый сервер.", notes.LangRussian)
+	create(t, s, "Migration plan", "Move tables to the new server.", notes.LangEnglish)
+
+	for _, q := range []string{"миграция", "таблица", "новые серверы"} {
+		items, err := s.List(ctx, notes.ListParams{Query: q, Limit: 10})
+		require.NoError(t, err)
+		require.Equal(t, []int64{n.ID}, ids(items), q)
+	}
+
+	// Notes are matched in their own language only.
+	mixed := create(t, s, "Заметка", "tables", notes.LangRussian)
+	items, err := s.List(ctx, notes.ListParams{Query: "tables", Language: notes.LangEnglish, Limit: 10})
+	require.NoError(t, err)
+	require.NotContains(t, ids(items), mixed.ID)
+	items, err = s.List(ctx, notes.ListParams{Query: "tables", Language: notes.LangRussian, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []int64{mixed.ID}, ids(items))
+}
+
+func testSearchKeyset(t *testing.T, s notes.Store) {
+	var want []int64
+	for i := 0; i < 5; i++ {
+		n := create(t, s, fmt.Sprintf("Index report %d", i), "vacuum statistics", notes.LangEnglish)
+		want = append([]int64{n.ID}, want...)
+		create(t, s, "Unrelated", "nothing here", notes.LangEnglish)
+	}
+
+	var got []int64
+	p := notes.ListParams{Query: "vacuum", Language: notes.LangEnglish, Limit: 2}
+	for {
+		page, err := s.List(ctx, p)
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		got = append(got, ids(page)...)
+		last := page[len(page)-1]
+		p.CursorCreatedAt, p.CursorID = &last.CreatedAt, &last.ID
+	}
+	require.Equal(t, want, got)
+}
+
+// Highlights are HTML: the note text is escaped, matches are marked.
+func testSearchHighlightEscaped(t *testing.T, s notes.Store) {
+	create(t, s, `Budget "draft"`, `<script>alert(1)</script> about the budget & costs`, notes.LangEnglish)
+
+	items, err := s.List(ctx, notes.ListParams{Query: "budget", Language: notes.LangEnglish, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	hl := items[0].Highlight
+	require.NotNil(t, hl)
+	require.Equal(t, "<b>Budget</b> &#34;draft&#34;", hl.Title)
+	require.Contains(t, hl.Content, "&lt;script&gt;")
+	require.NotContains(t, hl.Content, "<script>")
+	require.Contains(t, hl.Content, "<b>budget</b> &amp; costs")
+}
+
+func testFuzzy(t *testing.T, s notes.Store) {
+	db := create(t, s, "Database tuning", "indexes", notes.LangEnglish)
+	create(t, s, "Holiday photos", "beach", notes.LangEnglish)
+
+	items, err := s.List(ctx, notes.ListParams{Query: "databse", Fuzzy: true, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []int64{db.ID}, ids(items))
+	require.Greater(t, items[0].Score, 0.0)
+	require.LessOrEqual(t, items[0].Score, 1.0)
+	require.Equal(t, "indexes", items[0].Content)
+
+	items, err = s.List(ctx, notes.ListParams{Query: "zzzqqq", Fuzzy: true, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
+
+func testSuggest(t *testing.T, s notes.Store) {
+	notesRel := create(t, s, "Release notes", "a", notes.LangEnglish)
+	plan := create(t, s, "Release plan for the next quarter", "b", notes.LangEnglish)
+	relay := create(t, s, "Relay setup", "c", notes.LangEnglish)
+	create(t, s, "Budget", "d", notes.LangEnglish)
+	pct := create(t, s, "100% done", "e", notes.LangEnglish)
+
+	got, err := s.Suggest(ctx, "rel", 10)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{notesRel.ID, plan.ID, relay.ID}, suggestionIDs(got))
+
+	got, err = s.Suggest(ctx, "RELEASE", 10)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{notesRel.ID, plan.ID}, suggestionIDs(got))
+	// Closer titles come first.
+	require.Equal(t, notesRel.ID, got[0].ID)
+
+	got, err = s.Suggest(ctx, "rel", 1)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	// LIKE wildcards in the prefix are literal.
+	got, err = s.Suggest(ctx, "100%", 10)
+	require.NoError(t, err)
+	require.Equal(t, []int64{pct.ID}, suggestionIDs(got))
+	got, err = s.Suggest(ctx, "10_", 10)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func suggestionIDs(items []notes.Suggestion) []int64 {
+	out := make([]int64, len(items))
+	for i, s := range items {
+		out[i] = s.ID
+	}
+	return out
+}
+
+func testRelated(t *testing.T, s notes.Store) {
+	src := create(t, s, "Postgres index tuning", "Vacuum and btree index bloat.", notes.LangEnglish)
+	same := create(t, s, "Index tuning for Postgres", "Check btree bloat after vacuum.", notes.LangEnglish)
+	word := create(t, s, "Weekly sync", "We talked about vacuum.", notes.LangEnglish)
+	create(t, s, "Groceries", "milk eggs bread", notes.LangEnglish)
+	lonely := create(t, s, "Zebra", "xylophone", notes.LangEnglish)
+
+	got, err := s.Related(ctx, src.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, same.ID, got[0].ID)
+	require.Equal(t, word.ID, got[1].ID)
+	for _, r := range got {
+		require.InDelta(t, 0.4*r.Scores.Text+0.2*r.Scores.Title, r.Score, 1e-9)
+		require.True(t, r.Score > 0 && r.Score <= 1)
+	}
+	require.Greater(t, got[0].Scores.Title, got[1].Scores.Title)
+
+	got, err = s.Related(ctx, src.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	got, err = s.Related(ctx, lonely.ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = s.Related(ctx, lonely.ID+1000, 10)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testRelatedReferences(t *testing.T, s notes.Store) {
+	src := create(t, s, "Alpha", "Quarterly plan #Roadmap, see https://example.com/spec.", notes.LangSimple)
+	tagged := create(t, s, "Bravo", "xylophone #roadmap #zebra", notes.LangSimple)
+	linked := create(t, s, "Charlie", "draft at https://example.com/spec", notes.LangSimple)
+	ref := create(t, s, "Delta", fmt.Sprintf("follow-up of /notes/%d", src.ID), notes.LangSimple)
+	create(t, s, "Echo", "#unrelated words", notes.LangSimple)
+
+	got, err := s.Related(ctx, src.ID, 10)
+	require.NoError(t, err)
+	byID := map[int64]notes.RelatedNote{}
+	for _, r := range got {
+		byID[r.ID] = r
+		require.InDelta(t, 0.4*r.Scores.Text+0.2*r.Scores.Title+0.2*r.Scores.Tags+0.2*r.Scores.Links, r.Score, 1e-9)
+	}
+	require.Len(t, byID, 3)
+	require.InDelta(t, 0.5, byID[tagged.ID].Scores.Tags, 1e-9) // {roadmap} of {roadmap, zebra}
+	require.InDelta(t, 1, byID[linked.ID].Scores.Links, 1e-9)
+	require.InDelta(t, 1, byID[ref.ID].Scores.Links, 1e-9)
+
+	// A reference counts for both notes.
+	got, err = s.Related(ctx, ref.ID, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, got)
+	require.Equal(t, src.ID, got[0].ID)
+	require.InDelta(t, 1, got[0].Scores.Links, 1e-9)
+
+	// Cyrillic tags are matched regardless of case.
+	ru := create(t, s, "Заметка", "#Базы_Данных #ЁЛКА", notes.LangRussian)
+	ruTagged := create(t, s, "Другое", "#базы_данных #ёлка #го", notes.LangRussian)
+	got, err = s.Related(ctx, ru.ID, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, got)
+	require.Equal(t, ruTagged.ID, got[0].ID)
+	require.InDelta(t, 2.0/3, got[0].Scores.Tags, 1e-9)
+}
+
+func testBatchGet(t *testing.T, s notes.Store) {
+	a := create(t, s, "a", "a", notes.LangSimple)
+	b := create(t, s, "b", "b", notes.LangSimple)
+	c := create(t, s, "c", "c", notes.LangSimple)
+
+	got, err := s.BatchGet(ctx, []int64{a.ID, c.ID, c.ID + 1000, b.ID})
+	require.NoError(t, err)
+	require.Equal(t, []int64{c.ID, b.ID, a.ID}, ids(got))
+
+	got, err = s.BatchGet(ctx, nil)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Empty(t, got)
+}
+
+func testConcurrent(t *testing.T, s notes.Store) {
+	const workers, each = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*each)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				n, err := s.Create(ctx, fmt.Sprintf("worker %d note %d", w, i), "concurrent", notes.LangEnglish)
+				if err == nil {
+					_, err = s.List(ctx, notes.ListParams{Limit: 5})
+				}
+				if err == nil && i%2 == 0 {
+					_, err = s.Update(ctx, n.ID, strings.ToUpper(n.Title), n.Content, n.Language)
+				}
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	items, err := s.List(ctx, notes.ListParams{Limit: 200})
+	require.NoError(t, err)
+	require.Len(t, items, workers*each)
+	seen := map[int64]bool{}
+	for _, n := range items {
+		require.False(t, seen[n.ID])
+		seen[n.ID] = true
+	}
+}
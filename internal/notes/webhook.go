@@ -0,0 +1,96 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Webhook URLs come from unauthenticated clients and the worker posts note
+// contents to them, so they must not reach internal services (SSRF): hosts
+// resolving to loopback, private, link-local (cloud metadata), shared
+// (carrier-grade NAT), multicast or unspecified addresses are refused when a
+// saved search is created, and again when the webhook client dials, which
+// defeats DNS rebinding.
+
+var errWebhookAddr = errors.New("webhook address is not public")
+
+// publicAddr reports whether a webhook may be sent to a.
+func publicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	return a.IsValid() && a.IsGlobalUnicast() && !a.IsPrivate() && !sharedPrefix.Contains(a)
+}
+
+// sharedPrefix is the IPv4 shared address space of carrier-grade NAT
+// (RFC 6598), routed inside provider networks only.
+var sharedPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// lookupHost resolves webhook hosts; tests replace it.
+type lookupHost func(ctx context.Context, host string) ([]netip.Addr, error)
+
+func defaultLookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// checkWebhookURL accepts http(s) URLs whose host resolves to public
+// addresses only.
+func checkWebhookURL(ctx context.Context, lookup lookupHost, s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook URL must be http(s) with a host")
+	}
+	host := u.Hostname()
+	addrs := []netip.Addr{}
+	if a, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, a)
+	} else {
+		if addrs, err = lookup(ctx, host); err != nil {
+			return fmt.Errorf("webhook host %s: %w", host, err)
+		}
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("webhook host %s: no addresses", host)
+	}
+	for _, a := range addrs {
+		if !publicAddr(a) {
+			return fmt.Errorf("webhook host %s: %w", host, errWebhookAddr)
+		}
+	}
+	return nil
+}
+
+// webhookDialControl refuses connections to non-public addresses. It runs
+// after name resolution, on the address actually dialled.
+func webhookDialControl(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ap.Addr()) {
+		return fmt.Errorf("dial %s: %w", address, errWebhookAddr)
+	}
+	return nil
+}
+
+// NewWebhookTransport returns the transport for webhook clients: it dials
+// public addresses only, whatever the URL or a redirect resolves to, and
+// ignores proxy settings, which would hide the target address.
+func NewWebhookTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   webhookDialControl,
+	}).DialContext
+	return t
+}
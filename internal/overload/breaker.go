@@ -0,0 +1,151 @@
+package overload
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through and counts consecutive failures.
+	Closed State = iota
+	// HalfOpen lets a few probe calls through to test the database.
+	HalfOpen
+	// Open rejects every call until OpenTimeout has passed.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// BreakerOptions configures a Breaker.
+type BreakerOptions struct {
+	// FailureThreshold consecutive failures open the breaker; 0 disables it.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before probing.
+	OpenTimeout time.Duration
+	// HalfOpenRequests probes run at a time while half-open; that many
+	// successes in a row close the breaker, one failure opens it again.
+	HalfOpenRequests int
+}
+
+// Breaker is a circuit breaker: after FailureThreshold failures in a row it
+// fails calls fast for OpenTimeout instead of letting them queue for a
+// database that does not answer, then lets HalfOpenRequests probes through.
+type Breaker struct {
+	opts BreakerOptions
+	now  func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	gen       uint64 // incremented on every state change
+	failures  int    // consecutive, while closed
+	successes int    // consecutive, while half-open
+	probes    int    // in flight, while half-open
+	openedAt  time.Time
+}
+
+func NewBreaker(opts BreakerOptions) *Breaker {
+	if opts.HalfOpenRequests <= 0 {
+		opts.HalfOpenRequests = 1
+	}
+	return &Breaker{opts: opts, now: time.Now}
+}
+
+// Allow admits a call and returns the function that reports its outcome, or
+// a *ShedError if the breaker rejects it.
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	if b.opts.FailureThreshold <= 0 {
+		return func(bool) {}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	switch b.state {
+	case Open:
+		return nil, &ShedError{Reason: ReasonBreaker, Wait: b.openedAt.Add(b.opts.OpenTimeout).Sub(b.now())}
+	case HalfOpen:
+		if b.probes >= b.opts.HalfOpenRequests {
+			return nil, &ShedError{Reason: ReasonBreaker, Wait: busyRetryAfter}
+		}
+		b.probes++
+	}
+	gen := b.gen
+	return func(failed bool) { b.done(gen, failed) }, nil
+}
+
+func (b *Breaker) done(gen uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		// Admitted in an earlier state; its outcome says nothing of this one.
+		return
+	}
+	switch b.state {
+	case Closed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.opts.FailureThreshold {
+			b.set(Open)
+		}
+	case HalfOpen:
+		b.probes--
+		if failed {
+			b.set(Open)
+			return
+		}
+		b.successes++
+		if b.successes >= b.opts.HalfOpenRequests {
+			b.set(Closed)
+		}
+	}
+}
+
+// advance moves an open breaker to half-open once OpenTimeout has passed.
+func (b *Breaker) advance() {
+	if b.state == Open && !b.now().Before(b.openedAt.Add(b.opts.OpenTimeout)) {
+		b.set(HalfOpen)
+	}
+}
+
+func (b *Breaker) set(s State) {
+	b.state = s
+	b.gen++
+	b.failures, b.successes, b.probes = 0, 0, 0
+	if s == Open {
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Check fails while the breaker is open, so readiness takes the instance out
+// of the load balancer until it probes the database again.
+func (b *Breaker) Check(context.Context) error {
+	if b.State() == Open {
+		return errors.New("circuit breaker open")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package overload
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// LimiterOptions configures a Limiter.
+type LimiterOptions struct {
+	// The concurrency limit starts at Initial and stays in [Min, Max].
+	Initial int
+	Min     int
+	Max     int
+	// Calls slower than LatencyTarget, or failing, shrink the limit.
+	LatencyTarget time.Duration
+	// Backoff multiplies the limit on every slow or failed call (e.g. 0.9).
+	Backoff float64
+}
+
+// busyRetryAfter is the Retry-After of calls shed while others are in
+// flight: room frees up as soon as they finish.
+const busyRetryAfter = time.Second
+
+// Limiter is an adaptive concurrency limit (additive increase, multiplicative
+// decrease). Calls beyond the limit are rejected at once instead of queueing
+// for a pool connection. The limit grows by about one per limit's worth of
+// fast calls while it is in use and shrinks by Backoff when a call is slow or
+// fails, so it settles near the concurrency the database serves within
+// LatencyTarget.
+type Limiter struct {
+	opts LimiterOptions
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+}
+
+func NewLimiter(opts LimiterOptions) *Limiter {
+	opts.Min = max(opts.Min, 1)
+	opts.Max = max(opts.Max, opts.Min)
+	opts.Initial = min(max(opts.Initial, opts.Min), opts.Max)
+	if opts.Backoff <= 0 || opts.Backoff >= 1 {
+		opts.Backoff = 0.9
+	}
+	return &Limiter{opts: opts, limit: float64(opts.Initial)}
+}
+
+// Acquire admits a call and returns the function that reports its latency
+// and outcome, or a *ShedError if the limit is reached.
+func (l *Limiter) Acquire() (done func(latency time.Duration, failed bool), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= int(l.limit) {
+		return nil, &ShedError{Reason: ReasonLimit, Wait: busyRetryAfter}
+	}
+	l.inflight++
+	return l.release, nil
+}
+
+func (l *Limiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inflight := l.inflight
+	l.inflight--
+	switch {
+	case failed || (l.opts.LatencyTarget > 0 && latency > l.opts.LatencyTarget):
+		l.limit = math.Max(float64(l.opts.Min), l.limit*l.opts.Backoff)
+	case 2*inflight >= int(l.limit):
+		// Grow only when the limit is in use; an idle service would otherwise
+		// raise it to Max and lose its protection.
+		l.limit = math.Min(float64(l.opts.Max), l.limit+1/l.limit)
+	}
+}
+
+// cancel releases a call that did not reach the database, without adapting
+// the limit.
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+}
+
+// Limit returns the current concurrency limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of admitted calls that have not finished.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
@@ -0,0 +1,194 @@
+// Package overload protects the database from requests it cannot serve in
+// time. Guard wraps a notes.Store with an adaptive concurrency Limiter, which
+// rejects calls beyond what the database keeps up with instead of letting
+// them queue for a pool connection, and a circuit Breaker, which fails calls
+// fast while the database keeps failing. Rejected calls return a *ShedError;
+// the handlers answer them with 503 and Retry-After.
+package overload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// Reasons a call is shed.
+const (
+	ReasonBreaker = "breaker"
+	ReasonLimit   = "limit"
+)
+
+// ShedError is returned for calls rejected without reaching the database.
+type ShedError struct {
+	Reason string
+	// Wait is how long the client should wait before retrying.
+	Wait time.Duration
+}
+
+func (e *ShedError) Error() string {
+	switch e.Reason {
+	case ReasonBreaker:
+		return "overload: circuit breaker open"
+	case ReasonLimit:
+		return "overload: concurrency limit reached"
+	}
+	return fmt.Sprintf("overload: %s", e.Reason)
+}
+
+// RetryAfter is the Wait rounded up to whole seconds, at least one.
+func (e *ShedError) RetryAfter() time.Duration {
+	return max(time.Second, e.Wait.Round(time.Second))
+}
+
+// Failure reports whether err says the database is unavailable or overloaded:
+// a timeout (waiting for a connection counts), a transient error (see
+// notes.Transient), or a Postgres error of class 53 (insufficient resources,
+// e.g. too many connections) or statement_timeout. Missing rows, cancelled
+// requests and errors of the call itself are not failures.
+func Failure(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) || notes.Transient(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "53") || pgErr.Code == "57014")
+}
+
+// Guard is a notes.Store that sheds calls the next store would not serve in
+// time.
+type Guard struct {
+	next    notes.Store
+	breaker *Breaker
+	limiter *Limiter
+	onShed  func(ctx context.Context, err *ShedError)
+}
+
+var _ notes.Store = (*Guard)(nil)
+
+// NewGuard wraps next; a nil breaker or limiter is not applied.
+func NewGuard(next notes.Store, b *Breaker, l *Limiter) *Guard {
+	return &Guard{next: next, breaker: b, limiter: l}
+}
+
+// OnShed registers a callback for every rejected call, e.g. to count them.
+func (g *Guard) OnShed(fn func(ctx context.Context, err *ShedError)) *Guard {
+	g.onShed = fn
+	return g
+}
+
+func (g *Guard) Breaker() *Breaker { return g.breaker }
+func (g *Guard) Limiter() *Limiter { return g.limiter }
+
+// call runs fn if the limiter and the breaker admit it and reports its
+// outcome to both. A panic in fn counts as a failure and is re-raised, after
+// the call has given back its place.
+func (g *Guard) call(ctx context.Context, fn func() error) (err error) {
+	limitDone := func(time.Duration, bool) {}
+	if g.limiter != nil {
+		done, err := g.limiter.Acquire()
+		if err != nil {
+			return g.shed(ctx, err)
+		}
+		limitDone = done
+	}
+	breakerDone := func(bool) {}
+	if g.breaker != nil {
+		done, err := g.breaker.Allow()
+		if err != nil {
+			if g.limiter != nil {
+				g.limiter.cancel()
+			}
+			return g.shed(ctx, err)
+		}
+		breakerDone = done
+	}
+
+	start := time.Now()
+	defer func() {
+		p := recover()
+		failed := p != nil || Failure(err)
+		limitDone(time.Since(start), failed)
+		breakerDone(failed)
+		if p != nil {
+			panic(p)
+		}
+	}()
+	return fn()
+}
+
+func (g *Guard) shed(ctx context.Context, err error) error {
+	var se *ShedError
+	if g.onShed != nil && errors.As(err, &se) {
+		g.onShed(ctx, se)
+	}
+	return err
+}
+
+func (g *Guard) Create(ctx context.Context, title, content, language string) (n notes.Note, err error) {
+	err = g.call(ctx, func() error {
+		n, err = g.next.Create(ctx, title, content, language)
+		return err
+	})
+	return n, err
+}
+
+func (g *Guard) Get(ctx context.Context, id int64) (n notes.Note, err error) {
+	err = g.call(ctx, func() error {
+		n, err = g.next.Get(ctx, id)
+		return err
+	})
+	return n, err
+}
+
+func (g *Guard) Update(ctx context.Context, id int64, title, content, language string) (n notes.Note, err error) {
+	err = g.call(ctx, func() error {
+		n, err = g.next.Update(ctx, id, title, content, language)
+		return err
+	})
+	return n, err
+}
+
+func (g *Guard) Delete(ctx context.Context, id int64) error {
+	return g.call(ctx, func() error { return g.next.Delete(ctx, id) })
+}
+
+func (g *Guard) List(ctx context.Context, p notes.ListParams) (out []notes.Note, err error) {
+	err = g.call(ctx, func() error {
+		out, err = g.next.List(ctx, p)
+		return err
+	})
+	return out, err
+}
+
+func (g *Guard) BatchGet(ctx context.Context, ids []int64) (out []notes.Note, err error) {
+	err = g.call(ctx, func() error {
+		out, err = g.next.BatchGet(ctx, ids)
+		return err
+	})
+	return out, err
+}
+
+func (g *Guard) Suggest(ctx context.Context, prefix string, limit int) (out []notes.Suggestion, err error) {
+	err = g.call(ctx, func() error {
+		out, err = g.next.Suggest(ctx, prefix, limit)
+		return err
+	})
+	return out, err
+}
+
+func (g *Guard) Related(ctx context.Context, id int64, limit int) (out []notes.RelatedNote, err error) {
+	err = g.call(ctx, func() error {
+		out, err = g.next.Related(ctx, id, limit)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,220 @@
+package overload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/notes/storetest"
+)
+
+func TestGuard_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) notes.Store {
+		return NewGuard(notes.NewMemoryStore(),
+			NewBreaker(BreakerOptions{FailureThreshold: 5, OpenTimeout: time.Second}),
+			NewLimiter(LimiterOptions{Initial: 64, Max: 64}))
+	})
+}
+
+func TestFailure(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{sql.ErrNoRows, false},
+		{context.Canceled, false},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{errors.New("invalid input"), false},
+		{fmt.Errorf("acquire: %w", context.DeadlineExceeded), true},
+		{&pgconn.PgError{Code: "53300"}, true}, // too_many_connections
+		{&pgconn.PgError{Code: "57014"}, true}, // statement timeout
+		{&pgconn.PgError{Code: "57P01"}, true},
+	} {
+		require.Equal(t, tt.want, Failure(tt.err), "%v", tt.err)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := NewBreaker(BreakerOptions{FailureThreshold: 3, OpenTimeout: 10 * time.Second, HalfOpenRequests: 2})
+	b.now = func() time.Time { return now }
+
+	call := func(failed bool) error {
+		done, err := b.Allow()
+		if err != nil {
+			return err
+		}
+		done(failed)
+		return nil
+	}
+
+	// A success resets the count of consecutive failures.
+	require.NoError(t, call(true))
+	require.NoError(t, call(true))
+	require.NoError(t, call(false))
+	require.NoError(t, call(true))
+	require.NoError(t, call(true))
+	require.Equal(t, Closed, b.State())
+	require.NoError(t, b.Check(context.Background()))
+
+	require.NoError(t, call(true))
+	require.Equal(t, Open, b.State())
+	require.Error(t, b.Check(context.Background()))
+
+	now = now.Add(4 * time.Second)
+	err := call(false)
+	var se *ShedError
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, ReasonBreaker, se.Reason)
+	require.Equal(t, 6*time.Second, se.RetryAfter())
+
+	// Half-open: a failed probe opens the breaker again.
+	now = now.Add(6 * time.Second)
+	require.Equal(t, HalfOpen, b.State())
+	require.NoError(t, b.Check(context.Background()))
+	require.NoError(t, call(true))
+	require.Equal(t, Open, b.State())
+
+	// Only HalfOpenRequests probes run at a time; as many successes close it.
+	now = now.Add(10 * time.Second)
+	done1, err := b.Allow()
+	require.NoError(t, err)
+	done2, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	require.ErrorAs(t, err, &se)
+	done1(false)
+	require.Equal(t, HalfOpen, b.State())
+	done2(false)
+	require.Equal(t, Closed, b.State())
+
+	// Outcomes of calls admitted before a state change are ignored.
+	stale, err := b.Allow()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call(true))
+	}
+	require.Equal(t, Open, b.State())
+	now = now.Add(10 * time.Second)
+	stale(true)
+	require.Equal(t, HalfOpen, b.State())
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := NewBreaker(BreakerOptions{})
+	for i := 0; i < 100; i++ {
+		done, err := b.Allow()
+		require.NoError(t, err)
+		done(true)
+	}
+	require.Equal(t, Closed, b.State())
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(LimiterOptions{Initial: 4, Min: 2, Max: 5, LatencyTarget: 100 * time.Millisecond, Backoff: 0.5})
+	require.Equal(t, 4, l.Limit())
+
+	var dones []func(time.Duration, bool)
+	for i := 0; i < 4; i++ {
+		done, err := l.Acquire()
+		require.NoError(t, err)
+		dones = append(dones, done)
+	}
+	require.Equal(t, 4, l.InFlight())
+	_, err := l.Acquire()
+	var se *ShedError
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, ReasonLimit, se.Reason)
+	require.Equal(t, time.Second, se.RetryAfter())
+
+	// Fast calls under load grow the limit by about one per limit of calls.
+	for _, done := range dones {
+		done(time.Millisecond, false)
+	}
+	require.Equal(t, 0, l.InFlight())
+	require.Equal(t, 4, l.Limit()) // 4.49: grew while 3 or more were in flight
+
+	// A slow call halves it, never below Min.
+	done, err := l.Acquire()
+	require.NoError(t, err)
+	done(time.Second, false)
+	require.Equal(t, 2, l.Limit())
+	done, err = l.Acquire()
+	require.NoError(t, err)
+	done(0, true)
+	require.Equal(t, 2, l.Limit())
+
+	// One call at a time grows the limit only while it is in use: to 3.
+	for i := 0; i < 100; i++ {
+		done, err := l.Acquire()
+		require.NoError(t, err)
+		done(time.Millisecond, false)
+	}
+	require.Equal(t, 3, l.Limit())
+}
+
+func TestGuard_Sheds(t *testing.T) {
+	next := &failingStore{Store: notes.NewMemoryStore(), err: fmt.Errorf("acquire: %w", context.DeadlineExceeded)}
+	b := NewBreaker(BreakerOptions{FailureThreshold: 2, OpenTimeout: time.Minute})
+	var shed []string
+	g := NewGuard(next, b, nil).OnShed(func(_ context.Context, err *ShedError) {
+		shed = append(shed, err.Reason)
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := g.Get(ctx, 1)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	_, err := g.Get(ctx, 1)
+	var se *ShedError
+	require.ErrorAs(t, err, &se)
+	require.Equal(t, 2, next.calls, "no call reaches the store while open")
+	require.Equal(t, []string{ReasonBreaker}, shed)
+
+	// Errors of the call itself do not open the breaker.
+	fail := errors.New("invalid input syntax")
+	next.err = fail
+	b = NewBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute})
+	g = NewGuard(next, b, NewLimiter(LimiterOptions{Initial: 1}))
+	for i := 0; i < 3; i++ {
+		_, err := g.Get(ctx, 1)
+		require.ErrorIs(t, err, fail)
+	}
+	require.Equal(t, Closed, b.State())
+	require.Equal(t, 0, g.Limiter().InFlight())
+}
+
+func TestGuard_Panic(t *testing.T) {
+	next := &failingStore{Store: notes.NewMemoryStore(), panics: true}
+	b := NewBreaker(BreakerOptions{FailureThreshold: 1, OpenTimeout: time.Minute})
+	g := NewGuard(next, b, NewLimiter(LimiterOptions{Initial: 1}))
+
+	require.PanicsWithValue(t, "boom", func() { _, _ = g.Get(context.Background(), 1) })
+	require.Equal(t, 0, g.Limiter().InFlight())
+	require.Equal(t, Open, b.State(), "a panic is a failure")
+}
+
+// failingStore fails every Get with err, or panics.
+type failingStore struct {
+	notes.Store
+	err    error
+	panics bool
+	calls  int
+}
+
+func (s *failingStore) Get(context.Context, int64) (notes.Note, error) {
+	s.calls++
+	if s.panics {
+		panic("boom")
+	}
+	return notes.Note{}, s.err
+}
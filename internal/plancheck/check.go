@@ -0,0 +1,93 @@
+package plancheck
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Check is an assertion on a plan.
+type Check struct {
+	Name string
+	fn   func(Node) error
+}
+
+// Run applies the check to the root of a plan.
+func (c Check) Run(root Node) error { return c.fn(root) }
+
+// UsesIndex requires the plan to read at least one of the named indexes.
+func UsesIndex(names ...string) Check {
+	return Check{
+		Name: "uses " + strings.Join(names, " or "),
+		fn: func(root Node) error {
+			used := root.Indexes()
+			for _, name := range names {
+				if slices.Contains(used, name) {
+					return nil
+				}
+			}
+			return fmt.Errorf("none of %s used", strings.Join(names, ", "))
+		},
+	}
+}
+
+// NoSeqScan forbids sequential scans of relation.
+func NoSeqScan(relation string) Check {
+	return Check{
+		Name: "no seq scan on " + relation,
+		fn: func(root Node) error {
+			var found bool
+			root.Walk(func(n Node) {
+				found = found || n.NodeType == "Seq Scan" && n.RelationName == relation
+			})
+			if found {
+				return fmt.Errorf("Seq Scan on %s", relation)
+			}
+			return nil
+		},
+	}
+}
+
+// MaxRows bounds the estimated rows of the root node.
+func MaxRows(max float64) Check {
+	return Check{
+		Name: fmt.Sprintf("rows <= %g", max),
+		fn: func(root Node) error {
+			if root.PlanRows > max {
+				return fmt.Errorf("estimated %.0f rows, want <= %g", root.PlanRows, max)
+			}
+			return nil
+		},
+	}
+}
+
+// Expectations are the checks of each notes.QueryShape by name. Shapes
+// without an entry are explained and reported but not checked.
+var Expectations = map[string][]Check{
+	"notes.get":       {UsesIndex("notes_pkey"), NoSeqScan("notes"), MaxRows(1)},
+	"notes.exists":    {UsesIndex("notes_pkey"), NoSeqScan("notes")},
+	"notes.batch_get": {UsesIndex("notes_pkey"), NoSeqScan("notes"), MaxRows(10)},
+	"notes.update":    {UsesIndex("notes_pkey"), NoSeqScan("notes"), MaxRows(1)},
+	"notes.delete":    {UsesIndex("notes_pkey"), NoSeqScan("notes")},
+
+	"notes.list_first": {UsesIndex("idx_notes_created_id"), NoSeqScan("notes"), MaxRows(20)},
+	"notes.list_after": {UsesIndex("idx_notes_created_id"), NoSeqScan("notes"), MaxRows(20)},
+
+	// Rare words are found through the GIN index, frequent ones by walking
+	// idx_notes_created_id until the page is full; both are fine.
+	"notes.search":        {UsesIndex("idx_notes_search_vector", "idx_notes_created_id"), NoSeqScan("notes"), MaxRows(20)},
+	"notes.search#cursor": {UsesIndex("idx_notes_search_vector", "idx_notes_created_id"), NoSeqScan("notes"), MaxRows(20)},
+
+	"notes.fuzzy":   {UsesIndex("idx_notes_title_trgm"), NoSeqScan("notes"), MaxRows(20)},
+	"notes.suggest": {UsesIndex("idx_notes_title_lower_gist"), NoSeqScan("notes"), MaxRows(10)},
+	"notes.related": {
+		UsesIndex("notes_pkey"),
+		UsesIndex("idx_notes_search_vector"),
+		UsesIndex("idx_notes_title_trgm"),
+		NoSeqScan("notes"),
+		MaxRows(10),
+	},
+
+	"saved_searches.inbox":   {NoSeqScan("notes")},
+	"saved_searches.collect": {UsesIndex("idx_notes_updated_id"), NoSeqScan("notes")},
+}
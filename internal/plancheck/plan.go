@@ -0,0 +1,82 @@
+// Package plancheck EXPLAINs every repository query shape against a seeded
+// database and checks the plans: expected indexes are used, notes is never
+// scanned sequentially and row estimates stay bounded. It catches index
+// regressions before deploy; see notesctl plans.
+package plancheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Node is a node of an EXPLAIN (FORMAT JSON) plan.
+type Node struct {
+	NodeType     string  `json:"Node Type"`
+	RelationName string  `json:"Relation Name,omitempty"`
+	IndexName    string  `json:"Index Name,omitempty"`
+	PlanRows     float64 `json:"Plan Rows"`
+	TotalCost    float64 `json:"Total Cost"`
+	Plans        []Node  `json:"Plans,omitempty"`
+}
+
+// ParsePlan decodes the output of EXPLAIN (FORMAT JSON) and returns the root.
+func ParsePlan(b []byte) (Node, error) {
+	var out []struct {
+		Plan *Node `json:"Plan"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return Node{}, fmt.Errorf("plancheck: decode plan: %w", err)
+	}
+	if len(out) != 1 || out[0].Plan == nil {
+		return Node{}, errors.New("plancheck: plan not found")
+	}
+	return *out[0].Plan, nil
+}
+
+// Walk calls fn for n and its descendants, depth first.
+func (n Node) Walk(fn func(Node)) {
+	fn(n)
+	for _, c := range n.Plans {
+		c.Walk(fn)
+	}
+}
+
+// Indexes returns the sorted names of indexes the plan reads.
+func (n Node) Indexes() []string {
+	seen := map[string]bool{}
+	n.Walk(func(c Node) {
+		if c.IndexName != "" {
+			seen[c.IndexName] = true
+		}
+	})
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Tree renders the plan one node per line, indented by depth.
+func (n Node) Tree() string {
+	var b strings.Builder
+	n.tree(&b, 0)
+	return b.String()
+}
+
+func (n Node) tree(b *strings.Builder, depth int) {
+	fmt.Fprintf(b, "%s%s", strings.Repeat("  ", depth), n.NodeType)
+	if n.IndexName != "" {
+		fmt.Fprintf(b, " using %s", n.IndexName)
+	}
+	if n.RelationName != "" {
+		fmt.Fprintf(b, " on %s", n.RelationName)
+	}
+	fmt.Fprintf(b, " (rows=%.0f cost=%.2f)\n", n.PlanRows, n.TotalCost)
+	for _, c := range n.Plans {
+		c.tree(b, depth+1)
+	}
+}
@@ -0,0 +1,130 @@
+package plancheck
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// A trimmed plan of notes.related on a seeded table.
+const relatedPlan = `[{"Plan": {
+  "Node Type": "Limit", "Plan Rows": 10, "Total Cost": 1520.5,
+  "Plans": [{
+    "Node Type": "Sort", "Plan Rows": 310, "Total Cost": 1520.4,
+    "Plans": [{
+      "Node Type": "Nested Loop", "Plan Rows": 310, "Total Cost": 1510.1,
+      "Plans": [
+        {"Node Type": "Index Scan", "Relation Name": "notes", "Index Name": "notes_pkey", "Plan Rows": 1, "Total Cost": 8.4},
+        {"Node Type": "Bitmap Heap Scan", "Relation Name": "notes", "Plan Rows": 310, "Total Cost": 1490.2,
+         "Plans": [{"Node Type": "BitmapOr", "Plan Rows": 0, "Total Cost": 60.1,
+           "Plans": [
+             {"Node Type": "Bitmap Index Scan", "Index Name": "idx_notes_search_vector", "Plan Rows": 300, "Total Cost": 40.0},
+             {"Node Type": "Bitmap Index Scan", "Index Name": "idx_notes_title_trgm", "Plan Rows": 10, "Total Cost": 20.0}
+           ]}]}
+      ]}]}]}}]`
+
+const seqScanPlan = `[{"Plan": {
+  "Node Type": "Limit", "Plan Rows": 20, "Total Cost": 9000,
+  "Plans": [{"Node Type": "Sort", "Plan Rows": 50000, "Total Cost": 8990,
+    "Plans": [{"Node Type": "Seq Scan", "Relation Name": "notes", "Plan Rows": 50000, "Total Cost": 4000}]}]}}]`
+
+func TestParsePlan(t *testing.T) {
+	root, err := ParsePlan([]byte(relatedPlan))
+	require.NoError(t, err)
+	require.Equal(t, "Limit", root.NodeType)
+	require.Equal(t, 10.0, root.PlanRows)
+	require.Equal(t, []string{"idx_notes_search_vector", "idx_notes_title_trgm", "notes_pkey"}, root.Indexes())
+	require.Contains(t, root.Tree(), "\n      Index Scan using notes_pkey on notes (rows=1 cost=8.40)\n")
+	require.Contains(t, root.Tree(), "\n          Bitmap Index Scan using idx_notes_title_trgm (rows=10 cost=20.00)\n")
+
+	for _, bad := range []string{`{}`, `[]`, `[{"Planning": {}}]`, `not json`} {
+		_, err := ParsePlan([]byte(bad))
+		require.Error(t, err, bad)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	related, err := ParsePlan([]byte(relatedPlan))
+	require.NoError(t, err)
+	seq, err := ParsePlan([]byte(seqScanPlan))
+	require.NoError(t, err)
+
+	for _, c := range Expectations["notes.related"] {
+		require.NoError(t, c.Run(related), c.Name)
+	}
+
+	require.NoError(t, NoSeqScan("saved_searches").Run(seq))
+	require.EqualError(t, NoSeqScan("notes").Run(seq), "Seq Scan on notes")
+	require.EqualError(t, UsesIndex("idx_notes_created_id").Run(seq), "none of idx_notes_created_id used")
+	require.NoError(t, UsesIndex("idx_notes_created_id", "notes_pkey").Run(related))
+	require.NoError(t, MaxRows(20).Run(seq))
+	require.EqualError(t, MaxRows(5).Run(seq), "estimated 20 rows, want <= 5")
+}
+
+func TestExpectationsCoverShapes(t *testing.T) {
+	names := map[string]bool{}
+	for _, s := range notes.QueryShapes(notes.ShapeSample{ID: 1, Title: "Index plan", Language: "english", Now: time.Now()}) {
+		require.False(t, names[s.Name], "duplicate shape %s", s.Name)
+		names[s.Name] = true
+		require.NotEmpty(t, Expectations[s.Name], "no expectations for %s", s.Name)
+	}
+	for name := range Expectations {
+		require.True(t, names[name], "expectation for unknown shape %s", name)
+	}
+}
+
+func TestReport_WriteText(t *testing.T) {
+	seq, err := ParsePlan([]byte(seqScanPlan))
+	require.NoError(t, err)
+	rep := Report{Rows: 50000, Failed: 2, Results: []Result{
+		{Name: "notes.get", Rows: 1, Cost: 8.44, Indexes: []string{"notes_pkey"}, Checks: 3},
+		{Name: "notes.fuzzy", Rows: 20, Cost: 9000, Plan: seq.Tree(), Checks: 3,
+			Failures: []string{"no seq scan on notes: Seq Scan on notes"}},
+		{Name: "notes.extra", Rows: 5, Cost: 1},
+		{Name: "notes.broken", Checks: 1, Error: "syntax error"},
+	}}
+
+	var b bytes.Buffer
+	require.NoError(t, rep.WriteText(&b))
+	out := b.String()
+	require.Regexp(t, `notes\.get\s+1\s+8\.44\s+notes_pkey\s+ok\n`, out)
+	require.Regexp(t, `notes\.fuzzy\s+20\s+9000\.00\s+-\s+FAIL\n`, out)
+	require.Regexp(t, `notes\.extra\s+5\s+1\.00\s+-\s+unchecked\n`, out)
+	require.Regexp(t, `notes\.broken\s+0\s+0\.00\s+-\s+ERROR\n`, out)
+	require.Contains(t, out, "\nnotes.fuzzy:\n  no seq scan on notes: Seq Scan on notes\n    Limit (rows=20 cost=9000.00)\n")
+	require.Contains(t, out, "\nnotes.broken:\n  error: syntax error\n")
+	require.Contains(t, out, "4 statement(s), 2 failed, notes has ~50000 rows\n")
+}
+
+// TestRepositoryPlans is the regression check itself. It needs a migrated
+// database seeded with at least DefaultMinRows notes, e.g.
+//
+//	notesctl seed -n 200000
+//	TEST_DATABASE_URL=postgres://... go test ./internal/plancheck -run RepositoryPlans -v
+func TestRepositoryPlans(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("pgx", url)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	rep, err := CheckRepository(ctx, db, DefaultMinRows)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, rep.WriteText(&b))
+	t.Log("\n" + b.String())
+	require.True(t, rep.OK(), "%d plan regression(s)", rep.Failed)
+}
@@ -0,0 +1,171 @@
+package plancheck
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// DefaultMinRows is the smallest notes table plans are meaningful for: on
+// small tables a sequential scan is the right plan.
+const DefaultMinRows = 10_000
+
+// Case is a query shape with the checks its plan must pass.
+type Case struct {
+	Shape  notes.QueryShape
+	Checks []Check
+}
+
+// Cases attaches Expectations to shapes.
+func Cases(shapes []notes.QueryShape) []Case {
+	out := make([]Case, len(shapes))
+	for i, s := range shapes {
+		out[i] = Case{Shape: s, Checks: Expectations[s.Name]}
+	}
+	return out
+}
+
+// Result is the outcome of one case.
+type Result struct {
+	Name     string   `json:"name"`
+	Rows     float64  `json:"rows"`
+	Cost     float64  `json:"cost"`
+	Indexes  []string `json:"indexes"`
+	Plan     string   `json:"plan"`
+	Checks   int      `json:"checks"`
+	Failures []string `json:"failures,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func (r Result) OK() bool { return r.Error == "" && len(r.Failures) == 0 }
+
+// Report is the outcome of Run.
+type Report struct {
+	Rows    int64    `json:"table_rows"`
+	Results []Result `json:"results"`
+	Failed  int      `json:"failed"`
+}
+
+func (r Report) OK() bool { return r.Failed == 0 }
+
+// Sample reads the values the shapes are bound to: a note from the middle of
+// the id range. It fails if notes has fewer than minRows rows (by the
+// planner's estimate, so the table must have been analyzed).
+func Sample(ctx context.Context, db *sql.DB, minRows int64) (notes.ShapeSample, int64, error) {
+	var rows int64
+	if err := db.QueryRowContext(ctx, qTableRows).Scan(&rows); err != nil {
+		return notes.ShapeSample{}, 0, err
+	}
+	if rows < minRows {
+		return notes.ShapeSample{}, rows, fmt.Errorf("plancheck: notes has about %d rows, need %d; seed and ANALYZE it first", max(rows, 0), minRows)
+	}
+	s := notes.ShapeSample{Now: time.Now()}
+	err := db.QueryRowContext(ctx, qSample).Scan(&s.ID, &s.CreatedAt, &s.Title, &s.Language)
+	return s, rows, err
+}
+
+const (
+	qTableRows = `SELECT reltuples::bigint FROM pg_class WHERE oid = 'notes'::regclass`
+	qSample    = `
+		SELECT id, created_at, title, language
+		FROM notes
+		WHERE id >= (SELECT (min(id) + max(id)) / 2 FROM notes)
+		ORDER BY id
+		LIMIT 1`
+)
+
+// Explain returns the estimated plan of query. Statements are not executed.
+func Explain(ctx context.Context, db *sql.DB, query string, args ...any) (Node, error) {
+	var b []byte
+	if err := db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&b); err != nil {
+		return Node{}, err
+	}
+	return ParsePlan(b)
+}
+
+// Run explains every case and applies its checks.
+func Run(ctx context.Context, db *sql.DB, cases []Case) Report {
+	var rep Report
+	for _, c := range cases {
+		res := Result{Name: c.Shape.Name, Checks: len(c.Checks)}
+		root, err := Explain(ctx, db, c.Shape.SQL, c.Shape.Args...)
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Rows, res.Cost = root.PlanRows, root.TotalCost
+			res.Indexes, res.Plan = root.Indexes(), root.Tree()
+			for _, check := range c.Checks {
+				if err := check.Run(root); err != nil {
+					res.Failures = append(res.Failures, check.Name+": "+err.Error())
+				}
+			}
+		}
+		if !res.OK() {
+			rep.Failed++
+		}
+		rep.Results = append(rep.Results, res)
+	}
+	return rep
+}
+
+// WriteText writes a summary table followed by the plans of failed cases.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATEMENT\tROWS\tCOST\tINDEXES\tRESULT")
+	for _, res := range r.Results {
+		indexes := strings.Join(res.Indexes, ",")
+		if indexes == "" {
+			indexes = "-"
+		}
+		status := "ok"
+		switch {
+		case res.Error != "":
+			status = "ERROR"
+		case len(res.Failures) > 0:
+			status = "FAIL"
+		case res.Checks == 0:
+			status = "unchecked"
+		}
+		fmt.Fprintf(tw, "%s\t%.0f\t%.2f\t%s\t%s\n", res.Name, res.Rows, res.Cost, indexes, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, res := range r.Results {
+		if res.OK() {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", res.Name)
+		if res.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", res.Error)
+			continue
+		}
+		for _, f := range res.Failures {
+			fmt.Fprintf(w, "  %s\n", f)
+		}
+		for _, line := range strings.Split(strings.TrimRight(res.Plan, "\n"), "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d statement(s), %d failed, notes has ~%d rows\n", len(r.Results), r.Failed, r.Rows)
+	return err
+}
+
+// CheckRepository samples db and checks the plans of every notes.QueryShapes
+// statement against Expectations.
+func CheckRepository(ctx context.Context, db *sql.DB, minRows int64) (Report, error) {
+	sample, rows, err := Sample(ctx, db, minRows)
+	if err != nil {
+		return Report{}, err
+	}
+	rep := Run(ctx, db, Cases(notes.QueryShapes(sample)))
+	rep.Rows = rows
+	return rep, nil
+}
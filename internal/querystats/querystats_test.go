@@ -0,0 +1,117 @@
+package querystats
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/logging"
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+func TestCollector_Percentiles(t *testing.T) {
+	c := NewCollector(0)
+	ctx := context.Background()
+	for i := 1; i <= 100; i++ {
+		c.AfterQuery(ctx, notes.QueryEvent{Name: "notes.get", Rows: 1, Duration: time.Duration(i) * time.Millisecond})
+	}
+	c.AfterQuery(ctx, notes.QueryEvent{Name: "notes.get", Err: sql.ErrNoRows})
+	c.AfterQuery(ctx, notes.QueryEvent{Name: "notes.delete", Err: errors.New("boom"), Duration: time.Millisecond})
+
+	got := c.Snapshot()
+	require.Len(t, got, 2)
+	get := got[0]
+	require.Equal(t, "notes.get", get.Name)
+	require.Equal(t, int64(101), get.Count)
+	require.Equal(t, int64(0), get.Errors)
+	require.Equal(t, int64(100), get.Rows)
+	require.Equal(t, 50.0, get.P50MS)
+	require.Equal(t, 95.0, get.P95MS)
+	require.Equal(t, 99.0, get.P99MS)
+	require.Equal(t, 100.0, get.MaxMS)
+	require.Equal(t, 5050.0, get.TotalMS)
+
+	require.Equal(t, "notes.delete", got[1].Name)
+	require.Equal(t, int64(1), got[1].Errors)
+}
+
+func TestCollector_ReservoirIsBounded(t *testing.T) {
+	c := NewCollector(10)
+	for i := 0; i < 1000; i++ {
+		c.AfterQuery(context.Background(), notes.QueryEvent{Name: "q", Duration: time.Millisecond})
+	}
+	require.Len(t, c.stmts["q"].samples, 10)
+	require.Equal(t, int64(1000), c.Snapshot()[0].Count)
+}
+
+func TestCollector_Handler(t *testing.T) {
+	c := NewCollector(0)
+	c.AfterQuery(context.Background(), notes.QueryEvent{Name: "notes.list_first", Duration: 2 * time.Millisecond})
+
+	rr := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/query-stats", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var rep Report
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rep))
+	require.False(t, rep.Since.IsZero())
+	require.Len(t, rep.Statements, 1)
+	require.Equal(t, 2.0, rep.Statements[0].P99MS)
+}
+
+func TestSlowLog_LogsAboveThresholdWithRedactedArgs(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logging.New(&buf, "info", "json")
+	require.NoError(t, err)
+	ctx := logging.WithLogger(context.Background(), l.With("request_id", "req-1"))
+
+	s := NewSlowLog(100 * time.Millisecond)
+	s.AfterQuery(ctx, notes.QueryEvent{Name: "notes.get", Duration: 99 * time.Millisecond, Args: []any{int64(1)}})
+	require.Empty(t, buf.String())
+
+	s.AfterQuery(ctx, notes.QueryEvent{
+		Name:     "notes.search",
+		Duration: 150 * time.Millisecond,
+		Args:     []any{"секретный запрос", 20, nil},
+	})
+	var line map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	require.Equal(t, "slow query", line["msg"])
+	require.Equal(t, "WARN", line["level"])
+	require.Equal(t, "req-1", line["request_id"])
+	require.Equal(t, "notes.search", line["statement"])
+	require.Equal(t, []any{"<redacted 16 chars>", "20", "NULL"}, line["args"])
+	require.NotContains(t, buf.String(), "секрет")
+}
+
+func TestSlowLog_ExplainRateLimit(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSlowLog(0).WithExplain(nil, time.Second, time.Minute)
+	s.now = func() time.Time { return now }
+
+	require.True(t, s.shouldExplain("notes.get"))
+	require.False(t, s.shouldExplain("notes.get"))
+	require.True(t, s.shouldExplain("notes.search"))
+	now = now.Add(time.Minute)
+	require.True(t, s.shouldExplain("notes.get"))
+}
+
+func TestRedactArgs(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	id := int64(7)
+	var nilTime *time.Time
+	got := RedactArgs([]any{int64(1), 0.6, true, at, &at, nilTime, &id, []int64{1, 2, 3}, "note", []byte("x")})
+	require.Equal(t, []string{
+		"1", "0.6", "true", "2026-01-02T03:04:05Z", "2026-01-02T03:04:05Z", "NULL", "7",
+		"[3 ids]", "<redacted 4 chars>", "<redacted []uint8>",
+	}, got)
+}
@@ -0,0 +1,150 @@
+package querystats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/notes-api-pz14/internal/logging"
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// SlowLog is a notes.QueryHook that logs statements slower than a threshold
+// with the logger from the statement's context. Arguments are redacted:
+// numbers, booleans and times are logged, text only by its length.
+type SlowLog struct {
+	threshold time.Duration
+
+	// Optional EXPLAIN of slow statements.
+	db             *sql.DB
+	explainTimeout time.Duration
+	explainEvery   time.Duration
+	now            func() time.Time
+
+	mu        sync.Mutex
+	explained map[string]time.Time
+}
+
+var _ notes.QueryHook = (*SlowLog)(nil)
+
+func NewSlowLog(threshold time.Duration) *SlowLog {
+	return &SlowLog{threshold: threshold, now: time.Now, explained: map[string]time.Time{}}
+}
+
+// WithExplain makes the slow log run EXPLAIN (without ANALYZE, so nothing is
+// executed) for slow statements on db and log the plan. A statement is
+// explained at most once per every, in the background, within timeout.
+func (l *SlowLog) WithExplain(db *sql.DB, timeout, every time.Duration) *SlowLog {
+	l.db = db
+	l.explainTimeout = timeout
+	l.explainEvery = every
+	return l
+}
+
+func (l *SlowLog) BeforeQuery(ctx context.Context, _ string) context.Context { return ctx }
+
+func (l *SlowLog) AfterQuery(ctx context.Context, ev notes.QueryEvent) {
+	if ev.Duration < l.threshold {
+		return
+	}
+	log := logging.FromContext(ctx)
+	attrs := []slog.Attr{
+		slog.String("statement", ev.Name),
+		slog.Float64("duration_ms", ms(ev.Duration)),
+		slog.Int64("rows", ev.Rows),
+		slog.Any("args", RedactArgs(ev.Args)),
+	}
+	if isError(ev.Err) {
+		attrs = append(attrs, slog.String("error", ev.Err.Error()))
+	}
+	log.LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+
+	if l.db != nil && ev.SQL != "" && l.shouldExplain(ev.Name) {
+		go l.explain(log, ev)
+	}
+}
+
+func (l *SlowLog) shouldExplain(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if last, ok := l.explained[name]; ok && now.Sub(last) < l.explainEvery {
+		return false
+	}
+	l.explained[name] = now
+	return true
+}
+
+// explain runs detached from the request context, which may already be done.
+func (l *SlowLog) explain(log *slog.Logger, ev notes.QueryEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.explainTimeout)
+	defer cancel()
+
+	rows, err := l.db.QueryContext(ctx, "EXPLAIN "+ev.SQL, ev.Args...)
+	if err != nil {
+		log.Warn("slow query explain", "statement", ev.Name, "error", err)
+		return
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			log.Warn("slow query explain", "statement", ev.Name, "error", err)
+			return
+		}
+		plan = append(plan, line)
+	}
+	if err := rows.Err(); err != nil {
+		log.Warn("slow query explain", "statement", ev.Name, "error", err)
+		return
+	}
+	log.Warn("slow query plan", "statement", ev.Name, "plan", strings.Join(plan, "\n"))
+}
+
+// RedactArgs renders statement arguments for logs without leaking note text.
+func RedactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		out[i] = redact(a)
+	}
+	return out
+}
+
+func redact(a any) string {
+	switch v := a.(type) {
+	case nil:
+		return "NULL"
+	case int, int32, int64, float64, bool:
+		return fmt.Sprint(v)
+	case *int64:
+		if v == nil {
+			return "NULL"
+		}
+		return fmt.Sprint(*v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return "NULL"
+		}
+		return v.Format(time.RFC3339Nano)
+	case []int64:
+		return fmt.Sprintf("[%d ids]", len(v))
+	case string:
+		return fmt.Sprintf("<redacted %d chars>", len([]rune(v)))
+	default:
+		return fmt.Sprintf("<redacted %T>", v)
+	}
+}
+
+// isError reports failures; not found is a regular outcome.
+func isError(err error) bool {
+	return err != nil && !errors.Is(err, sql.ErrNoRows)
+}
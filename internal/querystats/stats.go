@@ -0,0 +1,152 @@
+// Package querystats aggregates per-statement repository statistics and logs
+// slow statements.
+package querystats
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// DefaultSamples is the per-statement reservoir size used for percentiles.
+const DefaultSamples = 1024
+
+// StatementStats is the aggregate of one statement since start. Percentiles
+// are estimated from a uniform sample of all executions.
+type StatementStats struct {
+	Name    string  `json:"name"`
+	Count   int64   `json:"count"`
+	Errors  int64   `json:"errors"`
+	Rows    int64   `json:"rows"`
+	TotalMS float64 `json:"total_ms"`
+	MeanMS  float64 `json:"mean_ms"`
+	P50MS   float64 `json:"p50_ms"`
+	P95MS   float64 `json:"p95_ms"`
+	P99MS   float64 `json:"p99_ms"`
+	MaxMS   float64 `json:"max_ms"`
+}
+
+type statement struct {
+	count   int64
+	errors  int64
+	rows    int64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+}
+
+// Collector is a notes.QueryHook that aggregates every statement.
+type Collector struct {
+	size  int
+	since time.Time
+
+	mu    sync.Mutex
+	stmts map[string]*statement
+}
+
+var _ notes.QueryHook = (*Collector)(nil)
+
+// NewCollector keeps at most samples latencies per statement (reservoir
+// sampling); samples <= 0 means DefaultSamples.
+func NewCollector(samples int) *Collector {
+	if samples <= 0 {
+		samples = DefaultSamples
+	}
+	return &Collector{size: samples, since: time.Now(), stmts: map[string]*statement{}}
+}
+
+func (c *Collector) BeforeQuery(ctx context.Context, _ string) context.Context { return ctx }
+
+func (c *Collector) AfterQuery(_ context.Context, ev notes.QueryEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stmts[ev.Name]
+	if s == nil {
+		s = &statement{}
+		c.stmts[ev.Name] = s
+	}
+	s.count++
+	if isError(ev.Err) {
+		s.errors++
+	}
+	s.rows += ev.Rows
+	s.total += ev.Duration
+	s.max = max(s.max, ev.Duration)
+
+	if len(s.samples) < c.size {
+		s.samples = append(s.samples, ev.Duration)
+	} else if i := rand.Int64N(s.count); i < int64(c.size) {
+		s.samples[i] = ev.Duration
+	}
+}
+
+// Snapshot returns statistics of every statement, most total time first.
+func (c *Collector) Snapshot() []StatementStats {
+	c.mu.Lock()
+	out := make([]StatementStats, 0, len(c.stmts))
+	samples := make([][]time.Duration, 0, len(c.stmts))
+	for name, s := range c.stmts {
+		out = append(out, StatementStats{
+			Name:    name,
+			Count:   s.count,
+			Errors:  s.errors,
+			Rows:    s.rows,
+			TotalMS: ms(s.total),
+			MeanMS:  ms(s.total / time.Duration(s.count)),
+			MaxMS:   ms(s.max),
+		})
+		samples = append(samples, append([]time.Duration(nil), s.samples...))
+	}
+	c.mu.Unlock()
+
+	// Sorting happens outside the lock so queries are not blocked by it.
+	for i := range out {
+		sorted := samples[i]
+		sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+		out[i].P50MS = ms(percentile(sorted, 0.50))
+		out[i].P95MS = ms(percentile(sorted, 0.95))
+		out[i].P99MS = ms(percentile(sorted, 0.99))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TotalMS != out[j].TotalMS {
+			return out[i].TotalMS > out[j].TotalMS
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// percentile uses the nearest-rank method on sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Report is the admin endpoint response body.
+type Report struct {
+	Since      time.Time        `json:"since"`
+	Statements []StatementStats `json:"statements"`
+}
+
+// Handler serves the statistics as JSON.
+func (c *Collector) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Report{Since: c.since, Statements: c.Snapshot()})
+	}
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryBackend keeps buckets in process memory; limits are per instance.
+type MemoryBackend struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{now: time.Now, buckets: map[string]*bucket{}}
+}
+
+func (m *MemoryBackend) Take(_ context.Context, key string, l Limit) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := m.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(l.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.Rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(allowed, b.tokens, l), nil
+}
+
+func (m *MemoryBackend) Prune(_ context.Context, idle time.Duration) error {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, b := range m.buckets {
+		if now.Sub(b.updated) > idle {
+			delete(m.buckets, k)
+		}
+	}
+	return nil
+}
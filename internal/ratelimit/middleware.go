@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/notes-api-pz14/internal/httpx"
+	"example.com/notes-api-pz14/internal/logging"
+)
+
+// KeyFunc identifies the client of a request; "" means it cannot tell.
+type KeyFunc func(r *http.Request) string
+
+// Keys returns the first non-empty key of fns.
+func Keys(fns ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, fn := range fns {
+			if k := fn(r); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+// APIKey identifies clients by the X-API-Key header or a bearer token. Keys
+// are hashed so secrets never reach bucket storage.
+func APIKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = strings.TrimSpace(token)
+		}
+	}
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:12])
+}
+
+// User identifies clients by the user an authentication middleware stored in
+// the request context.
+func User(from func(ctx context.Context) string) KeyFunc {
+	return func(r *http.Request) string {
+		if u := from(r.Context()); u != "" {
+			return "user:" + u
+		}
+		return ""
+	}
+}
+
+// IP identifies clients by remote address. With trustProxy the first
+// X-Forwarded-For address is used; enable it only behind a proxy that sets it.
+func IP(trustProxy bool) KeyFunc {
+	return func(r *http.Request) string {
+		if trustProxy {
+			if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+				first, _, _ := strings.Cut(xff, ",")
+				if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+					return "ip:" + ip.String()
+				}
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
+
+// Limiter applies Rules per route and client.
+type Limiter struct {
+	backend Backend
+	rules   Rules
+	keys    []KeyFunc
+}
+
+// New charges a bucket for every non-empty key of a request, and limits it
+// once any of them is empty. Keys a client chooses freely, like APIKey, must
+// be paired with one it cannot, like IP: rotating the header would otherwise
+// get a fresh bucket every time.
+func New(backend Backend, rules Rules, keys ...KeyFunc) *Limiter {
+	return &Limiter{backend: backend, rules: rules, keys: keys}
+}
+
+// Middleware must be installed on the root chi router: the route is resolved
+// with the router's Match before the request is dispatched. Requests are let
+// through if the backend fails.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, limit, ok := l.rules.lookup(r.Method, httpx.MatchRoute(r))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, charged, err := l.take(r, rule, limit)
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("rate limit backend", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !charged {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.Window())))
+		if !res.Allowed {
+			retry := ceilSeconds(res.RetryAfter)
+			h.Set("Retry-After", strconv.Itoa(retry))
+			httpx.WriteProblem(w, r, http.StatusTooManyRequests,
+				fmt.Sprintf("rate limit exceeded for %s, retry in %ds", rule, retry))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take charges the bucket of every key and reports the tightest result.
+func (l *Limiter) take(r *http.Request, rule string, limit Limit) (res Result, charged bool, err error) {
+	res = Result{Allowed: true, Remaining: limit.Burst}
+	for _, key := range l.keys {
+		k := key(r)
+		if k == "" {
+			continue
+		}
+		kr, err := l.backend.Take(r.Context(), rule+"|"+k, limit)
+		if err != nil {
+			return Result{}, false, err
+		}
+		charged = true
+		res.Allowed = res.Allowed && kr.Allowed
+		res.Remaining = min(res.Remaining, kr.Remaining)
+		res.Reset = max(res.Reset, kr.Reset)
+		res.RetryAfter = max(res.RetryAfter, kr.RetryAfter)
+	}
+	return res, charged, nil
+}
+
+// Run prunes idle buckets until ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context) {
+	// A bucket idle for the longest window is full and can be dropped.
+	var idle time.Duration
+	for _, lim := range l.rules {
+		idle = max(idle, lim.Window())
+	}
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := l.backend.Prune(ctx, idle); err != nil && ctx.Err() == nil {
+				logging.FromContext(ctx).Warn("rate limit prune", "error", err)
+			}
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
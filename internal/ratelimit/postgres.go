@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The refilled token count is computed from the database clock, so every
+// instance sees the same bucket state.
+const (
+	qTake = `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $3::float8 - 1, true, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN least($3::float8, b.tokens + $2::float8 * extract(epoch FROM now() - b.updated_at)::float8) >= 1
+				THEN least($3::float8, b.tokens + $2::float8 * extract(epoch FROM now() - b.updated_at)::float8) - 1
+				ELSE least($3::float8, b.tokens + $2::float8 * extract(epoch FROM now() - b.updated_at)::float8)
+			END,
+			allowed = least($3::float8, b.tokens + $2::float8 * extract(epoch FROM now() - b.updated_at)::float8) >= 1,
+			updated_at = now()
+		RETURNING tokens, allowed`
+
+	qPrune = `DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => $1)`
+)
+
+// PostgresBackend keeps buckets in the rate_limit_buckets table so that all
+// API instances share limits. Each Take is a single upsert.
+type PostgresBackend struct {
+	db *sql.DB
+}
+
+func NewPostgresBackend(db *sql.DB) *PostgresBackend {
+	return &PostgresBackend{db: db}
+}
+
+func (p *PostgresBackend) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	var tokens float64
+	var allowed bool
+	if err := p.db.QueryRowContext(ctx, qTake, key, l.Rate, l.Burst).Scan(&tokens, &allowed); err != nil {
+		return Result{}, err
+	}
+	return result(allowed, tokens, l), nil
+}
+
+func (p *PostgresBackend) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := p.db.ExecContext(ctx, qPrune, idle.Seconds())
+	return err
+}
@@ -0,0 +1,120 @@
+// Package ratelimit implements per-client token bucket rate limiting with
+// per-route limits and pluggable bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst tokens at most, refilled at Rate per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window returns the time an empty bucket needs to fill up.
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// ParseLimit parses "<n>/<s|m|h>[:<burst>]", e.g. "60/m:10". The burst
+// defaults to n.
+func ParseLimit(s string) (Limit, error) {
+	spec, burstStr, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	nStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit: invalid limit %q", s)
+	}
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid limit %q", s)
+	}
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if per == 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid unit in %q", s)
+	}
+
+	l := Limit{Rate: float64(n) / per.Seconds(), Burst: n}
+	if hasBurst {
+		b, err := strconv.Atoi(burstStr)
+		if err != nil || b <= 0 {
+			return Limit{}, fmt.Errorf("ratelimit: invalid burst in %q", s)
+		}
+		l.Burst = b
+	}
+	return l, nil
+}
+
+// Rules maps "METHOD /chi/route/pattern" to its limit. The "*" rule applies
+// to every route without an own rule.
+type Rules map[string]Limit
+
+// ParseRules parses comma separated "<route>=<limit>" pairs, e.g.
+// "POST /notes=60/m:10,POST /notes/batch=20/m,*=600/m".
+func ParseRules(s string) (Rules, error) {
+	rules := Rules{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("ratelimit: invalid rule %q", part)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		rules[strings.Join(strings.Fields(route), " ")] = l
+	}
+	return rules, nil
+}
+
+func (r Rules) lookup(method, pattern string) (string, Limit, bool) {
+	name := method + " " + pattern
+	if l, ok := r[name]; ok {
+		return name, l, true
+	}
+	l, ok := r["*"]
+	return "*", l, ok
+}
+
+// Result is the state of a bucket after taking a token.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, set if not allowed.
+	RetryAfter time.Duration
+}
+
+// Backend stores buckets. Take must be atomic per key.
+type Backend interface {
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+	// Prune drops buckets untouched for idle; they would be full anyway.
+	Prune(ctx context.Context, idle time.Duration) error
+}
+
+// result derives the response state from the tokens left in a bucket.
+func result(allowed bool, tokens float64, l Limit) Result {
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(l.Burst) - tokens) / l.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / l.Rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
@@ -0,0 +1,247 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/cors"
+	"example.com/notes-api-pz14/internal/httpx"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("60/m")
+	require.NoError(t, err)
+	require.Equal(t, Limit{Rate: 1, Burst: 60}, l)
+	require.Equal(t, time.Minute, l.Window())
+
+	l, err = ParseLimit(" 10/s:3 ")
+	require.NoError(t, err)
+	require.Equal(t, Limit{Rate: 10, Burst: 3}, l)
+
+	for _, bad := range []string{"", "10", "x/m", "0/m", "10/d", "10/m:0", "10/m:x"} {
+		_, err := ParseLimit(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("POST  /notes=60/m:10, POST /notes/batch=20/m,*=600/m,")
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	require.Equal(t, 10, rules["POST /notes"].Burst)
+
+	name, l, ok := rules.lookup(http.MethodPost, "/notes/batch")
+	require.True(t, ok)
+	require.Equal(t, "POST /notes/batch", name)
+	require.Equal(t, 20, l.Burst)
+
+	name, _, ok = rules.lookup(http.MethodGet, "/notes")
+	require.True(t, ok)
+	require.Equal(t, "*", name)
+
+	_, err = ParseRules("POST /notes")
+	require.Error(t, err)
+}
+
+func TestMemoryBackend_TokenBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemoryBackend()
+	m.now = func() time.Time { return now }
+	l := Limit{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	res, _ := m.Take(ctx, "k", l)
+	require.True(t, res.Allowed)
+	require.Equal(t, 1, res.Remaining)
+	require.Equal(t, time.Second, res.Reset)
+
+	res, _ = m.Take(ctx, "k", l)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+
+	res, _ = m.Take(ctx, "k", l)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Second, res.RetryAfter)
+
+	// Other keys have their own bucket.
+	res, _ = m.Take(ctx, "other", l)
+	require.True(t, res.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = m.Take(ctx, "k", l)
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = m.Take(ctx, "k", l)
+	require.True(t, res.Allowed)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, m.Prune(ctx, time.Minute))
+	require.Empty(t, m.buckets)
+}
+
+func TestKeys(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+
+	require.Equal(t, "ip:10.0.0.1", IP(false)(req))
+	require.Equal(t, "ip:203.0.113.7", IP(true)(req))
+
+	key := Keys(APIKey, IP(false))
+	require.Equal(t, "ip:10.0.0.1", key(req))
+
+	req.Header.Set("Authorization", "Bearer secret")
+	bearer := key(req)
+	require.Regexp(t, `^key:[0-9a-f]{24}$`, bearer)
+	require.NotContains(t, bearer, "secret")
+
+	req.Header.Set("X-API-Key", "secret")
+	require.Equal(t, bearer, key(req))
+
+	type userKey struct{}
+	byUser := User(func(ctx context.Context) string { u, _ := ctx.Value(userKey{}).(string); return u })
+	require.Empty(t, byUser(req))
+	req = req.WithContext(context.WithValue(req.Context(), userKey{}, "42"))
+	require.Equal(t, "user:42", byUser(req))
+}
+
+type failingBackend struct{}
+
+func (failingBackend) Take(context.Context, string, Limit) (Result, error) {
+	return Result{}, errors.New("db down")
+}
+
+func (failingBackend) Prune(context.Context, time.Duration) error { return nil }
+
+func newRouter(l *Limiter) http.Handler {
+	sub := chi.NewRouter()
+	sub.Post("/notes", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) })
+	sub.Get("/notes/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	r := chi.NewRouter()
+	r.Use(l.Middleware)
+	r.Mount("/", sub)
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	rules, err := ParseRules("POST /notes=1/m:2")
+	require.NoError(t, err)
+	h := newRouter(New(NewMemoryBackend(), rules, IP(false)))
+
+	post := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notes", nil)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("10.0.0.1:1")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "60", rr.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "2;w=120", rr.Header().Get("RateLimit-Policy"))
+	require.Equal(t, http.StatusCreated, post("10.0.0.1:2").Code)
+
+	rr = post("10.0.0.1:3")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "60", rr.Header().Get("Retry-After"))
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+	var p httpx.Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&p))
+	require.Equal(t, http.StatusTooManyRequests, p.Status)
+	require.Equal(t, "Too Many Requests", p.Title)
+	require.Equal(t, "/notes", p.Instance)
+
+	// Another client and routes without a rule are not limited.
+	require.Equal(t, http.StatusCreated, post("10.0.0.2:1").Code)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/notes/1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
+
+// A client rotating its API key still spends the bucket of its address.
+func TestMiddleware_APIKeyRotation(t *testing.T) {
+	rules, err := ParseRules("POST /notes=1/m:2")
+	require.NoError(t, err)
+	h := newRouter(New(NewMemoryBackend(), rules, IP(false), APIKey))
+
+	post := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notes", nil)
+		req.RemoteAddr = "10.0.0.1:1"
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusCreated, post("a").Code)
+	rr := post("b")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	rr = post("c")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "60", rr.Header().Get("Retry-After"))
+}
+
+// CORS runs ahead of the limiter (as in cmd/api), so browsers can read 429s
+// and preflight requests are answered before routing.
+func TestMiddleware_CORS(t *testing.T) {
+	rules, err := ParseRules("POST /notes=1/m:1")
+	require.NoError(t, err)
+	c, err := cors.New(cors.Options{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"POST"},
+		ExposedHeaders: []string{"Retry-After", "RateLimit-Remaining"},
+	})
+	require.NoError(t, err)
+	h := c.Handler(newRouter(New(NewMemoryBackend(), rules, IP(false))))
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/notes", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, preflight)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Empty(t, rr.Header().Get("RateLimit-Limit"))
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/notes", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	require.Equal(t, http.StatusCreated, post().Code)
+	rr = post()
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "Retry-After, RateLimit-Remaining", rr.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, "60", rr.Header().Get("Retry-After"))
+}
+
+func TestMiddleware_FailsOpen(t *testing.T) {
+	rules, err := ParseRules("*=1/m")
+	require.NoError(t, err)
+	h := newRouter(New(failingBackend{}, rules, IP(false)))
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/notes/1", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+}
@@ -0,0 +1,134 @@
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// DefaultBatchSize is the number of rows per COPY (and transaction).
+const DefaultBatchSize = 10_000
+
+var copyColumns = []string{"title", "content", "language", "created_at"}
+
+// Options control Load.
+type Options struct {
+	// BatchSize rows are copied per transaction; a failure keeps the batches
+	// already committed.
+	BatchSize int
+	// Progress, when set, is called after every batch.
+	Progress func(Stats)
+	// Analyze runs ANALYZE notes at the end, so planner statistics match
+	// the new data.
+	Analyze bool
+}
+
+// Stats reports the progress and throughput of Load.
+type Stats struct {
+	Rows    int64
+	Total   int64
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+func (s Stats) RowsPerSec() float64 { return perSec(float64(s.Rows), s.Elapsed) }
+
+// MBPerSec is the throughput of title and content text.
+func (s Stats) MBPerSec() float64 { return perSec(float64(s.Bytes)/(1<<20), s.Elapsed) }
+
+// ETA extrapolates the remaining time from the rate so far.
+func (s Stats) ETA() time.Duration {
+	if s.Rows == 0 || s.Rows >= s.Total {
+		return 0
+	}
+	return time.Duration(float64(s.Elapsed) * float64(s.Total-s.Rows) / float64(s.Rows))
+}
+
+func perSec(v float64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return v / d.Seconds()
+}
+
+// Load generates n notes with g and inserts them into notes with COPY. The
+// search_vector trigger fires for copied rows, so they are searchable;
+// seeded notes get no notes_audit rows.
+func Load(ctx context.Context, db *sql.DB, g *Generator, n int64, opts Options) (Stats, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer conn.Close()
+
+	st := Stats{Total: n}
+	start := time.Now()
+	err = conn.Raw(func(dc any) error {
+		pc, ok := dc.(*stdlib.Conn)
+		if !ok {
+			return errors.New("seed: COPY needs the pgx driver")
+		}
+		for st.Rows < n {
+			size := min(int64(opts.BatchSize), n-st.Rows)
+			copied, bytes, err := copyBatch(ctx, pc.Conn(), g, size)
+			st.Rows += copied
+			st.Bytes += bytes
+			st.Elapsed = time.Since(start)
+			if err != nil {
+				return err
+			}
+			if opts.Progress != nil {
+				opts.Progress(st)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+
+	if opts.Analyze {
+		if _, err := conn.ExecContext(ctx, "ANALYZE notes"); err != nil {
+			return st, err
+		}
+		st.Elapsed = time.Since(start)
+	}
+	return st, nil
+}
+
+// copyBatch copies size rows in one transaction. On error nothing of the
+// batch is kept and it returns zero rows.
+func copyBatch(ctx context.Context, conn *pgx.Conn, g *Generator, size int64) (int64, int64, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var bytes int64
+	var i int64
+	src := pgx.CopyFromFunc(func() ([]any, error) {
+		if i == size {
+			return nil, nil
+		}
+		i++
+		note := g.Next()
+		bytes += int64(len(note.Title) + len(note.Content))
+		return []any{note.Title, note.Content, note.Language, note.CreatedAt}, nil
+	})
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{"notes"}, copyColumns, src)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return copied, bytes, nil
+}
@@ -0,0 +1,99 @@
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// Dist is a distribution of non-negative integers, e.g. note lengths in words.
+type Dist struct {
+	kind string
+	// min/max bound uniform draws and clamp the others.
+	min, max int
+	// a, b are the mean and standard deviation of normal, or the median and
+	// sigma of lognormal.
+	a, b float64
+}
+
+// maxDraw caps every draw, so a long lognormal tail cannot blow up a row.
+const maxDraw = 100_000
+
+// ParseDist parses a distribution:
+//
+//	N                    always N
+//	MIN-MAX              uniform in [MIN, MAX]
+//	normal:MEAN,STDDEV   normal, clamped to [0, maxDraw]
+//	lognormal:MEDIAN,SIGMA
+//	                     log-normal (long tail), clamped to [0, maxDraw]
+func ParseDist(s string) (Dist, error) {
+	s = strings.TrimSpace(s)
+	kind, params, ok := strings.Cut(s, ":")
+	if !ok {
+		lo, hi, isRange := strings.Cut(s, "-")
+		if !isRange {
+			hi = lo
+		}
+		min, err1 := strconv.Atoi(lo)
+		max, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || min < 0 || max < min || max > maxDraw {
+			return Dist{}, fmt.Errorf("seed: invalid distribution %q", s)
+		}
+		return Uniform(min, max), nil
+	}
+
+	x, y, ok := strings.Cut(params, ",")
+	a, err1 := strconv.ParseFloat(x, 64)
+	b, err2 := strconv.ParseFloat(y, 64)
+	if !ok || err1 != nil || err2 != nil || a <= 0 || b < 0 {
+		return Dist{}, fmt.Errorf("seed: invalid distribution %q", s)
+	}
+	switch kind {
+	case "normal":
+		return Normal(a, b), nil
+	case "lognormal":
+		return LogNormal(a, b), nil
+	}
+	return Dist{}, fmt.Errorf("seed: unknown distribution %q", kind)
+}
+
+// Uniform draws integers in [min, max].
+func Uniform(min, max int) Dist { return Dist{kind: "uniform", min: min, max: max} }
+
+// Normal draws rounded normal values with the given mean and deviation.
+func Normal(mean, stddev float64) Dist {
+	return Dist{kind: "normal", max: maxDraw, a: mean, b: stddev}
+}
+
+// LogNormal draws values whose logarithm is normal: most are near median and
+// a few are much longer, like real notes.
+func LogNormal(median, sigma float64) Dist {
+	return Dist{kind: "lognormal", max: maxDraw, a: median, b: sigma}
+}
+
+// Draw returns the next value.
+func (d Dist) Draw(rnd *rand.Rand) int {
+	var v float64
+	switch d.kind {
+	case "normal":
+		v = d.a + d.b*rnd.NormFloat64()
+	case "lognormal":
+		v = d.a * math.Exp(d.b*rnd.NormFloat64())
+	default:
+		return d.min + rnd.IntN(d.max-d.min+1)
+	}
+	return min(max(int(math.Round(v)), d.min), d.max)
+}
+
+func (d Dist) String() string {
+	switch d.kind {
+	case "normal", "lognormal":
+		return fmt.Sprintf("%s:%g,%g", d.kind, d.a, d.b)
+	}
+	if d.min == d.max {
+		return strconv.Itoa(d.min)
+	}
+	return fmt.Sprintf("%d-%d", d.min, d.max)
+}
@@ -0,0 +1,141 @@
+// Package seed generates realistic synthetic notes and loads them with COPY,
+// for performance experiments such as the plans in scripts/explain.sql.
+//
+// Generation is deterministic: the same Config (including Seed and End)
+// produces the same notes in the same order.
+package seed
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// Config describes the generated data.
+type Config struct {
+	Seed uint64
+
+	// TitleWords and ContentWords are note lengths in words.
+	TitleWords   Dist
+	ContentWords Dist
+	// SentenceWords is the length of content sentences.
+	SentenceWords Dist
+
+	// RussianShare is the fraction of Russian notes; the rest are English.
+	RussianShare float64
+
+	// CreatedAt is spread uniformly over [End-Span, End].
+	End  time.Time
+	Span time.Duration
+
+	// Tags per note. Notes have no tags column, so tags are appended to the
+	// content as #hashtags and are found by full-text search.
+	Tags Dist
+}
+
+// DefaultConfig returns the defaults of notesctl seed with End set to now.
+func DefaultConfig() Config {
+	return Config{
+		Seed:          1,
+		TitleWords:    Uniform(2, 8),
+		ContentWords:  LogNormal(60, 0.8),
+		SentenceWords: Uniform(5, 15),
+		RussianShare:  0.3,
+		End:           time.Now().UTC().Truncate(time.Second),
+		Span:          365 * 24 * time.Hour,
+		Tags:          Uniform(0, 3),
+	}
+}
+
+// Validate reports configurations the generator cannot use.
+func (c Config) Validate() error {
+	switch {
+	case c.RussianShare < 0 || c.RussianShare > 1:
+		return errors.New("seed: russian share must be in [0, 1]")
+	case c.Span < 0:
+		return errors.New("seed: negative time span")
+	case c.End.IsZero():
+		return errors.New("seed: end time is required")
+	case c.TitleWords.kind == "" || c.ContentWords.kind == "" || c.SentenceWords.kind == "" || c.Tags.kind == "":
+		return errors.New("seed: every distribution must be set")
+	}
+	return nil
+}
+
+// Note is a generated note.
+type Note struct {
+	Title     string
+	Content   string
+	Language  string
+	CreatedAt time.Time
+	Tags      []string
+}
+
+// Generator produces notes; it is not safe for concurrent use.
+type Generator struct {
+	cfg Config
+	rnd *rand.Rand
+}
+
+func NewGenerator(cfg Config) *Generator {
+	// The second PCG word is fixed, so a seed alone selects the stream.
+	return &Generator{cfg: cfg, rnd: rand.New(rand.NewPCG(cfg.Seed, 0x6e6f746573))}
+}
+
+// Next generates the next note.
+func (g *Generator) Next() Note {
+	lang := notes.LangEnglish
+	if g.rnd.Float64() < g.cfg.RussianShare {
+		lang = notes.LangRussian
+	}
+	vocab := vocabularies[lang]
+
+	n := Note{
+		Language:  lang,
+		Title:     capitalize(g.words(vocab, max(1, g.cfg.TitleWords.Draw(g.rnd)))),
+		CreatedAt: g.cfg.End.Add(-time.Duration(g.rnd.Int64N(int64(g.cfg.Span) + 1))),
+	}
+
+	var b strings.Builder
+	for left := max(1, g.cfg.ContentWords.Draw(g.rnd)); left > 0; {
+		k := min(left, max(1, g.cfg.SentenceWords.Draw(g.rnd)))
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(capitalize(g.words(vocab, k)))
+		b.WriteByte('.')
+		left -= k
+	}
+	if k := g.cfg.Tags.Draw(g.rnd); k > 0 {
+		b.WriteByte('\n')
+		for i, j := range g.rnd.Perm(len(tags))[:min(k, len(tags))] {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString("#" + tags[j])
+			n.Tags = append(n.Tags, tags[j])
+		}
+	}
+	n.Content = b.String()
+	return n
+}
+
+func (g *Generator) words(v *vocabulary, n int) string {
+	w := make([]string, n)
+	for i := range w {
+		w[i] = v.word(g.rnd)
+	}
+	return strings.Join(w, " ")
+}
+
+func capitalize(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
@@ -0,0 +1,138 @@
+package seed
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+func TestParseDist(t *testing.T) {
+	for in, want := range map[string]Dist{
+		"5":                Uniform(5, 5),
+		"2-8":              Uniform(2, 8),
+		" 0-3 ":            Uniform(0, 3),
+		"normal:40,10":     Normal(40, 10),
+		"lognormal:60,0.8": LogNormal(60, 0.8),
+		"lognormal:60,0":   LogNormal(60, 0),
+	} {
+		got, err := ParseDist(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+		again, err := ParseDist(got.String())
+		require.NoError(t, err)
+		require.Equal(t, got, again)
+	}
+
+	for _, in := range []string{"", "x", "8-2", "-1", "1-200000", "normal:40", "normal:-1,2", "poisson:3,1", "normal:a,b"} {
+		_, err := ParseDist(in)
+		require.Error(t, err, in)
+	}
+}
+
+func TestDist_Draw(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	seen := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		v := Uniform(2, 4).Draw(rnd)
+		require.True(t, v >= 2 && v <= 4, v)
+		seen[v] = true
+	}
+	require.Len(t, seen, 3)
+
+	// Log-normal: the median is near the configured one, the tail is long.
+	draws := make([]int, 2001)
+	for i := range draws {
+		draws[i] = LogNormal(60, 0.8).Draw(rnd)
+		require.GreaterOrEqual(t, draws[i], 0)
+	}
+	var below, long int
+	for _, v := range draws {
+		if v < 60 {
+			below++
+		}
+		if v > 180 {
+			long++
+		}
+	}
+	require.InDelta(t, 1000, below, 100)
+	require.Greater(t, long, 10)
+
+	// Normal draws are clamped at zero.
+	zeros := 0
+	for i := 0; i < 1000; i++ {
+		v := Normal(1, 50).Draw(rnd)
+		require.GreaterOrEqual(t, v, 0)
+		if v == 0 {
+			zeros++
+		}
+	}
+	require.Greater(t, zeros, 400)
+}
+
+func TestGenerator(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 42
+	cfg.End = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	cfg.Span = 30 * 24 * time.Hour
+	require.NoError(t, cfg.Validate())
+
+	a, b := NewGenerator(cfg), NewGenerator(cfg)
+	other := cfg
+	other.Seed = 43
+	c := NewGenerator(other)
+
+	langs := map[string]int{}
+	var tagged, differ int
+	for i := 0; i < 2000; i++ {
+		n := a.Next()
+		require.Equal(t, n, b.Next())
+		if n.Title != c.Next().Title {
+			differ++
+		}
+
+		langs[n.Language]++
+		require.True(t, notes.SupportedLanguage(n.Language))
+		require.NotEmpty(t, n.Title)
+		require.NotEmpty(t, n.Content)
+		require.False(t, n.CreatedAt.After(cfg.End))
+		require.False(t, n.CreatedAt.Before(cfg.End.Add(-cfg.Span)))
+		require.LessOrEqual(t, len(n.Tags), 3)
+		if len(n.Tags) > 0 {
+			tagged++
+			_, line, ok := strings.Cut(n.Content, "\n")
+			require.True(t, ok)
+			require.Equal(t, "#"+strings.Join(n.Tags, " #"), line)
+		}
+		require.Equal(t, n.Language, notes.DetectLanguage(n.Title+" "+n.Content), n.Title)
+	}
+	require.InDelta(t, 600, langs[notes.LangRussian], 90)
+	require.Greater(t, tagged, 1000)
+	require.Greater(t, differ, 1900)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, mut := range []func(*Config){
+		func(c *Config) { c.RussianShare = 1.5 },
+		func(c *Config) { c.Span = -time.Hour },
+		func(c *Config) { c.End = time.Time{} },
+		func(c *Config) { c.Tags = Dist{} },
+	} {
+		cfg := DefaultConfig()
+		mut(&cfg)
+		require.Error(t, cfg.Validate())
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := Stats{Rows: 250, Total: 1000, Bytes: 2 << 20, Elapsed: 2 * time.Second}
+	require.Equal(t, 125.0, s.RowsPerSec())
+	require.Equal(t, 1.0, s.MBPerSec())
+	require.Equal(t, 6*time.Second, s.ETA())
+	require.Zero(t, Stats{Total: 10}.ETA())
+	require.Zero(t, Stats{}.RowsPerSec())
+}
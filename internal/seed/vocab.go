@@ -0,0 +1,73 @@
+package seed
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// zipfExponent skews word choice like natural text: the first words of a
+// vocabulary are much more frequent than the last.
+const zipfExponent = 1.1
+
+// vocabulary draws words with Zipf-like frequencies.
+type vocabulary struct {
+	words []string
+	cum   []float64
+}
+
+func newVocabulary(text string) *vocabulary {
+	v := &vocabulary{words: strings.Fields(text)}
+	v.cum = make([]float64, len(v.words))
+	total := 0.0
+	for i := range v.words {
+		total += 1 / math.Pow(float64(i+1), zipfExponent)
+		v.cum[i] = total
+	}
+	for i := range v.cum {
+		v.cum[i] /= total
+	}
+	return v
+}
+
+func (v *vocabulary) word(rnd *rand.Rand) string {
+	i := sort.SearchFloat64s(v.cum, rnd.Float64())
+	return v.words[min(i, len(v.words)-1)]
+}
+
+// Vocabularies are ordered roughly by frequency.
+var vocabularies = map[string]*vocabulary{
+	notes.LangEnglish: newVocabulary(`
+		the of and to a in is for that on with it as be this by are from at we
+		was not have or will can should need but all new more after about into
+		project meeting release database query index team notes plan review customer
+		server search report deadline budget schedule design feature incident backup
+		migration latency cache deploy service api client request response error
+		performance test build version update config document draft invoice order
+		payment contract summary agenda decision action item follow up priority
+		week month quarter today tomorrow morning afternoon call email chat ticket
+		bug fix issue change support user account access permission security audit
+		metric dashboard alert monitoring storage disk memory network traffic load
+		capacity scaling cluster node replica primary standby failover recovery
+		idea question answer example reference link note list table chart diagram`),
+	notes.LangRussian: newVocabulary(`
+		и в не на что с по для как это к из о у за от же так но все мы
+		был было будет нужно можно если после перед про при его их она они
+		проект встреча релиз база запрос индекс команда заметки план обзор клиент
+		сервер поиск отчёт срок бюджет график дизайн функция инцидент резервная копия
+		миграция задержка кэш выкладка сервис клиента запроса ответ ошибка
+		производительность тест сборка версия обновление настройка документ черновик
+		счёт заказ оплата договор итоги повестка решение задача приоритет
+		неделя месяц квартал сегодня завтра утром днём звонок письмо чат заявка
+		баг исправление проблема изменение поддержка пользователь доступ права
+		безопасность аудит метрика дашборд оповещение мониторинг хранилище диск
+		память сеть трафик нагрузка кластер узел реплика основной резервный
+		восстановление идея вопрос пример ссылка список таблица схема`),
+}
+
+// tags are shared by both languages, like real hashtags usually are.
+var tags = strings.Fields(`work personal todo idea meeting release backend frontend
+	database ops incident research reading travel finance health urgent later`)
@@ -0,0 +1,30 @@
+-- 001_init.sql
+-- Timestamps are Unix microseconds. notes_fts holds the lexemes of every note
+-- (textsearch.Lexemes with the note language) written by the store in the same
+-- transaction as the note: FTS5 tokenizers cannot stem Russian, so stemming
+-- happens in Go and FTS5 only indexes the stems.
+CREATE TABLE notes (
+  id          INTEGER PRIMARY KEY AUTOINCREMENT,
+  title       TEXT    NOT NULL,
+  title_lower TEXT    NOT NULL,
+  content     TEXT    NOT NULL,
+  language    TEXT    NOT NULL DEFAULT 'simple'
+              CHECK (language IN ('simple', 'english', 'russian')),
+  created_at  INTEGER NOT NULL
+);
+
+CREATE TABLE notes_audit (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id    INTEGER NOT NULL REFERENCES notes (id) ON DELETE CASCADE,
+  action     TEXT    NOT NULL,
+  created_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_notes_created_id ON notes (created_at DESC, id DESC);
+CREATE INDEX idx_notes_title_lower ON notes (title_lower);
+CREATE INDEX idx_notes_audit_note ON notes_audit (note_id);
+
+CREATE VIRTUAL TABLE notes_fts USING fts5 (
+  title, content,
+  tokenize = 'unicode61 remove_diacritics 0'
+);
@@ -0,0 +1,110 @@
+package sqlitestore
+
+// SQL of every store statement, reported to query hooks under the same names
+// as the notes.Repository ones. Timestamps are Unix microseconds.
+const (
+	noteColumns = `id, title, content, language, created_at`
+
+	qNoteGet = `SELECT ` + noteColumns + ` FROM notes WHERE id = ?`
+
+	qNoteInsert = `
+		INSERT INTO notes (title, title_lower, content, language, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`
+
+	qNoteUpdate = `
+		UPDATE notes
+		SET title = ?, title_lower = ?, content = ?, language = ?
+		WHERE id = ?
+		RETURNING ` + noteColumns
+
+	qNoteDelete = `DELETE FROM notes WHERE id = ?`
+
+	qAuditInsert = `INSERT INTO notes_audit (note_id, action, created_at) VALUES (?, ?, ?)`
+
+	qFTSInsert = `INSERT INTO notes_fts (rowid, title, content) VALUES (?, ?, ?)`
+
+	qFTSUpdate = `UPDATE notes_fts SET title = ?, content = ? WHERE rowid = ?`
+
+	qFTSDelete = `DELETE FROM notes_fts WHERE rowid = ?`
+
+	// Keyset pagination (idx_notes_created_id).
+	qNoteListFirst = `
+		SELECT ` + noteColumns + `
+		FROM notes
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`
+
+	qNoteListAfter = `
+		SELECT ` + noteColumns + `
+		FROM notes
+		WHERE (created_at, id) < (?, ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`
+
+	// Search: an FTS5 query over lexemes, then the keyset order.
+	qNoteSearch = `
+		SELECT ` + noteColumns + `
+		FROM notes
+		WHERE id IN (SELECT rowid FROM notes_fts WHERE notes_fts MATCH ?)
+		  AND language = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`
+
+	qNoteSearchAfter = `
+		SELECT ` + noteColumns + `
+		FROM notes
+		WHERE id IN (SELECT rowid FROM notes_fts WHERE notes_fts MATCH ?)
+		  AND language = ?
+		  AND (created_at, id) < (?, ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`
+
+	// Fuzzy search scans titles: SQLite has no trigram index.
+	qNoteFuzzy = `
+		SELECT ` + noteColumns + `, word_similarity(?, title) AS score
+		FROM notes
+		WHERE score >= ?
+		ORDER BY score DESC, created_at DESC, id DESC
+		LIMIT ?`
+
+	// Title autocomplete: a range on idx_notes_title_lower (LIKE would only
+	// fold ASCII and skip the index), closest matches first.
+	qNoteSuggest = `
+		SELECT id, title
+		FROM notes
+		WHERE title_lower >= ? AND title_lower < ?
+		ORDER BY similarity(title_lower, ?) DESC, created_at DESC, id DESC
+		LIMIT ?`
+
+	// Related notes: the source note with its lexemes, then candidates sharing
+	// a lexeme (FTS5), with a similar title, or sharing a hashtag, a URL or a
+	// reference (scan); the text score is computed in Go.
+	qNoteRelatedSource = `
+		SELECT n.title, n.content, f.title, f.content
+		FROM notes n JOIN notes_fts f ON f.rowid = n.id
+		WHERE n.id = ?`
+
+	relatedColumns = `n.id, n.title, n.language, n.created_at, f.title, f.content,
+		similarity(n.title, ?) AS title_score,
+		tag_similarity(n.content, ?) AS tag_score,
+		link_similarity(n.id, n.content, ?, ?) AS link_score`
+
+	qNoteRelated = `
+		SELECT ` + relatedColumns + `
+		FROM notes n JOIN notes_fts f ON f.rowid = n.id
+		WHERE n.id <> ?
+		  AND (n.id IN (SELECT rowid FROM notes_fts WHERE notes_fts MATCH ?) OR title_score >= ?
+		       OR tag_score > 0 OR link_score > 0)`
+
+	qNoteRelatedTitle = `
+		SELECT ` + relatedColumns + `
+		FROM notes n JOIN notes_fts f ON f.rowid = n.id
+		WHERE n.id <> ? AND (title_score >= ? OR tag_score > 0 OR link_score > 0)`
+
+	qNoteBatchGet = `
+		SELECT ` + noteColumns + `
+		FROM notes
+		WHERE id IN (SELECT value FROM json_each(?))
+		ORDER BY created_at DESC, id DESC`
+)
@@ -0,0 +1,158 @@
+// Package sqlitestore is a notes.Store on an embedded SQLite database
+// (modernc.org/sqlite, pure Go), for single-user and offline deployments.
+//
+// It keeps the semantics of notes.Repository: notes are ordered by
+// (created_at, id) with the same keyset pagination, missing notes are
+// sql.ErrNoRows and IDs are never reused. Search uses FTS5 over lexemes from
+// package textsearch, so it approximates Postgres text search the same way
+// notes.MemoryStore does. Fuzzy search, autocomplete ranking and title
+// similarity of related notes use trigram functions registered with SQLite
+// (similarity, word_similarity) and scan the table; so do the hashtag and link
+// signals of related notes (tag_similarity, link_similarity).
+//
+// Saved searches, idempotency keys and the rate limit backend need Postgres.
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+
+	"modernc.org/sqlite"
+
+	"example.com/notes-api-pz14/internal/migrate"
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/textsearch"
+)
+
+// Latest is the schema version the code expects (the highest migration file).
+const Latest = 1
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+func init() {
+	for name, fn := range map[string]func(a, b string) float64{
+		"similarity":      textsearch.Similarity,
+		"word_similarity": textsearch.WordSimilarity,
+		"tag_similarity":  notes.TagSimilarity,
+	} {
+		if err := sqlite.RegisterDeterministicScalarFunction(name, 2, trigramFunc(fn)); err != nil {
+			panic(err)
+		}
+	}
+	if err := sqlite.RegisterDeterministicScalarFunction("link_similarity", 4, linkSimilarity); err != nil {
+		panic(err)
+	}
+}
+
+// linkSimilarity is notes.LinkSimilarity(id, content, id, content).
+func linkSimilarity(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	var ids [2]int64
+	var contents [2]string
+	for i, v := range args {
+		switch v := v.(type) {
+		case int64:
+			ids[i/2] = v
+		case string:
+			contents[i/2] = v
+		case []byte:
+			contents[i/2] = string(v)
+		case nil:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("sqlitestore: unexpected argument %T", v)
+		}
+	}
+	return notes.LinkSimilarity(ids[0], contents[0], ids[1], contents[1]), nil
+}
+
+// trigramFunc adapts a function of two strings to SQLite; NULL arguments give
+// NULL.
+func trigramFunc(fn func(a, b string) float64) func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+	return func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var s [2]string
+		for i, v := range args {
+			switch v := v.(type) {
+			case string:
+				s[i] = v
+			case []byte:
+				s[i] = string(v)
+			case nil:
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("sqlitestore: unexpected argument %T", v)
+			}
+		}
+		return fn(s[0], s[1]), nil
+	}
+}
+
+// Open opens the database file at path, creating it if needed, and migrates
+// it to the latest schema. Connections use WAL, foreign keys, a busy timeout
+// and BEGIN IMMEDIATE, so concurrent writers queue instead of failing.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	q := url.Values{
+		"_pragma": {"journal_mode(WAL)", "foreign_keys(1)", "busy_timeout(5000)"},
+		"_txlock": {"immediate"},
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := Migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate applies pending migrations, one transaction each. The schema
+// version is kept in PRAGMA user_version.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+	list, err := migrate.Load(sub)
+	if err != nil {
+		return err
+	}
+	for _, m := range list {
+		if err := apply(ctx, db, m); err != nil {
+			return fmt.Errorf("sqlitestore: migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, m migrate.Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Read under the write lock: another process may be migrating too.
+	var version int64
+	if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > Latest {
+		return fmt.Errorf("database schema version %d is newer than this build (%d)", version, Latest)
+	}
+	if version >= m.Version {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", m.Version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,393 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/textsearch"
+)
+
+// Store implements notes.Store on a database opened with Open.
+type Store struct {
+	db    *sql.DB
+	hooks []notes.QueryHook
+	now   func() time.Time
+}
+
+var _ notes.Store = (*Store)(nil)
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// AddHook registers a hook called around every statement. Hooks must be added
+// before the store is used.
+func (s *Store) AddHook(h notes.QueryHook) {
+	s.hooks = append(s.hooks, h)
+}
+
+// Create inserts the note, its lexemes and an audit entry in one transaction.
+func (s *Store) Create(ctx context.Context, title, content, language string) (notes.Note, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return notes.Note{}, err
+	}
+	defer tx.Rollback()
+
+	n := notes.Note{Title: title, Content: content, Language: language, CreatedAt: s.now().UTC().Truncate(time.Microsecond)}
+	at := n.CreatedAt.UnixMicro()
+	err = s.queryRow(ctx, tx, "notes.insert", qNoteInsert, []any{&n.ID},
+		title, strings.ToLower(title), content, language, at)
+	if err != nil {
+		return notes.Note{}, err
+	}
+	titleLex, contentLex := lexemes(n)
+	if _, err := s.exec(ctx, tx, "notes_fts.insert", qFTSInsert, n.ID, titleLex, contentLex); err != nil {
+		return notes.Note{}, err
+	}
+	if _, err := s.exec(ctx, tx, "notes_audit.insert", qAuditInsert, n.ID, "create", at); err != nil {
+		return notes.Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return notes.Note{}, err
+	}
+	return n, nil
+}
+
+func (s *Store) Get(ctx context.Context, id int64) (notes.Note, error) {
+	var n notes.Note
+	var at int64
+	err := s.queryRow(ctx, s.db, "notes.get", qNoteGet,
+		[]any{&n.ID, &n.Title, &n.Content, &n.Language, &at}, id)
+	if err != nil {
+		return notes.Note{}, err
+	}
+	n.CreatedAt = fromMicros(at)
+	return n, nil
+}
+
+func (s *Store) Update(ctx context.Context, id int64, title, content, language string) (notes.Note, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return notes.Note{}, err
+	}
+	defer tx.Rollback()
+
+	var n notes.Note
+	var at int64
+	err = s.queryRow(ctx, tx, "notes.update", qNoteUpdate,
+		[]any{&n.ID, &n.Title, &n.Content, &n.Language, &at},
+		title, strings.ToLower(title), content, language, id)
+	if err != nil {
+		return notes.Note{}, err
+	}
+	n.CreatedAt = fromMicros(at)
+	titleLex, contentLex := lexemes(n)
+	if _, err := s.exec(ctx, tx, "notes_fts.update", qFTSUpdate, titleLex, contentLex, id); err != nil {
+		return notes.Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return notes.Note{}, err
+	}
+	return n, nil
+}
+
+// Delete removes the note and its lexemes; audit entries go with the note
+// (ON DELETE CASCADE).
+func (s *Store) Delete(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	a, err := s.exec(ctx, tx, "notes.delete", qNoteDelete, id)
+	if err != nil {
+		return err
+	}
+	if a == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := s.exec(ctx, tx, "notes_fts.delete", qFTSDelete, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) List(ctx context.Context, p notes.ListParams) ([]notes.Note, error) {
+	if p.Limit <= 0 || p.Limit > 200 {
+		p.Limit = 20
+	}
+	hasCursor := p.CursorCreatedAt != nil && p.CursorID != nil
+
+	if p.Query != "" && p.Fuzzy {
+		return queryAll(ctx, s, "notes.fuzzy", qNoteFuzzy, scanScoredNotes,
+			p.Query, textsearch.WordSimilarityThreshold, p.Limit)
+	}
+
+	if p.Query != "" {
+		if !notes.SupportedLanguage(p.Language) {
+			p.Language = notes.DetectLanguage(p.Query)
+		}
+		terms := textsearch.Lexemes(p.Language, p.Query)
+		// Like plainto_tsquery, a query of only stop words matches nothing.
+		if len(terms) == 0 {
+			return []notes.Note{}, nil
+		}
+		var out []notes.Note
+		var err error
+		if hasCursor {
+			out, err = queryAll(ctx, s, "notes.search", qNoteSearchAfter, scanNotes,
+				matchAll(terms), p.Language, p.CursorCreatedAt.UnixMicro(), *p.CursorID, p.Limit)
+		} else {
+			out, err = queryAll(ctx, s, "notes.search", qNoteSearch, scanNotes, matchAll(terms), p.Language, p.Limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range out {
+			out[i].Highlight = notes.SearchHighlight(out[i], terms, p.Highlight)
+			out[i].Content = ""
+		}
+		return out, nil
+	}
+
+	if hasCursor {
+		return queryAll(ctx, s, "notes.list_after", qNoteListAfter, scanNotes,
+			p.CursorCreatedAt.UnixMicro(), *p.CursorID, p.Limit)
+	}
+	return queryAll(ctx, s, "notes.list_first", qNoteListFirst, scanNotes, p.Limit)
+}
+
+// Suggest returns titles starting with prefix, case-insensitively, closest
+// matches first.
+func (s *Store) Suggest(ctx context.Context, prefix string, limit int) ([]notes.Suggestion, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	prefix = strings.ToLower(prefix)
+	// U+10FFFF sorts after every character that can follow the prefix.
+	return queryAll(ctx, s, "notes.suggest", qNoteSuggest, scanSuggestions,
+		prefix, prefix+"\U0010FFFF", prefix, limit)
+}
+
+// Related mirrors notes.Repository.Related: notes sharing a lexeme with the
+// note id, ranked by textsearch.Rank, with a similar title, or sharing
+// hashtags, URLs or a reference with it. Returns sql.ErrNoRows if the note is
+// missing.
+func (s *Store) Related(ctx context.Context, id int64, limit int) ([]notes.RelatedNote, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+	var title, content, titleLex, contentLex string
+	if err := s.queryRow(ctx, s.db, "notes.related_source", qNoteRelatedSource,
+		[]any{&title, &content, &titleLex, &contentLex}, id); err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(titleLex + " " + contentLex)
+	var candidates []related
+	var err error
+	if len(terms) > 0 {
+		candidates, err = queryAll(ctx, s, "notes.related", qNoteRelated, scanRelated,
+			title, content, id, content, id, matchAny(terms), textsearch.SimilarityThreshold)
+	} else {
+		candidates, err = queryAll(ctx, s, "notes.related", qNoteRelatedTitle, scanRelated,
+			title, content, id, content, id, textsearch.SimilarityThreshold)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		query[t] = true
+	}
+	out := make([]notes.RelatedNote, 0, len(candidates))
+	for _, c := range candidates {
+		r := c.note
+		r.Scores.Text = textsearch.Rank(strings.Fields(c.titleLex), strings.Fields(c.titleLex+" "+c.contentLex), query)
+		r.Score = r.Scores.Score()
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out[:min(limit, len(out))], nil
+}
+
+func (s *Store) BatchGet(ctx context.Context, ids []int64) ([]notes.Note, error) {
+	if len(ids) == 0 {
+		return []notes.Note{}, nil
+	}
+	list, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	return queryAll(ctx, s, "notes.batch_get", qNoteBatchGet, scanNotes, string(list))
+}
+
+// lexemes returns the notes_fts columns of n.
+func lexemes(n notes.Note) (title, content string) {
+	return strings.Join(textsearch.Lexemes(n.Language, n.Title), " "),
+		strings.Join(textsearch.Lexemes(n.Language, n.Content), " ")
+}
+
+// matchAll is the FTS5 query of notes containing every term; matchAny of
+// notes containing one of them.
+func matchAll(terms []string) string { return matchJoin(terms, " AND ") }
+
+func matchAny(terms []string) string { return matchJoin(terms, " OR ") }
+
+func matchJoin(terms []string, op string) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, op)
+}
+
+func fromMicros(us int64) time.Time {
+	return time.UnixMicro(us).UTC()
+}
+
+func scanNotes(rows *sql.Rows) ([]notes.Note, error) {
+	out := make([]notes.Note, 0, 32)
+	for rows.Next() {
+		var n notes.Note
+		var at int64
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.Language, &at); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = fromMicros(at)
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+func scanScoredNotes(rows *sql.Rows) ([]notes.Note, error) {
+	out := make([]notes.Note, 0, 32)
+	for rows.Next() {
+		var n notes.Note
+		var at int64
+		if err := rows.Scan(&n.ID, &n.Title, &n.Content, &n.Language, &at, &n.Score); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = fromMicros(at)
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+func scanSuggestions(rows *sql.Rows) ([]notes.Suggestion, error) {
+	out := make([]notes.Suggestion, 0, 16)
+	for rows.Next() {
+		var s notes.Suggestion
+		if err := rows.Scan(&s.ID, &s.Title); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// related is a candidate of Related with the lexemes it is ranked by.
+type related struct {
+	note                 notes.RelatedNote
+	titleLex, contentLex string
+}
+
+func scanRelated(rows *sql.Rows) ([]related, error) {
+	out := make([]related, 0, 16)
+	for rows.Next() {
+		var r related
+		var at int64
+		if err := rows.Scan(&r.note.ID, &r.note.Title, &r.note.Language, &at,
+			&r.titleLex, &r.contentLex, &r.note.Scores.Title, &r.note.Scores.Tags, &r.note.Scores.Links); err != nil {
+			return nil, err
+		}
+		r.note.CreatedAt = fromMicros(at)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// start notifies hooks that a statement begins and returns a function that
+// reports its outcome.
+func (s *Store) start(ctx context.Context, name, query string, args []any) (context.Context, func(rows int64, err error)) {
+	if len(s.hooks) == 0 {
+		return ctx, func(int64, error) {}
+	}
+	begin := time.Now()
+	for _, h := range s.hooks {
+		ctx = h.BeforeQuery(ctx, name)
+	}
+	return ctx, func(rows int64, err error) {
+		ev := notes.QueryEvent{Name: name, SQL: query, Args: args, Rows: rows, Duration: time.Since(begin), Err: err}
+		for i := len(s.hooks) - 1; i >= 0; i-- {
+			s.hooks[i].AfterQuery(ctx, ev)
+		}
+	}
+}
+
+// queryAll runs a read statement and scans all rows with scan.
+func queryAll[T any](ctx context.Context, s *Store, name, query string, scan func(*sql.Rows) ([]T, error), args ...any) ([]T, error) {
+	ctx, done := s.start(ctx, name, query, args)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		done(0, err)
+		return nil, err
+	}
+	defer rows.Close()
+	out, err := scan(rows)
+	done(int64(len(out)), err)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// queryRow runs a statement returning a single row into dest.
+func (s *Store) queryRow(ctx context.Context, q queryer, name, query string, dest []any, args ...any) error {
+	ctx, done := s.start(ctx, name, query, args)
+	err := q.QueryRowContext(ctx, query, args...).Scan(dest...)
+	var rows int64
+	if err == nil {
+		rows = 1
+	}
+	done(rows, err)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// exec runs a statement and returns the number of affected rows.
+func (s *Store) exec(ctx context.Context, q queryer, name, query string, args ...any) (int64, error) {
+	ctx, done := s.start(ctx, name, query, args)
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		done(0, err)
+		return 0, err
+	}
+	a, _ := res.RowsAffected()
+	done(a, nil)
+	return a, nil
+}
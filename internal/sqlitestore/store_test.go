@@ -0,0 +1,84 @@
+package sqlitestore_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+	"example.com/notes-api-pz14/internal/notes/storetest"
+	"example.com/notes-api-pz14/internal/sqlitestore"
+)
+
+func open(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sqlitestore.Open(context.Background(), filepath.Join(t.TempDir(), "notes.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) notes.Store { return sqlitestore.New(open(t)) })
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+
+	var version int64
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version))
+	require.Equal(t, int64(sqlitestore.Latest), version)
+
+	// Migrating again is a no-op.
+	require.NoError(t, sqlitestore.Migrate(ctx, db))
+
+	_, err := db.ExecContext(ctx, "PRAGMA user_version = 99")
+	require.NoError(t, err)
+	require.ErrorContains(t, sqlitestore.Migrate(ctx, db), "newer than this build")
+}
+
+func TestStore_Audit(t *testing.T) {
+	ctx := context.Background()
+	db := open(t)
+	s := sqlitestore.New(db)
+
+	n, err := s.Create(ctx, "Title", "content", notes.LangEnglish)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM notes_audit WHERE note_id = ? AND action = 'create'", n.ID).Scan(&count))
+	require.Equal(t, 1, count)
+
+	require.NoError(t, s.Delete(ctx, n.ID))
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM notes_audit").Scan(&count))
+	require.Zero(t, count)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM notes_fts").Scan(&count))
+	require.Zero(t, count)
+
+	_, err = s.Create(ctx, "Title", "content", "klingon")
+	require.Error(t, err)
+}
+
+type recordHook struct{ names []string }
+
+func (h *recordHook) BeforeQuery(ctx context.Context, _ string) context.Context { return ctx }
+
+func (h *recordHook) AfterQuery(_ context.Context, ev notes.QueryEvent) {
+	h.names = append(h.names, ev.Name)
+}
+
+func TestStore_Hooks(t *testing.T) {
+	ctx := context.Background()
+	s := sqlitestore.New(open(t))
+	h := &recordHook{}
+	s.AddHook(h)
+
+	n, err := s.Create(ctx, "Title", "content", notes.LangEnglish)
+	require.NoError(t, err)
+	_, err = s.Get(ctx, n.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"notes.insert", "notes_fts.insert", "notes_audit.insert", "notes.get"}, h.names)
+}
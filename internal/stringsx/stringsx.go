@@ -1,6 +1,9 @@
 package stringsx
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+)
 
 // Clip returns at most max characters of s.
 // If max <= 0, an empty string is returned.
@@ -23,3 +26,137 @@ func Normalize(s string) string {
 func IsEmpty(s string) bool {
 	return strings.TrimSpace(s) == ""
 }
+
+// SnippetOptions controls how Snippet cuts fragments out of a text.
+// Zero values are replaced with the same defaults ts_headline uses.
+type SnippetOptions struct {
+	MaxFragments int
+	MaxWords     int
+	StartSel     string
+	StopSel      string
+	Delimiter    string
+}
+
+func (o SnippetOptions) withDefaults() SnippetOptions {
+	if o.MaxFragments <= 0 {
+		o.MaxFragments = 1
+	}
+	if o.MaxWords <= 0 {
+		o.MaxWords = 35
+	}
+	if o.StartSel == "" && o.StopSel == "" {
+		o.StartSel, o.StopSel = "<b>", "</b>"
+	}
+	if o.Delimiter == "" {
+		o.Delimiter = " ... "
+	}
+	return o
+}
+
+// Terms splits a search query into unique normalized words.
+func Terms(q string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, w := range strings.Fields(q) {
+		w = Normalize(trimPunct(w))
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+	}
+	return out
+}
+
+// Highlight wraps every word of s that matches one of terms into start/stop.
+// Matching is case-insensitive and ignores surrounding punctuation.
+func Highlight(s string, terms []string, start, stop string) string {
+	words := strings.Fields(s)
+	if len(words) == 0 || len(terms) == 0 {
+		return s
+	}
+	set := termSet(terms)
+	for i, w := range words {
+		if set[Normalize(trimPunct(w))] {
+			words[i] = mark(w, start, stop)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Snippet returns up to o.MaxFragments fragments of at most o.MaxWords words
+// centred around the words of text that match terms, with matches wrapped into
+// o.StartSel/o.StopSel and fragments joined by o.Delimiter. When nothing
+// matches, the first o.MaxWords words are returned. The text is split on
+// whitespace, so multi-byte characters are never cut in half.
+func Snippet(text string, terms []string, o SnippetOptions) string {
+	o = o.withDefaults()
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return ""
+	}
+
+	set := termSet(terms)
+	var matches []int
+	for i, w := range words {
+		if set[Normalize(trimPunct(w))] {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		end := min(len(words), o.MaxWords)
+		return strings.Join(words[:end], " ")
+	}
+
+	var fragments []string
+	prevEnd := 0
+	for _, m := range matches {
+		if len(fragments) == o.MaxFragments {
+			break
+		}
+		if m < prevEnd {
+			continue
+		}
+		start := max(prevEnd, m-(o.MaxWords-1)/2)
+		end := min(len(words), start+o.MaxWords)
+		start = max(prevEnd, end-o.MaxWords)
+
+		frag := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			w := words[i]
+			if set[Normalize(trimPunct(w))] {
+				w = mark(w, o.StartSel, o.StopSel)
+			}
+			frag = append(frag, w)
+		}
+		fragments = append(fragments, strings.Join(frag, " "))
+		prevEnd = end
+	}
+	return strings.Join(fragments, o.Delimiter)
+}
+
+func termSet(terms []string) map[string]bool {
+	set := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		set[Normalize(t)] = true
+	}
+	return set
+}
+
+func isPunct(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func trimPunct(w string) string {
+	return strings.TrimFunc(w, isPunct)
+}
+
+// mark wraps the word core into start/stop, leaving punctuation outside.
+func mark(w, start, stop string) string {
+	core := trimPunct(w)
+	if core == "" {
+		return w
+	}
+	i := strings.Index(w, core)
+	return w[:i] + start + core + stop + w[i+len(core):]
+}
@@ -33,3 +33,42 @@ func TestNormalize_And_IsEmpty(t *testing.T) {
 	require.True(t, IsEmpty("   \n\t  "))
 	require.False(t, IsEmpty(" x "))
 }
+
+func TestTerms(t *testing.T) {
+	require.Equal(t, []string{"hello", "мир"}, Terms("  Hello, МИР! hello "))
+	require.Empty(t, Terms(" ,. "))
+}
+
+func TestHighlight(t *testing.T) {
+	require.Equal(t, "Say <b>hello</b>, world", Highlight("Say hello, world", []string{"hello"}, "<b>", "</b>"))
+	require.Equal(t, "no match", Highlight("no match", []string{"x"}, "<b>", "</b>"))
+}
+
+func TestSnippet_Table(t *testing.T) {
+	opts := SnippetOptions{MaxFragments: 2, MaxWords: 3, StartSel: "[", StopSel: "]", Delimiter: " | "}
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"empty", "", []string{"a"}, ""},
+		{"no match returns head", "one two three four", []string{"x"}, "one two three"},
+		{"single match centred", "one two three four five", []string{"three"}, "two [three] four"},
+		{"match at start", "Alpha beta gamma delta", []string{"alpha"}, "[Alpha] beta gamma"},
+		{"two fragments", "a b c d e f g h i j", []string{"b", "i"}, "a [b] c | h [i] j"},
+		{"fragment limit", "x a x a x a x", []string{"a"}, "x [a] x | [a] x [a]"},
+		{"cyrillic is rune safe", "Это первая заметка про заметки.", []string{"заметка"}, "первая [заметка] про"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Snippet(tt.text, tt.terms, opts))
+		})
+	}
+}
+
+func TestSnippet_Defaults(t *testing.T) {
+	require.Equal(t, "a <b>b</b> c", Snippet("a b c", []string{"b"}, SnippetOptions{}))
+}
@@ -0,0 +1,178 @@
+// Package textsearch approximates Postgres full-text search and pg_trgm in Go,
+// for stores without them (notes.MemoryStore, SQLite). Languages are text
+// search configuration names: "simple", "english" or "russian". Results agree
+// with Postgres on ordinary words; exotic inflections and stop words outside
+// the short lists below may differ.
+package textsearch
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Default pg_trgm thresholds of the <% and % operators.
+const (
+	WordSimilarityThreshold = 0.6
+	SimilarityThreshold     = 0.3
+)
+
+var stopWords = map[string]map[string]bool{
+	"english": wordSet(`a an and are as at be but by for from has have he her his i if in into is it
+		its me my no not of on or our she so such that the their then there these they this to
+		was we were what when which who will with you your`),
+	"russian": wordSet(`а без более бы был была были было быть в вам вас весь во вот все всего всех вы
+		где да даже для до его ее ей ему если есть еще же за здесь и из или им их к как
+		когда кто ли между мне мы на над нас не него нее нет ни них но ну о об однако он она
+		они оно от по под после при про с со так также там тем то того тоже только том ты у
+		уже чем что чтобы эта эти это этого этой этом этот я`),
+}
+
+// Suffixes removed by Stem, longest first.
+var suffixes = map[string][]string{
+	"english": {"ations", "ation", "ments", "ment", "ness", "ings", "ing", "ies", "ied", "ed", "ly", "s"},
+	"russian": {"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "иях", "иям", "ией",
+		"ах", "ях", "ов", "ев", "ой", "ей", "ий", "ый", "ом", "ем", "ам", "ям", "ую", "юю", "ая", "яя",
+		"ое", "ее", "ые", "ие", "а", "я", "ы", "и", "у", "ю", "е", "о", "ь"},
+}
+
+// IsStopWord reports whether the lower-case word w is ignored by lang.
+func IsStopWord(lang, w string) bool { return stopWords[lang][w] }
+
+func wordSet(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+// Words splits s into lower-case runs of letters and digits.
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Lexemes is the counterpart of to_tsvector(lang, s): stems of the words of
+// s without stop words, in order of appearance.
+func Lexemes(lang, s string) []string {
+	var out []string
+	for _, w := range Words(s) {
+		if stopWords[lang][w] {
+			continue
+		}
+		out = append(out, Stem(lang, w))
+	}
+	return out
+}
+
+// Stem removes one inflection suffix (and a final English "e") while at
+// least three letters remain. 'simple' does not stem.
+func Stem(lang, w string) string {
+	w = strings.ReplaceAll(w, "ё", "е")
+	r := []rune(w)
+	for _, suf := range suffixes[lang] {
+		n := len([]rune(suf))
+		if len(r)-n >= 3 && strings.HasSuffix(w, suf) {
+			r = r[:len(r)-n]
+			break
+		}
+	}
+	if lang == "english" && len(r) > 3 && r[len(r)-1] == 'e' {
+		r = r[:len(r)-1]
+	}
+	return string(r)
+}
+
+// Trigrams returns pg_trgm trigrams of s in order: every word is padded with
+// two spaces in front and one behind.
+func Trigrams(s string) []string {
+	var out []string
+	for _, w := range Words(s) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			out = append(out, string(r[i:i+3]))
+		}
+	}
+	return out
+}
+
+func trigramSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, t := range list {
+		set[t] = true
+	}
+	return set
+}
+
+// Similarity is pg_trgm Similarity(a, b): shared trigrams over all trigrams.
+func Similarity(a, b string) float64 {
+	x, y := trigramSet(Trigrams(a)), trigramSet(Trigrams(b))
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range x {
+		if y[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(x)+len(y)-common)
+}
+
+// WordSimilarity is pg_trgm word_similarity(q, s): the best similarity of
+// the trigrams of q with a continuous extent of the trigrams of s.
+func WordSimilarity(q, s string) float64 {
+	qs := trigramSet(Trigrams(q))
+	seq := Trigrams(s)
+	if len(qs) == 0 || len(seq) == 0 {
+		return 0
+	}
+	best := 0.0
+	for i := range seq {
+		if !qs[seq[i]] {
+			continue
+		}
+		extent := map[string]bool{}
+		common := 0
+		for j := i; j < len(seq); j++ {
+			if !extent[seq[j]] {
+				extent[seq[j]] = true
+				if qs[seq[j]] {
+					common++
+				}
+			}
+			if !qs[seq[j]] {
+				continue
+			}
+			sim := float64(common) / float64(len(qs)+len(extent)-common)
+			best = max(best, sim)
+		}
+	}
+	return best
+}
+
+// Rank approximates ts_rank(vector, query, 32) for a tsvector with title
+// lexemes weighted A and the rest B: every distinct lexeme of query found in
+// all adds 0.1 when it is also in title and 0.04 otherwise (the A and B
+// weights, scaled by 0.1), and the sum is mapped to rank/(rank+1).
+func Rank(title, all []string, query map[string]bool) float64 {
+	inTitle := map[string]bool{}
+	for _, l := range title {
+		inTitle[l] = true
+	}
+	rank := 0.0
+	seen := map[string]bool{}
+	for _, l := range all {
+		if !query[l] || seen[l] {
+			continue
+		}
+		seen[l] = true
+		if inTitle[l] {
+			rank += 0.1
+		} else {
+			rank += 0.04
+		}
+	}
+	return rank / (rank + 1)
+}
@@ -0,0 +1,35 @@
+package textsearch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrigrams(t *testing.T) {
+	require.Equal(t, []string{"  w", " wo", "wor", "ord", "rd "}, Trigrams("Word!"))
+	require.Empty(t, Trigrams("  ...  "))
+
+	// Values from the pg_trgm documentation.
+	require.InDelta(t, 0.8, WordSimilarity("word", "two words"), 1e-9)
+	require.InDelta(t, 4.0/11, Similarity("word", "two words"), 1e-9)
+	require.Equal(t, 1.0, Similarity("Index", "index"))
+	require.Zero(t, WordSimilarity("", "index"))
+}
+
+func TestLexemes(t *testing.T) {
+	require.Equal(t, []string{"releas", "not", "review", "budget"},
+		Lexemes("english", "The releases notes: reviewed budgets"))
+	require.Equal(t, []string{"миграци", "таблиц", "нов", "сервер"},
+		Lexemes("russian", "Миграция таблицы на новый сервер"))
+	require.Equal(t, []string{"the", "releases"}, Lexemes("simple", "The releases"))
+	require.Equal(t, Stem("russian", "ёлка"), Stem("russian", "елки"))
+}
+
+func TestRank(t *testing.T) {
+	query := map[string]bool{"vacuum": true, "index": true}
+	require.Zero(t, Rank(nil, []string{"milk", "egg"}, query))
+	// One title hit (0.1) and one content hit (0.04); repeats count once.
+	rank := Rank([]string{"index"}, []string{"index", "vacuum", "vacuum"}, query)
+	require.InDelta(t, 0.14/1.14, rank, 1e-9)
+}
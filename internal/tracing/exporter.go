@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SpanData is a finished span as seen by exporters.
+type SpanData struct {
+	Service    string         `json:"service"`
+	Name       string         `json:"name"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	DurationMS float64        `json:"duration_ms"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Exporter receives finished, sampled spans. Export is called synchronously
+// from Span.End and must be safe for concurrent use.
+type Exporter interface {
+	Export(SpanData) error
+	Close() error
+}
+
+// WriterExporter writes one JSON object per span and line.
+type WriterExporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+// NewStdoutExporter writes spans to standard output.
+func NewStdoutExporter() *WriterExporter {
+	return NewWriterExporter(os.Stdout)
+}
+
+// NewFileExporter appends spans to the file at path, creating it if needed.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	e := NewWriterExporter(f)
+	e.closer = f
+	return e, nil
+}
+
+func (e *WriterExporter) Export(d SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(d)
+}
+
+// Close closes the underlying file, if the exporter owns one.
+func (e *WriterExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closer.Close()
+}
+
+// NopExporter drops all spans.
+type NopExporter struct{}
+
+func (NopExporter) Export(SpanData) error { return nil }
+func (NopExporter) Close() error          { return nil }
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const traceparentHeader = "traceparent"
+
+// Middleware starts a server span per request, continuing the trace from an
+// incoming traceparent header. Install it on the root chi router so the span
+// is named after the full route pattern, e.g. "GET /notes/{id}".
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, err := ParseTraceparent(r.Header.Get(traceparentHeader)); err == nil {
+			ctx = ContextWithRemote(ctx, sc)
+		}
+		ctx, span := t.Start(ctx, r.Method)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.route", route)
+		span.SetAttr("http.target", r.URL.Path)
+		span.SetAttr("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(errStatus(status))
+		}
+	})
+}
+
+// Transport traces outgoing requests and injects the traceparent header.
+type Transport struct {
+	Tracer *Tracer
+	// Base is the underlying transport; nil means http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := t.Tracer.Start(r.Context(), "HTTP "+r.Method)
+	defer span.End()
+	span.SetAttr("http.method", r.Method)
+	span.SetAttr("http.url", r.URL.Redacted())
+
+	if sc := span.Context(); sc.IsValid() {
+		// RoundTrippers must not modify the caller's request.
+		r = r.Clone(ctx)
+		r.Header.Set(traceparentHeader, sc.Traceparent())
+	}
+
+	resp, err := base.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttr("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetError(errStatus(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+type errStatus int
+
+func (e errStatus) Error() string { return http.StatusText(int(e)) }
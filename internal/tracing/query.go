@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+// QueryHook creates a child span for every repository statement.
+type QueryHook struct {
+	Tracer *Tracer
+	// System is the db.system attribute of the spans, e.g. "postgresql".
+	System string
+}
+
+var _ notes.QueryHook = QueryHook{}
+
+// NewQueryHook returns a hook for a store on the given database system.
+func NewQueryHook(tracer *Tracer, system string) QueryHook {
+	return QueryHook{Tracer: tracer, System: system}
+}
+
+func (h QueryHook) BeforeQuery(ctx context.Context, name string) context.Context {
+	ctx, _ = h.Tracer.Start(ctx, "db "+name)
+	return ctx
+}
+
+func (h QueryHook) AfterQuery(ctx context.Context, ev notes.QueryEvent) {
+	span := SpanFromContext(ctx)
+	span.SetAttr("db.system", h.System)
+	span.SetAttr("db.statement", ev.Name)
+	span.SetAttr("db.rows", ev.Rows)
+	// Not found is a regular outcome, not a failed statement.
+	if !errors.Is(ev.Err, sql.ErrNoRows) {
+		span.SetError(ev.Err)
+	}
+	span.End()
+}
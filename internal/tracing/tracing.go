@@ -0,0 +1,271 @@
+// Package tracing records spans for HTTP requests, outgoing calls and
+// repository statements. Context is propagated with the W3C traceparent
+// header; finished spans are handed to an Exporter.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// SpanContext is the part of a span propagated across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Traceparent formats sc as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+var errTraceparent = errors.New("tracing: malformed traceparent")
+
+// ParseTraceparent parses a W3C traceparent header value
+// ("00-<trace-id>-<parent-id>-<flags>").
+func ParseTraceparent(v string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, errTraceparent
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, errTraceparent
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, err
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, errTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+func decodeHex(dst []byte, s string) error {
+	// Upper case hex is invalid per the spec.
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return errTraceparent
+	}
+	if _, err := hex.Decode(dst, []byte(s)); err != nil {
+		return errTraceparent
+	}
+	return nil
+}
+
+// Span is an operation in a trace. A nil *Span is valid and does nothing, so
+// callers never have to check whether tracing is enabled.
+type Span struct {
+	tracer   *Tracer
+	name     string
+	sc       SpanContext
+	parentID SpanID
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs map[string]any
+	err   string
+	ended bool
+}
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName renames the span, e.g. once the HTTP route is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed; a nil err is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and exports it if it is sampled. Only the first call
+// has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	d := SpanData{
+		Service:    s.tracer.service,
+		Name:       s.name,
+		TraceID:    s.sc.TraceID.String(),
+		SpanID:     s.sc.SpanID.String(),
+		Start:      s.start,
+		End:        end,
+		DurationMS: float64(end.Sub(s.start).Microseconds()) / 1000,
+		Status:     "ok",
+		Error:      s.err,
+		Attributes: s.attrs,
+	}
+	s.mu.Unlock()
+
+	if s.parentID.IsValid() {
+		d.ParentID = s.parentID.String()
+	}
+	if d.Error != "" {
+		d.Status = "error"
+	}
+	if s.sc.Sampled {
+		s.tracer.export(d)
+	}
+}
+
+// Tracer creates spans and sends sampled ones to an exporter.
+type Tracer struct {
+	service  string
+	exporter Exporter
+	ratio    float64
+
+	// errOnce logs only the first export error.
+	errOnce sync.Once
+	onError func(error)
+}
+
+// New returns a tracer. Root spans are sampled with probability ratio
+// (0..1); child spans and requests with an incoming traceparent follow
+// the parent's decision.
+func New(service string, exporter Exporter, ratio float64) *Tracer {
+	return &Tracer{service: service, exporter: exporter, ratio: ratio, onError: func(error) {}}
+}
+
+// OnExportError sets a callback for the first failed export.
+func (t *Tracer) OnExportError(fn func(error)) *Tracer {
+	t.onError = fn
+	return t
+}
+
+func (t *Tracer) export(d SpanData) {
+	if err := t.exporter.Export(d); err != nil {
+		t.errOnce.Do(func() { t.onError(fmt.Errorf("tracing: export: %w", err)) })
+	}
+}
+
+// Start starts a span that is a child of the span or remote parent in ctx.
+// It returns a nil span on a nil tracer.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, start: time.Now(), attrs: map[string]any{}}
+
+	parent := SpanFromContext(ctx).Context()
+	if !parent.IsValid() {
+		parent = remoteFromContext(ctx)
+	}
+	if parent.IsValid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parentID = parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.ratio >= 1 || (t.ratio > 0 && rand.Float64() < t.ratio)
+	}
+	s.sc.SpanID = newSpanID()
+	return ContextWithSpan(ctx, s), s
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext returns the current span or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemote stores a span context received from another process; the
+// next span started from ctx becomes its child.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func remoteFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+func newTraceID() TraceID {
+	var t TraceID
+	for !t.IsValid() {
+		_, _ = crand.Read(t[:])
+	}
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	for !s.IsValid() {
+		_, _ = crand.Read(s[:])
+	}
+	return s
+}
@@ -0,0 +1,236 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/notes"
+)
+
+type memExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memExporter) Export(d SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, d)
+	return nil
+}
+
+func (e *memExporter) Close() error { return nil }
+
+func (e *memExporter) byName(t *testing.T, name string) SpanData {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range e.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("span %q not exported", name)
+	return SpanData{}
+}
+
+func TestTraceparent_RoundTrip(t *testing.T) {
+	const v = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(v)
+	require.NoError(t, err)
+	require.True(t, sc.Sampled)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.Equal(t, v, sc.Traceparent())
+
+	sc, err = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	require.False(t, sc.Sampled)
+}
+
+func TestTraceparent_Invalid(t *testing.T) {
+	for _, v := range []string{
+		"",
+		"garbage",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(v)
+		require.Error(t, err, v)
+	}
+}
+
+func TestTracer_ChildSpansAndSampling(t *testing.T) {
+	exp := &memExporter{}
+	tr := New("svc", exp, 1)
+
+	ctx, root := tr.Start(context.Background(), "root")
+	_, child := tr.Start(ctx, "child")
+	child.SetAttr("k", "v")
+	child.SetError(errors.New("boom"))
+	child.End()
+	child.End()
+	root.End()
+
+	require.Len(t, exp.spans, 2)
+	c, r := exp.byName(t, "child"), exp.byName(t, "root")
+	require.Equal(t, r.TraceID, c.TraceID)
+	require.Equal(t, r.SpanID, c.ParentID)
+	require.Empty(t, r.ParentID)
+	require.Equal(t, "error", c.Status)
+	require.Equal(t, "boom", c.Error)
+	require.Equal(t, "v", c.Attributes["k"])
+	require.Equal(t, "svc", c.Service)
+
+	exp = &memExporter{}
+	tr = New("svc", exp, 0)
+	ctx, root = tr.Start(context.Background(), "root")
+	_, child = tr.Start(ctx, "child")
+	require.True(t, child.Context().IsValid())
+	child.End()
+	root.End()
+	require.Empty(t, exp.spans)
+
+	var nilTracer *Tracer
+	_, s := nilTracer.Start(context.Background(), "x")
+	require.Nil(t, s)
+	s.SetAttr("k", 1)
+	s.End()
+}
+
+func TestMiddleware_ContinuesIncomingTrace(t *testing.T) {
+	exp := &memExporter{}
+	tr := New("svc", exp, 0)
+
+	var inner SpanContext
+	r := chi.NewRouter()
+	r.Use(tr.Middleware)
+	r.Get("/notes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		inner = SpanFromContext(r.Context()).Context()
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/notes/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The incoming sampled flag wins over the local ratio.
+	s := exp.byName(t, "GET /notes/{id}")
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID)
+	require.Equal(t, "00f067aa0ba902b7", s.ParentID)
+	require.Equal(t, inner.SpanID.String(), s.SpanID)
+	require.Equal(t, "/notes/{id}", s.Attributes["http.route"])
+	require.Equal(t, http.StatusTeapot, s.Attributes["http.status_code"])
+	require.Equal(t, "ok", s.Status)
+}
+
+func TestMiddleware_ServerErrorMarksSpan(t *testing.T) {
+	exp := &memExporter{}
+	r := chi.NewRouter()
+	r.Use(New("svc", exp, 1).Middleware)
+	r.Get("/x", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope", nil))
+
+	require.Equal(t, "error", exp.byName(t, "GET /x").Status)
+	exp.byName(t, "GET unmatched")
+}
+
+func TestTransport_InjectsTraceparent(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	exp := &memExporter{}
+	tr := New("svc", exp, 1)
+	ctx, root := tr.Start(context.Background(), "root")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: &Transport{Tracer: tr}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	root.End()
+
+	require.Empty(t, req.Header.Get("traceparent"))
+	out := exp.byName(t, "HTTP POST")
+	require.Equal(t, root.Context().SpanID.String(), out.ParentID)
+	require.Equal(t, "00-"+out.TraceID+"-"+out.SpanID+"-01", got)
+	require.Equal(t, http.StatusOK, out.Attributes["http.status_code"])
+}
+
+func TestQueryHook_Spans(t *testing.T) {
+	exp := &memExporter{}
+	tr := New("svc", exp, 1)
+	h := NewQueryHook(tr, "sqlite")
+
+	ctx, root := tr.Start(context.Background(), "GET /notes/{id}")
+	qctx := h.BeforeQuery(ctx, "notes.get")
+	h.AfterQuery(qctx, notes.QueryEvent{Name: "notes.get", Rows: 0, Err: sql.ErrNoRows})
+	qctx = h.BeforeQuery(ctx, "notes.list_first")
+	h.AfterQuery(qctx, notes.QueryEvent{Name: "notes.list_first", Rows: 20})
+	qctx = h.BeforeQuery(ctx, "notes.delete")
+	h.AfterQuery(qctx, notes.QueryEvent{Name: "notes.delete", Err: errors.New("conn reset")})
+	root.End()
+
+	get := exp.byName(t, "db notes.get")
+	require.Equal(t, root.Context().SpanID.String(), get.ParentID)
+	require.Equal(t, "notes.get", get.Attributes["db.statement"])
+	require.Equal(t, "sqlite", get.Attributes["db.system"])
+	require.Equal(t, "ok", get.Status)
+	require.Equal(t, int64(20), exp.byName(t, "db notes.list_first").Attributes["db.rows"])
+	require.Equal(t, "error", exp.byName(t, "db notes.delete").Status)
+}
+
+func TestWriterExporter_JSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	tr := New("svc", NewWriterExporter(&buf), 1)
+	_, s := tr.Start(context.Background(), "a")
+	s.End()
+	_, s = tr.Start(context.Background(), "b")
+	s.End()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var d SpanData
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &d))
+	require.Equal(t, "b", d.Name)
+	require.Len(t, d.TraceID, 32)
+	require.Len(t, d.SpanID, 16)
+}
+
+func TestFileExporter_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	for i := 0; i < 2; i++ {
+		e, err := NewFileExporter(path)
+		require.NoError(t, err)
+		require.NoError(t, e.Export(SpanData{Name: "x"}))
+		require.NoError(t, e.Close())
+	}
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(b), "\n"))
+}
@@ -0,0 +1,3 @@
+-- 002_notes_title_trgm.down.sql
+-- pg_trgm stays: it may be used outside this schema.
+DROP INDEX IF EXISTS idx_notes_title_trgm;
@@ -0,0 +1,6 @@
+-- 002_notes_title_trgm.sql
+-- Typo-tolerant search: GET /notes?q=...&fuzzy=1 (word_similarity, operator <%).
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_notes_title_trgm
+  ON notes USING GIN (title gin_trgm_ops);
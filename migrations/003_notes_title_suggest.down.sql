@@ -0,0 +1,2 @@
+-- 003_notes_title_suggest.down.sql
+DROP INDEX IF EXISTS idx_notes_title_lower_gist;
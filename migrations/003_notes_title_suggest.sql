@@ -0,0 +1,6 @@
+-- 003_notes_title_suggest.sql
+-- Title autocomplete: GET /notes/suggest?prefix=... (LIKE 'prefix%' + KNN ordering by <->).
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_notes_title_lower_gist
+  ON notes USING GIST (lower(title) gist_trgm_ops);
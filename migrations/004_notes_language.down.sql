@@ -0,0 +1,7 @@
+-- 004_notes_language.down.sql
+DROP INDEX IF EXISTS idx_notes_search_vector;
+DROP TRIGGER IF EXISTS notes_search_vector_trg ON notes;
+DROP FUNCTION IF EXISTS notes_search_vector_update();
+ALTER TABLE notes DROP CONSTRAINT IF EXISTS notes_language_check;
+ALTER TABLE notes DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE notes DROP COLUMN IF EXISTS language;
@@ -0,0 +1,27 @@
+-- 004_notes_language.sql
+-- Language-aware full-text search: every note keeps its text search configuration
+-- and a tsvector built with it. The vector is maintained by a trigger, so plain
+-- INSERT/UPDATE/COPY keep it up to date.
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT 'simple';
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+ALTER TABLE notes DROP CONSTRAINT IF EXISTS notes_language_check;
+ALTER TABLE notes ADD CONSTRAINT notes_language_check
+  CHECK (language IN ('simple', 'english', 'russian'));
+
+CREATE OR REPLACE FUNCTION notes_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector(NEW.language::regconfig, coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector(NEW.language::regconfig, coalesce(NEW.content, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS notes_search_vector_trg ON notes;
+CREATE TRIGGER notes_search_vector_trg
+  BEFORE INSERT OR UPDATE OF title, content, language ON notes
+  FOR EACH ROW EXECUTE FUNCTION notes_search_vector_update();
+
+CREATE INDEX IF NOT EXISTS idx_notes_search_vector
+  ON notes USING GIN (search_vector);
@@ -0,0 +1,5 @@
+-- 005_notes_language_backfill.down.sql
+-- Detected languages are kept; only the title index used by the old search
+-- comes back.
+CREATE INDEX IF NOT EXISTS idx_notes_title_gin
+  ON notes USING GIN (to_tsvector('simple', title));
@@ -0,0 +1,38 @@
+-- 005_notes_language_backfill.sql
+-- Detects the language of notes created before 004 and builds their search_vector
+-- in batches, committing after each one so the table is never locked as a whole.
+-- CALL must run outside an explicit transaction (psql -f does that by default).
+-- migrate:no-transaction
+CREATE OR REPLACE PROCEDURE notes_backfill_language(batch_size INT DEFAULT 5000)
+LANGUAGE plpgsql AS $$
+DECLARE
+  updated INT;
+BEGIN
+  LOOP
+    UPDATE notes
+    SET language = CASE
+      WHEN length(regexp_replace(title || ' ' || content, '[^а-яёА-ЯЁ]', '', 'g'))
+         >= length(regexp_replace(title || ' ' || content, '[^a-zA-Z]', '', 'g'))
+       AND (title || ' ' || content) ~ '[а-яёА-ЯЁ]' THEN 'russian'
+      WHEN (title || ' ' || content) ~ '[a-zA-Z]' THEN 'english'
+      ELSE 'simple'
+    END
+    WHERE id IN (
+      SELECT id FROM notes
+      WHERE search_vector IS NULL
+      ORDER BY id
+      LIMIT batch_size
+    );
+    GET DIAGNOSTICS updated = ROW_COUNT;
+    EXIT WHEN updated = 0;
+    COMMIT;
+  END LOOP;
+END
+$$;
+
+CALL notes_backfill_language(5000);
+
+DROP PROCEDURE IF EXISTS notes_backfill_language(INT);
+
+-- Search now goes through idx_notes_search_vector.
+DROP INDEX IF EXISTS idx_notes_title_gin;
@@ -0,0 +1,8 @@
+-- 006_notes_references.down.sql
+DROP INDEX IF EXISTS idx_notes_refs;
+DROP INDEX IF EXISTS idx_notes_links;
+DROP INDEX IF EXISTS idx_notes_tags;
+DROP FUNCTION IF EXISTS notes_jaccard(ANYARRAY, ANYARRAY);
+DROP FUNCTION IF EXISTS notes_refs(TEXT);
+DROP FUNCTION IF EXISTS notes_links(TEXT);
+DROP FUNCTION IF EXISTS notes_tags(TEXT);
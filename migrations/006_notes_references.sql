@@ -0,0 +1,36 @@
+-- 006_notes_references.sql
+-- Related notes also rank by shared #hashtags, shared URLs and references
+-- between notes (/notes/{id} paths). They are parsed from the content by
+-- these functions, which mirror the patterns in internal/notes/related.go,
+-- and indexed (GIN on the expressions) to find candidates.
+CREATE OR REPLACE FUNCTION notes_tags(content TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT lower(m[1])), '{}')
+  FROM regexp_matches(content, '(?:^|\s)#([[:alnum:]_]+)', 'g') AS m
+$$;
+
+CREATE OR REPLACE FUNCTION notes_links(content TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT m[1]), '{}')
+  FROM regexp_matches(content, '(https?://[^\s<>"'']*[^\s<>"''.,;:!?)])', 'g') AS m
+$$;
+
+-- At most 18 digits, so the cast cannot overflow.
+CREATE OR REPLACE FUNCTION notes_refs(content TEXT) RETURNS BIGINT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT m[1]::bigint), '{}')
+  FROM regexp_matches(content, '/notes/([1-9][0-9]{0,17})', 'g') AS m
+$$;
+
+-- |a ∩ b| / |a ∪ b|; 0 if either set is empty.
+CREATE OR REPLACE FUNCTION notes_jaccard(a ANYARRAY, b ANYARRAY) RETURNS DOUBLE PRECISION
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT CASE WHEN cardinality(a) = 0 OR cardinality(b) = 0 THEN 0 ELSE
+    (SELECT count(*) FROM (SELECT unnest(a) INTERSECT SELECT unnest(b)) i)::float8 /
+    (SELECT count(*) FROM (SELECT unnest(a) UNION SELECT unnest(b)) u)
+  END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_notes_tags ON notes USING GIN (notes_tags(content));
+CREATE INDEX IF NOT EXISTS idx_notes_links ON notes USING GIN (notes_links(content));
+CREATE INDEX IF NOT EXISTS idx_notes_refs ON notes USING GIN (notes_refs(content));
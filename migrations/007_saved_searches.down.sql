@@ -0,0 +1,7 @@
+-- 007_saved_searches.down.sql
+DROP TABLE IF EXISTS saved_search_matches;
+DROP TABLE IF EXISTS saved_searches;
+DROP INDEX IF EXISTS idx_notes_updated_id;
+DROP TRIGGER IF EXISTS notes_touch_updated_at_trg ON notes;
+DROP FUNCTION IF EXISTS notes_touch_updated_at();
+ALTER TABLE notes DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,42 @@
+-- 007_saved_searches.sql
+-- Saved searches, their in-app inbox of matched notes and notes.updated_at,
+-- which the background worker uses to find newly created or changed notes.
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE OR REPLACE FUNCTION notes_touch_updated_at() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := now();
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS notes_touch_updated_at_trg ON notes;
+CREATE TRIGGER notes_touch_updated_at_trg
+  BEFORE UPDATE OF title, content, language ON notes
+  FOR EACH ROW EXECUTE FUNCTION notes_touch_updated_at();
+
+CREATE INDEX IF NOT EXISTS idx_notes_updated_id ON notes (updated_at, id);
+
+CREATE TABLE IF NOT EXISTS saved_searches (
+  id BIGSERIAL PRIMARY KEY,
+  name TEXT NOT NULL UNIQUE,
+  query TEXT NOT NULL,
+  language TEXT NOT NULL DEFAULT 'simple',
+  notify BOOLEAN NOT NULL DEFAULT false,
+  webhook_url TEXT,
+  -- high-water mark of notes.updated_at already evaluated by the worker
+  checked_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+  id BIGSERIAL PRIMARY KEY,
+  saved_search_id BIGINT NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+  note_id BIGINT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+  matched_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  read_at TIMESTAMPTZ,
+  UNIQUE (saved_search_id, note_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_search_matches_inbox
+  ON saved_search_matches (saved_search_id, id DESC);
@@ -0,0 +1,12 @@
+-- 008_schema_migrations.sql
+-- Records applied migration versions; /readyz compares the latest one with the
+-- version the binary was built for. Migrations applied by hand insert their
+-- own version at the end.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT PRIMARY KEY,
+  applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO schema_migrations (version)
+VALUES (1), (2), (3), (4), (5), (6), (7), (8)
+ON CONFLICT (version) DO NOTHING;
@@ -0,0 +1,2 @@
+-- 009_rate_limits.down.sql
+DROP TABLE IF EXISTS rate_limit_buckets;
@@ -0,0 +1,14 @@
+-- 009_rate_limits.sql
+-- Token buckets of the Postgres rate limit backend (RATE_LIMIT_BACKEND=postgres).
+-- Rows are short-lived and rewritten on every request, so the table is
+-- unlogged: buckets are lost on a crash, which only resets the limits.
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+  key TEXT PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  allowed BOOLEAN NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated ON rate_limit_buckets (updated_at);
+
+INSERT INTO schema_migrations (version) VALUES (9) ON CONFLICT (version) DO NOTHING;
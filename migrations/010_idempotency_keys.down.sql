@@ -0,0 +1,2 @@
+-- 010_idempotency_keys.down.sql
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,11 @@
+-- 011_idempotency_client.sql
+-- Idempotency keys are chosen by clients, so they are scoped per client (the
+-- hashed API key, or the remote address): one client can neither replay nor
+-- block another client's requests by reusing its key. Keys stored before
+-- this migration keep an empty client until they expire.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS client TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD CONSTRAINT idempotency_keys_pkey PRIMARY KEY (client, key);
+
+INSERT INTO schema_migrations (version) VALUES (11) ON CONFLICT (version) DO NOTHING;
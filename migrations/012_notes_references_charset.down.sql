@@ -0,0 +1,15 @@
+-- 012_notes_references_charset.down.sql
+CREATE OR REPLACE FUNCTION notes_tags(content TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT lower(m[1])), '{}')
+  FROM regexp_matches(content, '(?:^|\s)#([[:alnum:]_]+)', 'g') AS m
+$$;
+
+CREATE OR REPLACE FUNCTION notes_links(content TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT m[1]), '{}')
+  FROM regexp_matches(content, '(https?://[^\s<>"'']*[^\s<>"''.,;:!?)])', 'g') AS m
+$$;
+
+REINDEX INDEX idx_notes_tags;
+REINDEX INDEX idx_notes_links;
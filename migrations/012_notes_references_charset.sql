@@ -0,0 +1,24 @@
+-- 012_notes_references_charset.sql
+-- notes_tags and notes_links used [[:alnum:]], \s and lower(), which follow
+-- the database locale, so they disagreed with internal/notes/related.go and
+-- could change under the IMMUTABLE indexes built on them. The character sets
+-- and the case folding are now spelled out: tags are Latin or Cyrillic
+-- letters, digits and '_', whitespace is ASCII as in Go's \s.
+CREATE OR REPLACE FUNCTION notes_tags(content TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT translate(m[1],
+    'ABCDEFGHIJKLMNOPQRSTUVWXYZАБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯ',
+    'abcdefghijklmnopqrstuvwxyzабвгдеёжзийклмнопрстуфхцчшщъыьэюя')), '{}')
+  FROM regexp_matches(content, '(?:^|[ \t\n\f\r])#([0-9A-Za-zА-Яа-яЁё_]+)', 'g') AS m
+$$;
+
+CREATE OR REPLACE FUNCTION notes_links(content TEXT) RETURNS TEXT[]
+LANGUAGE sql IMMUTABLE PARALLEL SAFE AS $$
+  SELECT coalesce(array_agg(DISTINCT m[1]), '{}')
+  FROM regexp_matches(content, '(https?://[^ \t\n\f\r<>"'']*[^ \t\n\f\r<>"''.,;:!?)])', 'g') AS m
+$$;
+
+REINDEX INDEX idx_notes_tags;
+REINDEX INDEX idx_notes_links;
+
+INSERT INTO schema_migrations (version) VALUES (12) ON CONFLICT (version) DO NOTHING;
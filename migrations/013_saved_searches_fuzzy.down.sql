@@ -0,0 +1,2 @@
+-- 013_saved_searches_fuzzy.down.sql
+ALTER TABLE saved_searches DROP COLUMN IF EXISTS fuzzy;
@@ -0,0 +1,7 @@
+-- 013_saved_searches_fuzzy.sql
+-- Saved searches keep the fuzzy filter of GET /notes: their results and the
+-- background worker match titles by trigram word similarity instead of
+-- full-text search.
+ALTER TABLE saved_searches ADD COLUMN IF NOT EXISTS fuzzy BOOLEAN NOT NULL DEFAULT false;
+
+INSERT INTO schema_migrations (version) VALUES (13) ON CONFLICT (version) DO NOTHING;
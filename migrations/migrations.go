@@ -0,0 +1,13 @@
+// Package migrations holds the SQL schema of the notes API. The files are
+// embedded into the binary and applied by internal/migrate.
+package migrations
+
+import "embed"
+
+// Latest is the schema version the code expects (the highest NNN_*.sql file).
+const Latest = 13
+
+// FS contains the NNN_name.sql and NNN_name.down.sql files.
+//
+//go:embed *.sql
+var FS embed.FS
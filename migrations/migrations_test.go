@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"example.com/notes-api-pz14/internal/migrate"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := migrate.Load(FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, list)
+
+	// Versions are contiguous and Latest matches the newest file.
+	for i, m := range list {
+		require.Equal(t, int64(i+1), m.Version, m.Name)
+	}
+	require.Equal(t, int64(Latest), list[len(list)-1].Version)
+
+	byName := map[string]migrate.Migration{}
+	for _, m := range list {
+		byName[m.Name] = m
+	}
+	// The backfill CALLs a procedure that commits.
+	require.True(t, byName["notes_language_backfill"].NoTx())
+	require.Len(t, migrate.Split(byName["notes_language_backfill"].Up), 4)
+	require.Empty(t, byName["init"].Down)
+	require.Empty(t, byName["schema_migrations"].Down)
+	require.NotEmpty(t, byName["idempotency_keys"].Down)
+	require.NotEmpty(t, byName["idempotency_client"].Down)
+}
@@ -1,4 +1,6 @@
 -- scripts/explain.sql
+-- Needs realistic volume, e.g.: notesctl seed -n 1000000 -end 2026-01-01T00:00:00Z
+-- The same plans are checked automatically by notesctl plans (internal/plancheck).
 \echo '--- OFFSET pagination (плохо на больших OFFSET) ---'
 EXPLAIN (ANALYZE, BUFFERS)
 SELECT id, title, content, created_at
@@ -14,8 +16,49 @@ WHERE (created_at, id) < (now(), 9223372036854775807)
 ORDER BY created_at DESC, id DESC
 LIMIT 20;
 
-\echo '--- Search by title (GIN index) ---'
+\echo '--- Full-text search (GIN on search_vector, idx_notes_search_vector) ---'
 EXPLAIN (ANALYZE, BUFFERS)
 SELECT id, title
+FROM notes, plainto_tsquery('russian', 'заметки') AS q
+WHERE language = 'russian' AND search_vector @@ q
+ORDER BY created_at DESC, id DESC
+LIMIT 20;
+
+\echo '--- Fuzzy search by title (GIN trigram, idx_notes_title_trgm) ---'
+EXPLAIN (ANALYZE, BUFFERS)
+SELECT id, title, content, created_at, word_similarity('titel', title) AS score
 FROM notes
-WHERE to_tsvector('simple', title) @@ plainto_tsquery('simple', 'title');
+WHERE 'titel' <% title
+ORDER BY score DESC, created_at DESC, id DESC
+LIMIT 20;
+
+\echo '--- Title autocomplete (GiST trigram, idx_notes_title_lower_gist) ---'
+EXPLAIN (ANALYZE, BUFFERS)
+SELECT id, title
+FROM notes
+WHERE lower(title) LIKE 'tit%'
+ORDER BY lower(title) <-> 'tit'
+LIMIT 10;
+
+\echo '--- Related notes (BitmapOr over idx_notes_search_vector, idx_notes_title_trgm, idx_notes_tags, idx_notes_links, idx_notes_refs and notes_pkey) ---'
+EXPLAIN (ANALYZE, BUFFERS)
+WITH src AS (
+  SELECT id, title,
+         NULLIF(array_to_string(ARRAY(
+           SELECT quote_literal(l) FROM unnest(tsvector_to_array(search_vector)) AS l
+         ), ' | '), '')::tsquery AS q,
+         notes_tags(content) AS tags,
+         notes_links(content) AS links,
+         notes_refs(content) AS refs
+  FROM notes
+  WHERE id = 1
+)
+SELECT n.id, n.title
+FROM notes n, src
+WHERE n.id <> src.id
+  AND (n.search_vector @@ src.q OR n.title % src.title
+       OR notes_tags(n.content) && src.tags
+       OR notes_links(n.content) && src.links
+       OR notes_refs(n.content) @> ARRAY[src.id]
+       OR n.id = ANY(src.refs))
+LIMIT 10;